@@ -18,6 +18,7 @@ import (
 	"github.com/aescanero/dago/pkg/api/http"
 	"github.com/aescanero/dago/pkg/api/websocket"
 
+	"github.com/google/uuid"
 	goredis "github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -65,11 +66,16 @@ func main() {
 	}
 	logger.Info("connected to Redis", zap.String("addr", cfg.Redis.Addr))
 
-	// Initialize adapters
+	// Initialize adapters. Consumer names must be unique across replicas
+	// and restarts: PIDs repeat across containers.
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "dago"
+	}
 	eventBus, err := redis.NewStreamsEventBus(
 		redisClient,
 		"dago-workers",
-		fmt.Sprintf("dago-%d", os.Getpid()),
+		fmt.Sprintf("%s-%s", hostname, uuid.New().String()),
 		logger,
 	)
 	if err != nil {
@@ -20,6 +20,9 @@ require (
 	// Redis (events + storage + cache)
 	github.com/redis/go-redis/v9 v9.17.2
 
+	// JSON Schema validation
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+
 	// Logging
 	go.uber.org/zap v1.26.0
 	google.golang.org/grpc v1.64.1
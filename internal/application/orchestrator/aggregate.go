@@ -0,0 +1,365 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/internal/expression"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// NodeTypeAggregate is the type of nodes the orchestrator evaluates itself,
+// combining the outputs of their predecessors into one result
+const NodeTypeAggregate graph.NodeType = "aggregate"
+
+// Aggregate node settings
+const (
+	// ConfigAggregateStrategy selects how branch outputs are combined
+	ConfigAggregateStrategy = "strategy"
+
+	// ConfigAggregateField is an expression over each branch output
+	// selecting its vote, or the value reduced; the whole output by default
+	ConfigAggregateField = "field"
+
+	// ConfigAggregateWeights weighs the votes of branches by node ID
+	ConfigAggregateWeights = "weights"
+
+	// ConfigAggregateScore is an expression over each branch output
+	// scaling its weight, such as a reported confidence
+	ConfigAggregateScore = "score"
+
+	// ConfigAggregateReducer combines the branch values of the reduce strategy
+	ConfigAggregateReducer = "reducer"
+)
+
+// Node state metadata keys of aggregate nodes
+const (
+	// MetadataVotes records each branch's vote and weight by node ID
+	MetadataVotes = "votes"
+
+	// MetadataAggregate records the strategy, result and support of an
+	// aggregation
+	MetadataAggregate = "aggregate"
+)
+
+// ErrorClassAggregate is the error class of an aggregation that failed
+const ErrorClassAggregate = "aggregate"
+
+// AggregateStrategy selects how an aggregate node combines branch outputs
+type AggregateStrategy string
+
+const (
+	// AggregateMajority picks the value most branches voted for (default)
+	AggregateMajority AggregateStrategy = "majority"
+
+	// AggregateWeighted picks the value with the highest total weight
+	AggregateWeighted AggregateStrategy = "weighted"
+
+	// AggregateReduce combines the branch values with a reducer
+	AggregateReduce AggregateStrategy = "reduce"
+)
+
+// Reducers of the reduce strategy
+const (
+	AggregateSum    = "sum"
+	AggregateMean   = "mean"
+	AggregateMin    = "min"
+	AggregateMax    = "max"
+	AggregateAppend = "append"
+	AggregateMerge  = "merge"
+)
+
+// aggregateSpec holds the settings of an aggregate node
+type aggregateSpec struct {
+	strategy AggregateStrategy
+	field    *expression.Program
+	score    *expression.Program
+	weights  map[string]float64
+	reducer  string
+}
+
+// branchVote is the contribution of one predecessor to an aggregation
+type branchVote struct {
+	nodeID string
+	value  interface{}
+	weight float64
+}
+
+// parseAggregateSpec reads the settings of an aggregate node
+func parseAggregateSpec(cfg map[string]interface{}) (aggregateSpec, error) {
+	spec := aggregateSpec{strategy: AggregateStrategy(configString(cfg, ConfigAggregateStrategy))}
+
+	switch spec.strategy {
+	case "":
+		spec.strategy = AggregateMajority
+	case AggregateMajority, AggregateWeighted:
+	case AggregateReduce:
+		spec.reducer = configString(cfg, ConfigAggregateReducer)
+		switch spec.reducer {
+		case AggregateSum, AggregateMean, AggregateMin, AggregateMax, AggregateAppend, AggregateMerge:
+		default:
+			return spec, fmt.Errorf("unknown %s: %q", ConfigAggregateReducer, spec.reducer)
+		}
+	default:
+		return spec, fmt.Errorf("unknown aggregate %s: %s", ConfigAggregateStrategy, spec.strategy)
+	}
+
+	var err error
+	if source := configString(cfg, ConfigAggregateField); source != "" {
+		if spec.field, err = expression.Compile(source); err != nil {
+			return spec, err
+		}
+	}
+	if source := configString(cfg, ConfigAggregateScore); source != "" {
+		if spec.score, err = expression.Compile(source); err != nil {
+			return spec, err
+		}
+	}
+
+	if raw, ok := cfg[ConfigAggregateWeights]; ok {
+		weights, ok := raw.(map[string]interface{})
+		if !ok {
+			return spec, fmt.Errorf("%s must map node IDs to numbers", ConfigAggregateWeights)
+		}
+		spec.weights = make(map[string]float64, len(weights))
+		for nodeID := range weights {
+			weight, ok := configFloat(weights, nodeID)
+			if !ok || weight < 0 {
+				return spec, fmt.Errorf("invalid weight for %s: %v", nodeID, weights[nodeID])
+			}
+			spec.weights[nodeID] = weight
+		}
+	}
+
+	return spec, nil
+}
+
+// collectVotes evaluates the vote of every completed predecessor, earliest
+// completion first
+func collectVotes(state *domain.GraphState, nodeID string, spec aggregateSpec) ([]branchVote, error) {
+	var branches []string
+	for _, pred := range predecessors(state.Graph, nodeID) {
+		if predState := state.NodeStates[pred]; predState != nil && predState.Status == domain.ExecutionStatusCompleted {
+			branches = append(branches, pred)
+		}
+	}
+	sort.SliceStable(branches, func(i, j int) bool {
+		a, b := state.NodeStates[branches[i]].CompletedAt, state.NodeStates[branches[j]].CompletedAt
+		if a == nil || b == nil || a.Equal(*b) {
+			return branches[i] < branches[j]
+		}
+		return a.Before(*b)
+	})
+
+	votes := make([]branchVote, 0, len(branches))
+	for _, branch := range branches {
+		output := state.NodeStates[branch].Output
+		env := mappingEnv(output, "output")
+
+		vote := branchVote{nodeID: branch, value: output, weight: 1}
+		if spec.field != nil {
+			value, err := spec.field.Eval(env)
+			if err != nil {
+				return nil, fmt.Errorf("branch %s: %w", branch, err)
+			}
+			vote.value = value
+		}
+		if spec.strategy != AggregateWeighted {
+			votes = append(votes, vote)
+			continue
+		}
+
+		if weight, ok := spec.weights[branch]; ok {
+			vote.weight = weight
+		}
+		if spec.score != nil {
+			value, err := spec.score.Eval(env)
+			if err != nil {
+				return nil, fmt.Errorf("branch %s: %w", branch, err)
+			}
+			score, ok := numberOf(value)
+			if !ok {
+				return nil, fmt.Errorf("branch %s: score %v is not a number", branch, value)
+			}
+			vote.weight *= score
+		}
+		votes = append(votes, vote)
+	}
+	return votes, nil
+}
+
+// numberOf converts a decoded JSON or Go number
+func numberOf(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// tallyVotes picks the value with the highest total weight. Ties go to the
+// value whose first supporter completed first. It returns the winner and
+// its support.
+func tallyVotes(votes []branchVote) (interface{}, float64) {
+	type entry struct {
+		value   interface{}
+		support float64
+	}
+	var tally []*entry
+	byKey := make(map[string]*entry)
+	for _, vote := range votes {
+		// Votes are compared by their JSON encoding, which sorts object keys
+		encoded, _ := json.Marshal(vote.value)
+		e, ok := byKey[string(encoded)]
+		if !ok {
+			e = &entry{value: vote.value}
+			byKey[string(encoded)] = e
+			tally = append(tally, e)
+		}
+		e.support += vote.weight
+	}
+
+	best := tally[0]
+	for _, e := range tally[1:] {
+		if e.support > best.support {
+			best = e
+		}
+	}
+	return best.value, best.support
+}
+
+// reduceVotes combines the branch values with a reducer
+func reduceVotes(reducer string, votes []branchVote) (interface{}, error) {
+	switch reducer {
+	case AggregateAppend:
+		values := make([]interface{}, 0, len(votes))
+		for _, vote := range votes {
+			values = append(values, vote.value)
+		}
+		return values, nil
+	case AggregateMerge:
+		var merged interface{} = map[string]interface{}{}
+		for _, vote := range votes {
+			if _, ok := vote.value.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("branch %s: %v is not an object", vote.nodeID, vote.value)
+			}
+			merged = reduce(ReducerMerge, merged, vote.value)
+		}
+		return merged, nil
+	}
+
+	numbers := make([]float64, 0, len(votes))
+	for _, vote := range votes {
+		n, ok := numberOf(vote.value)
+		if !ok {
+			return nil, fmt.Errorf("branch %s: %v is not a number", vote.nodeID, vote.value)
+		}
+		numbers = append(numbers, n)
+	}
+
+	result := numbers[0]
+	for _, n := range numbers[1:] {
+		switch reducer {
+		case AggregateMin:
+			result = math.Min(result, n)
+		case AggregateMax:
+			result = math.Max(result, n)
+		default:
+			result += n
+		}
+	}
+	if reducer == AggregateMean {
+		result /= float64(len(votes))
+	}
+	return result, nil
+}
+
+// runAggregate evaluates an aggregate node over its predecessors' outputs
+// and reports it back the way a worker reports a node. Callers must hold the
+// execution lock.
+func (m *Manager) runAggregate(ctx context.Context, graphID, nodeID string, state *executionState) error {
+	spec, err := parseAggregateSpec(nodeConfig(state.Graph.GetNode(nodeID)))
+	var (
+		votes  []branchVote
+		result interface{}
+		record = map[string]interface{}{"strategy": string(spec.strategy)}
+	)
+	if err == nil {
+		votes, err = collectVotes(state.GraphState, nodeID, spec)
+	}
+	if err == nil && len(votes) == 0 {
+		err = fmt.Errorf("no completed branches to aggregate")
+	}
+	if err == nil {
+		if spec.strategy == AggregateReduce {
+			record["reducer"] = spec.reducer
+			result, err = reduceVotes(spec.reducer, votes)
+		} else {
+			var support float64
+			result, support = tallyVotes(votes)
+			record["support"] = support
+		}
+	}
+
+	now := time.Now()
+	dispatchID := uuid.New().String()
+	saveErr := m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[nodeID]
+		nodeState.StartedAt = &now
+		nodeState.Status = domain.ExecutionStatusRunning
+		setNodeMetadata(nodeState, MetadataDispatchID, dispatchID)
+
+		recorded := make(map[string]interface{}, len(votes))
+		for _, vote := range votes {
+			recorded[vote.nodeID] = map[string]interface{}{
+				"value":  vote.value,
+				"weight": vote.weight,
+			}
+		}
+		setNodeMetadata(nodeState, MetadataVotes, recorded)
+		if err == nil {
+			record["result"] = result
+			setNodeMetadata(nodeState, MetadataAggregate, record)
+		} else {
+			delete(nodeState.Metadata, MetadataAggregate)
+		}
+	})
+	if saveErr != nil {
+		m.logger.Error("failed to save aggregation",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(saveErr))
+	}
+
+	m.logger.Info("aggregated branches",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID),
+		zap.Int("branches", len(votes)),
+		zap.Bool("ok", err == nil))
+
+	data := map[string]interface{}{
+		"node_id":     nodeID,
+		"dispatch_id": dispatchID,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+		data["error_class"] = ErrorClassAggregate
+	} else {
+		data["output"] = result
+	}
+	return m.publishCompletion(ctx, graphID, data)
+}
@@ -0,0 +1,114 @@
+package orchestrator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/nodes"
+)
+
+// aggregateGraph fans start out to a, b and c, which agg combines
+func aggregateGraph(aggCfg map[string]interface{}) *domain.Graph {
+	return newGraph("start",
+		[]graph.Node{
+			startNode("start"),
+			executorNode("a", nil),
+			executorNode("b", nil),
+			executorNode("c", nil),
+			nodes.New("agg", NodeTypeAggregate, aggCfg),
+			endNode("end"),
+		},
+		edge("start", "a"), edge("start", "b"), edge("start", "c"),
+		edge("a", "agg"), edge("b", "agg"), edge("c", "agg"),
+		edge("agg", "end"))
+}
+
+// answers has a and b answer x with low confidence and c, last, answer y
+// with high confidence
+func answers(data map[string]interface{}) map[string]interface{} {
+	outputs := map[string]map[string]interface{}{
+		"a": {"answer": "x", "confidence": 0.2},
+		"b": {"answer": "x", "confidence": 0.3},
+		"c": {"answer": "y", "confidence": 0.9},
+	}
+	nodeID := data["node_id"].(string)
+	if nodeID == "c" {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return map[string]interface{}{"output": outputs[nodeID]}
+}
+
+func TestAggregateStrategies(t *testing.T) {
+	tests := []struct {
+		name   string
+		aggCfg map[string]interface{}
+		want   interface{}
+	}{
+		{"majority", map[string]interface{}{"field": "answer"}, "x"},
+		{"weighted by node", map[string]interface{}{"strategy": "weighted", "field": "answer", "weights": map[string]interface{}{"c": 3}}, "y"},
+		{"weighted by score", map[string]interface{}{"strategy": "weighted", "field": "answer", "score": "confidence"}, "y"},
+		{"max", map[string]interface{}{"strategy": "reduce", "reducer": "max", "field": "confidence"}, 0.9},
+		{"min", map[string]interface{}{"strategy": "reduce", "reducer": "min", "field": "confidence"}, 0.2},
+		{"append", map[string]interface{}{"strategy": "reduce", "reducer": "append", "field": "answer"}, []interface{}{"x", "x", "y"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, answers)
+			state := h.waitDone(t, h.submit(t, aggregateGraph(tt.aggCfg), nil))
+			if state.Status != domain.ExecutionStatusCompleted {
+				t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+			}
+			if got := state.NodeStates["agg"].Output; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("agg output = %v, want %v", got, tt.want)
+			}
+
+			// dago aggregates by itself and records how
+			if got := len(h.workFor("agg")); got != 0 {
+				t.Errorf("published %d work events for the aggregate node, want 0", got)
+			}
+			votes, _ := state.NodeStates["agg"].Metadata[MetadataVotes].(map[string]interface{})
+			if len(votes) != 3 {
+				t.Errorf("recorded votes = %v, want one per branch", votes)
+			}
+			record, _ := state.NodeStates["agg"].Metadata[MetadataAggregate].(map[string]interface{})
+			if !reflect.DeepEqual(record["result"], tt.want) {
+				t.Errorf("recorded result = %v, want %v", record["result"], tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateFailsOnValuesItCannotReduce(t *testing.T) {
+	h := newTestHarness(t, answers)
+	state := h.waitDone(t, h.submit(t, aggregateGraph(map[string]interface{}{
+		"strategy": "reduce",
+		"reducer":  "sum",
+		"field":    "answer",
+	}), nil))
+
+	if state.Status != domain.ExecutionStatusFailed {
+		t.Fatalf("status = %s, want failed", state.Status)
+	}
+	assertNodeStatus(t, state, "agg", domain.ExecutionStatusFailed)
+	if got := lastErrorClass(state, "agg"); got != ErrorClassAggregate {
+		t.Errorf("error class = %q, want %q", got, ErrorClassAggregate)
+	}
+}
+
+func TestAggregateSettingsAreValidatedOnSubmission(t *testing.T) {
+	h := newTestHarness(t, answers)
+	for _, aggCfg := range []map[string]interface{}{
+		{"strategy": "vote"},
+		{"strategy": "reduce"},
+		{"field": "((("},
+	} {
+		if _, err := h.manager.SubmitGraph(context.Background(), aggregateGraph(aggCfg), nil); err == nil {
+			t.Errorf("SubmitGraph() with %v succeeded", aggCfg)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"go.uber.org/zap"
+)
+
+// ConfigCompensation names the node that undoes a node's side effects when
+// the graph fails
+const ConfigCompensation = "compensation"
+
+// ExecutionStatusCompensating is the status of a failed execution while the
+// compensations of its completed nodes run
+const ExecutionStatusCompensating domain.ExecutionStatus = "compensating"
+
+// MetadataCompensationStatus records the progress of a completed node's
+// compensation: pending, running, completed or failed
+const MetadataCompensationStatus = "compensation_status"
+
+// Graph events of compensation progress
+const (
+	EventTypeGraphCompensating    domain.EventType = "graph.compensating"
+	EventTypeCompensationStarted  domain.EventType = "compensation.started"
+	EventTypeCompensationComplete domain.EventType = "compensation.completed"
+	EventTypeCompensationFailed   domain.EventType = "compensation.failed"
+)
+
+// Compensation progress values
+const (
+	compensationPending   = "pending"
+	compensationRunning   = "running"
+	compensationCompleted = "completed"
+	compensationFailed    = "failed"
+)
+
+// compensationNode returns the node that compensates nodeID, or ""
+func compensationNode(g *domain.Graph, nodeID string) string {
+	node := g.GetNode(nodeID)
+	if node == nil {
+		return ""
+	}
+	return configString(nodeConfig(node), ConfigCompensation)
+}
+
+// compensatedNode returns the node a compensation node undoes, or ""
+func compensatedNode(g *domain.Graph, compensationID string) string {
+	for _, nodeID := range sortedNodeIDs(g) {
+		if compensationNode(g, nodeID) == compensationID {
+			return nodeID
+		}
+	}
+	return ""
+}
+
+// validateCompensations checks that every compensation node is an executor
+// outside the edges of the graph that compensates a single node
+func validateCompensations(g *domain.Graph) error {
+	compensates := make(map[string]string)
+	for _, nodeID := range sortedNodeIDs(g) {
+		cfg := nodeConfig(g.Nodes[nodeID])
+		raw, ok := cfg[ConfigCompensation]
+		if !ok {
+			continue
+		}
+		compensationID, ok := raw.(string)
+		if !ok || compensationID == "" {
+			return fmt.Errorf("%s of node %s must name a node", ConfigCompensation, nodeID)
+		}
+
+		node, exists := g.Nodes[compensationID]
+		switch {
+		case !exists:
+			return fmt.Errorf("compensation node %s of node %s not found in graph", compensationID, nodeID)
+		case node.GetType() != graph.NodeTypeExecutor:
+			return fmt.Errorf("compensation node %s must be an executor", compensationID)
+		case compensationID == nodeID || compensationID == g.EntryNode || compensationID == finallyNode(g):
+			return fmt.Errorf("node %s cannot be a compensation node", compensationID)
+		case hasEdges(g, compensationID):
+			return fmt.Errorf("compensation node %s cannot have edges", compensationID)
+		case compensationNode(g, compensationID) != "":
+			return fmt.Errorf("compensation node %s cannot have a compensation", compensationID)
+		}
+
+		if other, taken := compensates[compensationID]; taken {
+			return fmt.Errorf("compensation node %s compensates both %s and %s", compensationID, other, nodeID)
+		}
+		compensates[compensationID] = nodeID
+	}
+	return nil
+}
+
+// compensationPlan returns the completed nodes with a compensation, most
+// recently completed first
+func compensationPlan(state *domain.GraphState) []string {
+	type completion struct {
+		nodeID string
+		seq    int
+	}
+	var completions []completion
+	for nodeID, nodeState := range state.NodeStates {
+		if nodeState.Status != domain.ExecutionStatusCompleted || nodeState.Metadata[MetadataErrorHandled] != nil {
+			continue
+		}
+		if compensationNode(state.Graph, nodeID) == "" {
+			continue
+		}
+		seq, _ := configInt(nodeState.Metadata, MetadataStateSeq)
+		completions = append(completions, completion{nodeID: nodeID, seq: seq})
+	}
+
+	sort.Slice(completions, func(i, j int) bool {
+		if completions[i].seq != completions[j].seq {
+			return completions[i].seq > completions[j].seq
+		}
+		return completions[i].nodeID < completions[j].nodeID
+	})
+
+	plan := make([]string, len(completions))
+	for i, c := range completions {
+		plan[i] = c.nodeID
+	}
+	return plan
+}
+
+// compensate starts compensating a failing execution, or advances the
+// compensation in progress. It reports whether the execution must wait for
+// a compensation node. Callers must hold the execution lock.
+func (m *Manager) compensate(ctx context.Context, graphID string, state *executionState, errorMsg string) bool {
+	// Compensations run before the finally node: once it started the outcome
+	// is settled, and neither its failure nor a graph timeout compensates
+	if finalizing(state.GraphState) {
+		return false
+	}
+
+	// A failure ends a pause or a wait for input
+	switch state.Status {
+	case domain.ExecutionStatusRunning, ExecutionStatusAwaitingInput, ExecutionStatusAwaitingSignal, ExecutionStatusPaused:
+		plan := compensationPlan(state.GraphState)
+		if len(plan) == 0 {
+			return false
+		}
+
+		err := m.updateState(ctx, state, func(s *domain.GraphState) {
+			s.Status = ExecutionStatusCompensating
+			s.Error = errorMsg
+			for _, nodeID := range plan {
+				setNodeMetadata(s.NodeStates[nodeID], MetadataCompensationStatus, compensationPending)
+			}
+		})
+		if err != nil {
+			m.logger.Error("failed to save state before compensation",
+				zap.String("graph_id", graphID),
+				zap.Error(err))
+			return false
+		}
+
+		// Branches still in flight are abandoned
+		if val, ok := m.executions.Load(graphID); ok {
+			stopNodeTimers(val.(*executionContext))
+		}
+
+		m.logger.Info("compensating failed execution",
+			zap.String("graph_id", graphID),
+			zap.Strings("nodes", plan))
+
+		// Publish compensating event (ignore error as it's non-critical)
+		_ = m.publishGraphEvent(ctx, graphID, EventTypeGraphCompensating, map[string]interface{}{
+			"error": errorMsg,
+			"nodes": plan,
+		})
+	}
+
+	if state.Status != ExecutionStatusCompensating {
+		return false
+	}
+	return m.advanceCompensation(ctx, graphID, state)
+}
+
+// advanceCompensation settles finished compensation nodes and dispatches
+// the next one. It reports whether a compensation node is in flight.
+func (m *Manager) advanceCompensation(ctx context.Context, graphID string, state *executionState) bool {
+	for _, nodeID := range compensationOrder(state.GraphState) {
+		compensationID := compensationNode(state.Graph, nodeID)
+		compensationState := state.NodeStates[compensationID]
+		if compensationState == nil {
+			continue
+		}
+
+		switch compensationState.Status {
+		case domain.ExecutionStatusRunning:
+			return true
+
+		case domain.ExecutionStatusPending:
+			// Retried compensation nodes return to pending while running
+			if configString(state.NodeStates[nodeID].Metadata, MetadataCompensationStatus) == compensationPending {
+				m.settleCompensation(ctx, graphID, state, nodeID, compensationRunning, "")
+			}
+			if err := m.publishNodeWork(ctx, graphID, compensationID, state); err != nil {
+				m.logger.Error("failed to publish compensation node work",
+					zap.String("graph_id", graphID),
+					zap.String("node_id", compensationID),
+					zap.Error(err))
+				m.settleCompensation(ctx, graphID, state, nodeID, compensationFailed, err.Error())
+				continue
+			}
+			// A failure while dispatching has advanced the compensation already
+			return true
+
+		case domain.ExecutionStatusCompleted:
+			if compensationState.Metadata[MetadataErrorHandled] != nil {
+				m.settleCompensation(ctx, graphID, state, nodeID, compensationFailed, compensationState.Error)
+			} else {
+				m.settleCompensation(ctx, graphID, state, nodeID, compensationCompleted, "")
+			}
+
+		default:
+			m.settleCompensation(ctx, graphID, state, nodeID, compensationFailed, compensationState.Error)
+		}
+	}
+	return false
+}
+
+// compensationOrder returns the nodes whose compensation is not settled,
+// most recently completed first
+func compensationOrder(state *domain.GraphState) []string {
+	var order []string
+	for _, nodeID := range compensationPlan(state) {
+		switch configString(state.NodeStates[nodeID].Metadata, MetadataCompensationStatus) {
+		case compensationPending, compensationRunning:
+			order = append(order, nodeID)
+		}
+	}
+	return order
+}
+
+// settleCompensation records the progress of a node's compensation and
+// publishes it as a graph event
+func (m *Manager) settleCompensation(ctx context.Context, graphID string, state *executionState, nodeID, progress, errorMsg string) {
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		setNodeMetadata(s.NodeStates[nodeID], MetadataCompensationStatus, progress)
+	})
+	if err != nil {
+		m.logger.Error("failed to save compensation progress",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+	}
+
+	eventType := EventTypeCompensationStarted
+	switch progress {
+	case compensationCompleted:
+		eventType = EventTypeCompensationComplete
+	case compensationFailed:
+		eventType = EventTypeCompensationFailed
+		m.logger.Warn("compensation failed",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.String("error", errorMsg))
+	}
+
+	data := map[string]interface{}{
+		"node_id":           nodeID,
+		"compensation_node": compensationNode(state.Graph, nodeID),
+	}
+	if errorMsg != "" {
+		data["error"] = errorMsg
+	}
+
+	// Publish compensation event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, eventType, data)
+}
@@ -0,0 +1,132 @@
+package orchestrator
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+// sagaGraph runs a -> b -> c, where a and b are undone by ca and cb
+func sagaGraph() *domain.Graph {
+	return newGraph("start",
+		[]graph.Node{
+			startNode("start"),
+			executorNode("a", map[string]interface{}{ConfigCompensation: "ca"}),
+			executorNode("b", map[string]interface{}{ConfigCompensation: "cb"}),
+			executorNode("c", nil),
+			executorNode("ca", nil),
+			executorNode("cb", nil),
+			endNode("end"),
+		},
+		edge("start", "a"), edge("a", "b"), edge("b", "c"), edge("c", "end"))
+}
+
+// failing answers the listed nodes with an error and the others with an output
+func failing(nodeIDs ...string) workerFunc {
+	return func(data map[string]interface{}) map[string]interface{} {
+		if slices.Contains(nodeIDs, data["node_id"].(string)) {
+			return map[string]interface{}{"error": "boom " + data["node_id"].(string)}
+		}
+		return map[string]interface{}{"output": data["node_id"]}
+	}
+}
+
+func TestCompensationsRunInReverseOnFailure(t *testing.T) {
+	h := newTestHarness(t, failing("c"))
+	state := h.waitDone(t, h.submit(t, sagaGraph(), nil))
+
+	if state.Status != domain.ExecutionStatusFailed || state.Error != "boom c" {
+		t.Fatalf("status = %s (%q), want failed with the original error", state.Status, state.Error)
+	}
+	want := []string{"a", "b", "c", "cb", "ca"}
+	if got := h.dispatchOrder(); !slices.Equal(got, want) {
+		t.Errorf("dispatch order = %v, want %v", got, want)
+	}
+	for _, nodeID := range []string{"a", "b"} {
+		if got := state.NodeStates[nodeID].Metadata[MetadataCompensationStatus]; got != compensationCompleted {
+			t.Errorf("%s compensation status = %v, want completed", nodeID, got)
+		}
+	}
+
+	// The compensation of b learns what it undoes
+	work := h.workFor("cb")
+	if len(work) != 1 {
+		t.Fatalf("cb dispatched %d times, want 1", len(work))
+	}
+	if compensate, _ := work[0].Data["compensate"].(map[string]interface{}); compensate["node_id"] != "b" || compensate["output"] != "b" {
+		t.Errorf("cb compensate = %v, want b and its output", work[0].Data["compensate"])
+	}
+}
+
+func TestFailedCompensationDoesNotStopTheOthers(t *testing.T) {
+	h := newTestHarness(t, failing("c", "cb"))
+	state := h.waitDone(t, h.submit(t, sagaGraph(), nil))
+
+	if state.Status != domain.ExecutionStatusFailed || state.Error != "boom c" {
+		t.Fatalf("status = %s (%q), want failed with the original error", state.Status, state.Error)
+	}
+	if got := state.NodeStates["b"].Metadata[MetadataCompensationStatus]; got != compensationFailed {
+		t.Errorf("b compensation status = %v, want failed", got)
+	}
+	if got := state.NodeStates["a"].Metadata[MetadataCompensationStatus]; got != compensationCompleted {
+		t.Errorf("a compensation status = %v, want completed", got)
+	}
+}
+
+func TestCompletedGraphIsNotCompensated(t *testing.T) {
+	h := newTestHarness(t, failing())
+	state := h.waitDone(t, h.submit(t, sagaGraph(), nil))
+
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	if got := len(h.workFor("ca")) + len(h.workFor("cb")); got != 0 {
+		t.Errorf("compensations dispatched %d times, want 0", got)
+	}
+}
+
+// sagaFinallyGraph is sagaGraph with f as its finally node
+func sagaFinallyGraph() *domain.Graph {
+	g := sagaGraph()
+	g.Nodes["f"] = executorNode("f", nil)
+	g.Metadata = map[string]interface{}{GraphFinally: "f"}
+	return g
+}
+
+func TestFailedFinallyNodeIsNotCompensated(t *testing.T) {
+	h := newTestHarness(t, failing("f"))
+	state := h.waitDone(t, h.submit(t, sagaFinallyGraph(), nil))
+
+	if state.Status != domain.ExecutionStatusFailed || state.Error != "boom f" {
+		t.Fatalf("status = %s (%q), want failed with the finally node's error", state.Status, state.Error)
+	}
+	if got := len(h.workFor("ca")) + len(h.workFor("cb")); got != 0 {
+		t.Errorf("compensations dispatched %d times, want 0", got)
+	}
+}
+
+func TestGraphTimeoutWhileFinalizingIsNotCompensated(t *testing.T) {
+	// The finally node answers once the graph timed out
+	release := make(chan struct{})
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] == "f" {
+			<-release
+		}
+		return map[string]interface{}{"output": data["node_id"]}
+	})
+	graphID := h.submit(t, sagaFinallyGraph(), nil)
+	h.waitNode(t, graphID, "f", domain.ExecutionStatusRunning)
+
+	h.manager.handleTimeout(graphID)
+	close(release)
+	state := h.waitDone(t, graphID)
+
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want the outcome reached before the timeout", state.Status, state.Error)
+	}
+	if got := len(h.workFor("ca")) + len(h.workFor("cb")); got != 0 {
+		t.Errorf("compensations dispatched %d times, want 0", got)
+	}
+}
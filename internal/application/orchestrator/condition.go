@@ -0,0 +1,126 @@
+package orchestrator
+
+import (
+	"fmt"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/internal/expression"
+)
+
+// EdgeDefault is the edge metadata flag marking the edge followed when none
+// of the conditions on its sibling edges holds
+const EdgeDefault = "default"
+
+// MetadataNextNodes records the successors selected by edge conditions
+const MetadataNextNodes = "next_nodes"
+
+// isDefaultEdge reports whether an edge is flagged as its source's default edge
+func isDefaultEdge(edge *graph.Edge) bool {
+	isDefault, _ := edge.Metadata[EdgeDefault].(bool)
+	return isDefault
+}
+
+// validateEdgeCondition checks that an edge's condition parses and that it
+// is not combined with a loop or default declaration
+func validateEdgeCondition(edge *graph.Edge) error {
+	if raw, ok := edge.Metadata[EdgeDefault]; ok {
+		if _, ok := raw.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", EdgeDefault)
+		}
+	}
+
+	if edge.Condition == "" {
+		return nil
+	}
+	if isDefaultEdge(edge) {
+		return fmt.Errorf("a default edge cannot have a condition")
+	}
+	if isLoopEdge(edge) {
+		return fmt.Errorf("loop edges use %s instead of a condition", EdgeExitCondition)
+	}
+
+	_, err := expression.Compile(edge.Condition)
+	return err
+}
+
+// hasEdgeConditions reports whether a node's outgoing edges are selected by
+// conditions rather than all followed
+func hasEdgeConditions(g *domain.Graph, nodeID string) bool {
+	for _, edge := range g.GetOutgoingEdges(nodeID) {
+		if !isLoopEdge(edge) && !isErrorEdge(edge) && (edge.Condition != "" || isDefaultEdge(edge)) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectEdges evaluates the conditions on a completed node's outgoing edges.
+// Unconditional edges are always followed, conditional edges when their
+// condition holds, and default edges when no condition holds.
+func selectEdges(state *domain.GraphState, nodeID string) ([]string, error) {
+	var selected, defaults []string
+	conditional, matched := false, false
+	var env map[string]interface{}
+
+	for _, edge := range state.Graph.GetOutgoingEdges(nodeID) {
+		switch {
+		case isLoopEdge(edge), isErrorEdge(edge):
+			continue
+		case isDefaultEdge(edge):
+			defaults = append(defaults, edge.To)
+		case edge.Condition == "":
+			selected = append(selected, edge.To)
+		default:
+			conditional = true
+			program, err := expression.Compile(edge.Condition)
+			if err != nil {
+				return nil, fmt.Errorf("condition on edge %s -> %s: %w", edge.From, edge.To, err)
+			}
+			if env == nil {
+				env = expressionEnv(state, nodeID)
+			}
+			ok, err := program.EvalBool(env)
+			if err != nil {
+				return nil, fmt.Errorf("condition on edge %s -> %s: %w", edge.From, edge.To, err)
+			}
+			if ok {
+				matched = true
+				selected = append(selected, edge.To)
+			}
+		}
+	}
+
+	if !matched {
+		selected = append(selected, defaults...)
+	}
+	if conditional && len(selected) == 0 {
+		return nil, fmt.Errorf("no condition on the outgoing edges of node %s holds and it has no default edge", nodeID)
+	}
+
+	next := make([]string, 0, len(selected))
+	seen := make(map[string]bool, len(selected))
+	for _, target := range selected {
+		if !seen[target] {
+			seen[target] = true
+			next = append(next, target)
+		}
+	}
+	return next, nil
+}
+
+// recordEdgeSelection evaluates the edge conditions of a node that just
+// completed and records the successors they select, so that later changes
+// to the execution state do not change the branch taken
+func recordEdgeSelection(state *domain.GraphState, nodeID string) error {
+	if !hasEdgeConditions(state.Graph, nodeID) {
+		return nil
+	}
+
+	next, err := selectEdges(state, nodeID)
+	if err != nil {
+		return err
+	}
+	setNodeMetadata(state.NodeStates[nodeID], MetadataNextNodes, next)
+	return nil
+}
@@ -0,0 +1,94 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+// conditionGraph routes a to hi when the score is high and to lo through
+// the given fallback edge
+func conditionGraph(fallback *graph.Edge) *domain.Graph {
+	edges := []*graph.Edge{
+		edge("s", "a"),
+		{From: "a", To: "hi", Condition: "output.score > 0.8"},
+		edge("hi", "e"),
+		edge("lo", "e2"),
+	}
+	edges = append(edges, fallback)
+	return newGraph("s",
+		[]graph.Node{
+			startNode("s"),
+			executorNode("a", nil),
+			executorNode("hi", nil),
+			executorNode("lo", nil),
+			endNode("e"),
+			endNode("e2"),
+		},
+		edges...,
+	)
+}
+
+func TestConditionalEdges(t *testing.T) {
+	fallback := &graph.Edge{From: "a", To: "lo", Metadata: map[string]interface{}{EdgeDefault: true}}
+	tests := []struct {
+		name     string
+		score    float64
+		fallback *graph.Edge
+		taken    string
+		untaken  string
+	}{
+		{"condition holds", 0.9, fallback, "hi", "lo"},
+		{"default edge", 0.5, fallback, "lo", "hi"},
+		{"conditional sibling", 0.5, &graph.Edge{From: "a", To: "lo", Condition: "output.score <= 0.8"}, "lo", "hi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, reply(map[string]interface{}{"score": tt.score}))
+			state := h.waitDone(t, h.submit(t, conditionGraph(tt.fallback), nil))
+			if state.Status != domain.ExecutionStatusCompleted {
+				t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+			}
+			if got := len(h.workFor(tt.taken)); got != 1 {
+				t.Errorf("%s dispatched %d times, want 1", tt.taken, got)
+			}
+			if got := len(h.workFor(tt.untaken)); got != 0 {
+				t.Errorf("%s dispatched %d times, want 0", tt.untaken, got)
+			}
+		})
+	}
+}
+
+func TestUnroutableOutputFailsNode(t *testing.T) {
+	h := newTestHarness(t, reply(map[string]interface{}{"score": 0.5}))
+	state := h.waitDone(t, h.submit(t, conditionGraph(&graph.Edge{From: "a", To: "lo", Condition: "output.score < 0.2"}), nil))
+	if state.Status != domain.ExecutionStatusFailed {
+		t.Fatalf("status = %s, want failed", state.Status)
+	}
+	assertNodeStatus(t, state, "a", domain.ExecutionStatusFailed)
+	if got := len(h.workFor("hi")); got != 0 {
+		t.Errorf("hi dispatched %d times, want 0", got)
+	}
+}
+
+func TestValidateEdgeCondition(t *testing.T) {
+	tests := []struct {
+		name    string
+		edge    *graph.Edge
+		wantErr bool
+	}{
+		{"plain", &graph.Edge{From: "a", To: "b"}, false},
+		{"condition", &graph.Edge{From: "a", To: "b", Condition: "output.ok == true"}, false},
+		{"default", &graph.Edge{From: "a", To: "b", Metadata: map[string]interface{}{EdgeDefault: true}}, false},
+		{"syntax error", &graph.Edge{From: "a", To: "b", Condition: "output.ok =="}, true},
+		{"default with condition", &graph.Edge{From: "a", To: "b", Condition: "true", Metadata: map[string]interface{}{EdgeDefault: true}}, true},
+		{"default not a boolean", &graph.Edge{From: "a", To: "b", Metadata: map[string]interface{}{EdgeDefault: "yes"}}, true},
+		{"loop with condition", &graph.Edge{From: "b", To: "a", Condition: "true", Metadata: map[string]interface{}{EdgeMaxIterations: 3}}, true},
+	}
+	for _, tt := range tests {
+		if err := validateEdgeCondition(tt.edge); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateEdgeCondition() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/adapters/storage"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// NodeTypeDelay is the type of nodes that hold back their branch for a
+// duration or until a timestamp, without a worker
+const NodeTypeDelay graph.NodeType = "delay"
+
+// Delay node settings: a duration ("10m" or seconds) or an RFC 3339 timestamp
+const (
+	ConfigDelayDuration = "duration"
+	ConfigDelayUntil    = "until"
+)
+
+// MetadataResumeAt records when a delay node's branch continues
+const MetadataResumeAt = "resume_at"
+
+// EventTypeNodeDelayed is published when a delay node starts waiting
+const EventTypeNodeDelayed domain.EventType = "node.delayed"
+
+// parseDelay reads when a delay node started at the given time resumes
+func parseDelay(cfg map[string]interface{}, startedAt time.Time) (time.Time, error) {
+	_, hasDuration := cfg[ConfigDelayDuration]
+	_, hasUntil := cfg[ConfigDelayUntil]
+	if hasDuration == hasUntil {
+		return time.Time{}, fmt.Errorf("delay nodes need exactly one of %s and %s", ConfigDelayDuration, ConfigDelayUntil)
+	}
+
+	if hasDuration {
+		d, ok := configDuration(cfg, ConfigDelayDuration)
+		if !ok || d < 0 {
+			return time.Time{}, fmt.Errorf("invalid %s: %v", ConfigDelayDuration, cfg[ConfigDelayDuration])
+		}
+		return startedAt.Add(d), nil
+	}
+
+	until, err := time.Parse(time.RFC3339, configString(cfg, ConfigDelayUntil))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %w", ConfigDelayUntil, err)
+	}
+	return until, nil
+}
+
+// startDelay holds back a delay node's branch until its resume time. Callers
+// must hold the execution lock.
+func (m *Manager) startDelay(ctx context.Context, graphID, nodeID string, state *executionState) error {
+	now := time.Now()
+	resumeAt, err := parseDelay(nodeConfig(state.Graph.GetNode(nodeID)), now)
+	if err != nil {
+		m.failNode(ctx, graphID, state, nodeID, err.Error(), "")
+		return nil
+	}
+
+	dispatchID := uuid.New().String()
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[nodeID]
+		nodeState.StartedAt = &now
+		nodeState.Status = domain.ExecutionStatusRunning
+		setNodeMetadata(nodeState, MetadataDispatchID, dispatchID)
+		setNodeMetadata(nodeState, MetadataResumeAt, resumeAt)
+	})
+	if err != nil {
+		m.logger.Error("failed to save state before delay",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+	}
+
+	if err := m.scheduleDelay(ctx, nodeTimer{GraphID: graphID, NodeID: nodeID, DispatchID: dispatchID}, resumeAt); err != nil {
+		m.failNode(ctx, graphID, state, nodeID, err.Error(), "")
+		return nil
+	}
+
+	m.logger.Info("delaying branch",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID),
+		zap.Time("resume_at", resumeAt))
+
+	// Publish delayed event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeDelayed, map[string]interface{}{
+		"node_id":   nodeID,
+		"resume_at": resumeAt,
+	})
+
+	return nil
+}
+
+// scheduleDelay arms the timer resuming a delay node: a durable timer when
+// the state storage keeps them, otherwise one in this process. Callers must
+// hold the execution lock.
+func (m *Manager) scheduleDelay(ctx context.Context, timer nodeTimer, resumeAt time.Time) error {
+	if _, ok := m.storage.(storage.TimerStorage); !ok {
+		m.armNodeTimer(timer, time.Until(resumeAt))
+		return nil
+	}
+	if err := m.scheduleNodeTimer(ctx, timer, resumeAt); err != nil {
+		return fmt.Errorf("failed to schedule delay: %w", err)
+	}
+	return nil
+}
+
+// resumeDelay completes the dispatch of a delay node its timer resumes. The
+// completion is dropped like any stale one if the node no longer waits.
+func (m *Manager) resumeDelay(ctx context.Context, timer nodeTimer) error {
+	return m.publishCompletion(ctx, timer.GraphID, map[string]interface{}{
+		"node_id":     timer.NodeID,
+		"dispatch_id": timer.DispatchID,
+	})
+}
+
+// reconcileDelay re-arms the timer of a delay node that was waiting before
+// the restart. Durable timers were never lost.
+func (m *Manager) reconcileDelay(ctx context.Context, graphID, nodeID string, nodeState *domain.NodeState) {
+	if _, ok := m.storage.(storage.TimerStorage); ok {
+		return
+	}
+	resumeAt, ok := metadataTime(nodeState.Metadata, MetadataResumeAt)
+	if !ok {
+		return
+	}
+	timer := nodeTimer{
+		GraphID:    graphID,
+		NodeID:     nodeID,
+		DispatchID: configString(nodeState.Metadata, MetadataDispatchID),
+	}
+	_ = m.scheduleDelay(ctx, timer, resumeAt)
+}
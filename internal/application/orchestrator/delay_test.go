@@ -0,0 +1,73 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/nodes"
+)
+
+func TestDelayNodeHoldsBackItsBranch(t *testing.T) {
+	h := newTestHarness(t, reply(map[string]interface{}{}))
+
+	g := newGraph("start",
+		[]graph.Node{
+			startNode("start"),
+			nodes.New("wait", NodeTypeDelay, map[string]interface{}{ConfigDelayDuration: "200ms"}),
+			executorNode("after", nil),
+			endNode("end"),
+		},
+		edge("start", "wait"), edge("wait", "after"), edge("after", "end"))
+	submitted := time.Now()
+	graphID := h.submit(t, g, nil)
+
+	state := h.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+
+	// No worker runs the delay, and its successor waits for it
+	if got := len(h.workFor("wait")); got != 0 {
+		t.Errorf("published %d work events for the delay node, want 0", got)
+	}
+	work := h.workFor("after")
+	if len(work) != 1 {
+		t.Fatalf("after dispatched %d times, want 1", len(work))
+	}
+	if waited := work[0].Timestamp.Sub(submitted); waited < 200*time.Millisecond {
+		t.Errorf("after dispatched %s after submission, want at least the delay", waited)
+	}
+	if got := len(h.eventsOf(EventTypeNodeDelayed)); got != 1 {
+		t.Errorf("published %d node.delayed events, want 1", got)
+	}
+}
+
+func TestParseDelay(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		cfg     map[string]interface{}
+		want    time.Time
+		wantErr bool
+	}{
+		{"duration", map[string]interface{}{ConfigDelayDuration: "10m"}, start.Add(10 * time.Minute), false},
+		{"seconds", map[string]interface{}{ConfigDelayDuration: 30}, start.Add(30 * time.Second), false},
+		{"until", map[string]interface{}{ConfigDelayUntil: "2025-01-02T00:00:00Z"}, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), false},
+		{"neither", map[string]interface{}{}, time.Time{}, true},
+		{"both", map[string]interface{}{ConfigDelayDuration: "1s", ConfigDelayUntil: "2025-01-02T00:00:00Z"}, time.Time{}, true},
+		{"negative", map[string]interface{}{ConfigDelayDuration: "-1s"}, time.Time{}, true},
+		{"bad timestamp", map[string]interface{}{ConfigDelayUntil: "tomorrow"}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDelay(tt.cfg, start)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: parseDelay() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: parseDelay() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,9 +2,20 @@
 //
 // The orchestrator manager coordinates graph execution by:
 //   - Validating graph structure and dependencies
-//   - Managing execution lifecycle (submit, monitor, cancel)
+//   - Checking inputs, node outputs and the final shared state against
+//     declared JSON Schemas
+//   - Managing execution lifecycle (submit, monitor, pause, resume, cancel)
+//   - Following edges, evaluating edge conditions without a router worker
+//   - Running subgraph nodes as linked child executions
+//   - Fanning map nodes out over lists, one work item per element
+//   - Parking human nodes until a reviewer approves, rejects or responds
+//   - Suspending signal nodes until an outside system posts to their token
+//   - Resuming delay nodes from durable timers kept by the state storage
+//   - Aggregating parallel branch outputs by vote, weighted score or reducer
+//   - Evaluating transform nodes in process with expressions and templates
 //   - Publishing events to the event bus
 //   - Tracking execution state via state storage
 //
-// The validator ensures graphs are well-formed with no cycles and valid dependencies.
+// The validator ensures graphs are well-formed with no cycles other than
+// declared bounded loops and valid dependencies.
 package orchestrator
@@ -0,0 +1,31 @@
+package orchestrator
+
+import (
+	"github.com/aescanero/dago-libs/pkg/domain"
+)
+
+// expressionEnv builds the environment graph expressions are evaluated in
+// on behalf of a node: its own output, the execution inputs, the shared
+// execution state, and the output, status and error of every node keyed by
+// node ID
+func expressionEnv(state *domain.GraphState, nodeID string) map[string]interface{} {
+	nodes := make(map[string]interface{}, len(state.NodeStates))
+	for id, nodeState := range state.NodeStates {
+		nodes[id] = map[string]interface{}{
+			"output":    nodeState.Output,
+			"status":    string(nodeState.Status),
+			"error":     nodeState.Error,
+			"iteration": nodeIteration(nodeState),
+		}
+	}
+
+	env := map[string]interface{}{
+		"inputs": state.Inputs,
+		"state":  SharedState(state),
+		"nodes":  nodes,
+	}
+	if nodeState := state.NodeStates[nodeID]; nodeState != nil {
+		env["output"] = nodeState.Output
+	}
+	return env
+}
@@ -0,0 +1,159 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"go.uber.org/zap"
+)
+
+// EdgeOnError is the edge metadata flag marking an edge followed only when
+// its source fails
+const EdgeOnError = "on_error"
+
+// ConfigErrorPolicy selects what happens when a node without on_error edges
+// fails for good
+const ConfigErrorPolicy = "error_policy"
+
+// MetadataErrorHandled records how a node failure was handled instead of
+// failing the graph
+const MetadataErrorHandled = "error_handled"
+
+// EventTypeNodeErrorHandled is published when a node failure is routed to
+// on_error edges or continued past
+const EventTypeNodeErrorHandled domain.EventType = "node.error_handled"
+
+// ErrorPolicy controls how a failed node affects its execution
+type ErrorPolicy string
+
+const (
+	// ErrorPolicyFail fails the graph (default)
+	ErrorPolicyFail ErrorPolicy = "fail"
+
+	// ErrorPolicyContinue records the failure and follows the node's
+	// outgoing edges as if it had completed without output
+	ErrorPolicyContinue ErrorPolicy = "continue"
+)
+
+// errorHandlingOnError marks a failure routed to the node's on_error edges
+const errorHandlingOnError = "on_error"
+
+// parseErrorPolicy reads the error policy from a node configuration
+func parseErrorPolicy(cfg map[string]interface{}) (ErrorPolicy, error) {
+	policy := ErrorPolicy(configString(cfg, ConfigErrorPolicy))
+	switch policy {
+	case "":
+		return ErrorPolicyFail, nil
+	case ErrorPolicyFail, ErrorPolicyContinue:
+		return policy, nil
+	}
+	return policy, fmt.Errorf("unknown %s: %s", ConfigErrorPolicy, policy)
+}
+
+// isErrorEdge reports whether an edge is only followed when its source fails
+func isErrorEdge(edge *graph.Edge) bool {
+	onError, _ := edge.Metadata[EdgeOnError].(bool)
+	return onError
+}
+
+// validateErrorEdge checks that an on_error flag is not combined with other
+// edge declarations
+func validateErrorEdge(edge *graph.Edge) error {
+	raw, ok := edge.Metadata[EdgeOnError]
+	if !ok {
+		return nil
+	}
+	if _, ok := raw.(bool); !ok {
+		return fmt.Errorf("%s must be a boolean", EdgeOnError)
+	}
+	if !isErrorEdge(edge) {
+		return nil
+	}
+
+	switch {
+	case edge.Condition != "":
+		return fmt.Errorf("%s edges cannot have a condition", EdgeOnError)
+	case isDefaultEdge(edge):
+		return fmt.Errorf("%s edges cannot be default edges", EdgeOnError)
+	case isLoopEdge(edge):
+		return fmt.Errorf("%s edges cannot be loop edges", EdgeOnError)
+	}
+	return nil
+}
+
+// errorTargets returns the targets of a node's on_error edges
+func errorTargets(g *domain.Graph, nodeID string) []string {
+	var targets []string
+	seen := make(map[string]bool)
+	for _, edge := range g.GetOutgoingEdges(nodeID) {
+		if isErrorEdge(edge) && !seen[edge.To] {
+			seen[edge.To] = true
+			targets = append(targets, edge.To)
+		}
+	}
+	return targets
+}
+
+// errorHandling returns how a failure of the node is handled: routed to its
+// on_error edges, continued past, or "" when it fails the graph
+func errorHandling(g *domain.Graph, nodeID string) string {
+	if len(errorTargets(g, nodeID)) > 0 {
+		return errorHandlingOnError
+	}
+
+	policy, err := parseErrorPolicy(nodeConfig(g.GetNode(nodeID)))
+	if err == nil && policy == ErrorPolicyContinue {
+		return string(ErrorPolicyContinue)
+	}
+	return ""
+}
+
+// errorRouted reports whether a failed node hands over to its on_error edges
+func errorRouted(nodeState *domain.NodeState) bool {
+	return nodeState.Status == domain.ExecutionStatusFailed &&
+		configString(nodeState.Metadata, MetadataErrorHandled) == errorHandlingOnError
+}
+
+// handledErrors collects the errors of failed nodes that route to a node
+// through on_error edges, keyed by the failed node's ID
+func handledErrors(state *domain.GraphState, nodeID string) map[string]interface{} {
+	failures := make(map[string]interface{})
+	for _, edge := range state.Graph.GetIncomingEdges(nodeID) {
+		if !isErrorEdge(edge) {
+			continue
+		}
+		if source := state.NodeStates[edge.From]; source != nil && errorRouted(source) {
+			failures[edge.From] = source.Error
+		}
+	}
+	return failures
+}
+
+// handleNodeError moves an execution past a failure that does not fail the
+// graph. Callers must hold the execution lock.
+func (m *Manager) handleNodeError(ctx context.Context, graphID string, state *executionState, nodeID, errorMsg, handling string) {
+	var next []string
+	for _, target := range m.nextNodes(ctx, graphID, state, nodeID) {
+		if targetState := state.NodeStates[target]; targetState != nil && targetState.Status == domain.ExecutionStatusPending {
+			next = append(next, target)
+		}
+	}
+
+	m.logger.Info("handling node failure",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID),
+		zap.String("handling", handling),
+		zap.Strings("next_nodes", next))
+
+	// Publish error handled event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeErrorHandled, map[string]interface{}{
+		"node_id":    nodeID,
+		"error":      errorMsg,
+		"handling":   handling,
+		"next_nodes": next,
+	})
+
+	m.dispatchNodes(ctx, graphID, state, next)
+}
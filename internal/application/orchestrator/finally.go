@@ -0,0 +1,173 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"go.uber.org/zap"
+)
+
+// GraphFinally is the graph metadata key naming the node that runs once the
+// execution completes, fails or is cancelled, before its final status is
+// recorded
+const GraphFinally = "finally"
+
+// Node state metadata keys of the finally node, holding the status the
+// execution ends with once the finally node has run
+const (
+	MetadataGraphStatus = "graph_status"
+	MetadataGraphError  = "graph_error"
+)
+
+// finallyNode returns the ID of the graph's finally node, or ""
+func finallyNode(g *domain.Graph) string {
+	return configString(g.Metadata, GraphFinally)
+}
+
+// validateFinally checks that the finally node is an executor outside the
+// edges of the graph
+func validateFinally(g *domain.Graph) error {
+	raw, ok := g.Metadata[GraphFinally]
+	if !ok {
+		return nil
+	}
+	nodeID, ok := raw.(string)
+	if !ok || nodeID == "" {
+		return fmt.Errorf("%s must name a node", GraphFinally)
+	}
+
+	node, exists := g.Nodes[nodeID]
+	if !exists {
+		return fmt.Errorf("%s node %s not found in graph", GraphFinally, nodeID)
+	}
+	if node.GetType() != graph.NodeTypeExecutor {
+		return fmt.Errorf("%s node %s must be an executor", GraphFinally, nodeID)
+	}
+	if nodeID == g.EntryNode {
+		return fmt.Errorf("%s node %s cannot be the entry node", GraphFinally, nodeID)
+	}
+
+	if hasEdges(g, nodeID) {
+		return fmt.Errorf("%s node %s cannot have edges", GraphFinally, nodeID)
+	}
+	return nil
+}
+
+// finalizing reports whether the execution reached its outcome and only
+// waits for the finally node
+func finalizing(state *domain.GraphState) bool {
+	finallyID := finallyNode(state.Graph)
+	if finallyID == "" {
+		return false
+	}
+	nodeState := state.NodeStates[finallyID]
+	if nodeState == nil {
+		return false
+	}
+	_, ok := nodeState.Metadata[MetadataGraphStatus]
+	return ok
+}
+
+// finallyOutcome returns the status an execution ends with once its finally
+// node finished: the status recorded when the finally node was started, or
+// failed if the finally node itself failed
+func finallyOutcome(state *domain.GraphState) (domain.ExecutionStatus, string, bool) {
+	if !finalizing(state) {
+		return "", "", false
+	}
+
+	nodeState := state.NodeStates[finallyNode(state.Graph)]
+	switch nodeState.Status {
+	case domain.ExecutionStatusCompleted:
+		status := domain.ExecutionStatus(configString(nodeState.Metadata, MetadataGraphStatus))
+		return status, configString(nodeState.Metadata, MetadataGraphError), true
+	case domain.ExecutionStatusFailed:
+		return domain.ExecutionStatusFailed, nodeState.Error, true
+	}
+	return "", "", false
+}
+
+// runFinally starts the finally node in place of ending the execution with
+// the given status. It reports whether the execution must wait for the
+// finally node. Callers must hold the execution lock.
+func (m *Manager) runFinally(ctx context.Context, graphID string, state *executionState, status domain.ExecutionStatus, errorMsg string) bool {
+	finallyID := finallyNode(state.Graph)
+	if finallyID == "" || !executionActive(state.Status) {
+		return false
+	}
+	nodeState := state.NodeStates[finallyID]
+	if nodeState == nil {
+		return false
+	}
+
+	// The outcome was reached before: wait for the finally node unless it
+	// already finished
+	if finalizing(state.GraphState) {
+		_, _, finished := finallyOutcome(state.GraphState)
+		return !finished
+	}
+
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[finallyID]
+		setNodeMetadata(nodeState, MetadataGraphStatus, string(status))
+		// Human and signal nodes still waiting are abandoned, and a pause ends
+		if awaiting(s.Status) || s.Status == ExecutionStatusPaused {
+			s.Status = domain.ExecutionStatusRunning
+		}
+		if errorMsg != "" {
+			setNodeMetadata(nodeState, MetadataGraphError, errorMsg)
+		}
+	})
+	if err != nil {
+		m.logger.Error("failed to save state before finally node",
+			zap.String("graph_id", graphID),
+			zap.Error(err))
+		return false
+	}
+
+	// Branches still in flight are abandoned
+	if val, ok := m.executions.Load(graphID); ok {
+		stopNodeTimers(val.(*executionContext))
+	}
+
+	m.logger.Info("running finally node",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", finallyID),
+		zap.String("status", string(status)))
+
+	if err := m.publishNodeWork(ctx, graphID, finallyID, state); err != nil {
+		m.logger.Error("failed to publish finally node work",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", finallyID),
+			zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// dispatchFinally dispatches the finally node of a finalizing execution and
+// ends the execution once the finally node finished. Callers must hold the
+// execution lock.
+func (m *Manager) dispatchFinally(ctx context.Context, graphID string, state *executionState, nodeIDs []string) {
+	finallyID := finallyNode(state.Graph)
+	for _, nodeID := range nodeIDs {
+		if nodeID != finallyID {
+			continue
+		}
+		if err := m.publishNodeWork(ctx, graphID, nodeID, state); err != nil {
+			m.logger.Error("failed to publish finally node work",
+				zap.String("graph_id", graphID),
+				zap.String("node_id", nodeID),
+				zap.Error(err))
+		}
+	}
+
+	if !executionActive(state.Status) {
+		return
+	}
+	if status, errorMsg, ok := finallyOutcome(state.GraphState); ok {
+		m.completeGraph(ctx, graphID, state, status, errorMsg)
+	}
+}
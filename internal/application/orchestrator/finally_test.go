@@ -0,0 +1,64 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+// finallyGraph runs a then the end node, with f as its finally node
+func finallyGraph() *domain.Graph {
+	g := newGraph("s",
+		[]graph.Node{startNode("s"), executorNode("a", nil), endNode("e"), executorNode("f", nil)},
+		edge("s", "a"),
+		edge("a", "e"),
+	)
+	g.Metadata = map[string]interface{}{GraphFinally: "f"}
+	return g
+}
+
+func TestFinallyRunsAfterCompletion(t *testing.T) {
+	h := newTestHarness(t, reply("done"))
+	state := h.waitDone(t, h.submit(t, finallyGraph(), nil))
+
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	assertNodeStatus(t, state, "f", domain.ExecutionStatusCompleted)
+	if got := state.NodeStates["f"].Metadata[MetadataGraphStatus]; got != string(domain.ExecutionStatusCompleted) {
+		t.Errorf("graph_status = %v, want completed", got)
+	}
+}
+
+func TestCancelRunsFinallyNode(t *testing.T) {
+	// a never reports back; only the finally node does
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] == "f" {
+			return map[string]interface{}{"output": "cleaned up"}
+		}
+		return nil
+	})
+	graphID := h.submit(t, finallyGraph(), nil)
+	h.waitNode(t, graphID, "a", domain.ExecutionStatusRunning)
+
+	if err := h.manager.CancelExecution(context.Background(), graphID); err != nil {
+		t.Fatalf("CancelExecution() error = %v", err)
+	}
+	state := h.waitDone(t, graphID)
+
+	if state.Status != domain.ExecutionStatusCancelled {
+		t.Fatalf("status = %s (%s), want cancelled", state.Status, state.Error)
+	}
+	assertNodeStatus(t, state, "f", domain.ExecutionStatusCompleted)
+	if got := state.NodeStates["f"].Metadata[MetadataGraphStatus]; got != string(domain.ExecutionStatusCancelled) {
+		t.Errorf("graph_status = %v, want cancelled", got)
+	}
+	h.waitFor(t, graphID, func(*domain.GraphState) bool {
+		return len(h.eventsOf(domain.EventTypeGraphCancelled)) == 1
+	})
+	if got := len(h.eventsOf(domain.EventTypeGraphCompleted)); got != 0 {
+		t.Errorf("published %d graph.completed events for a cancelled execution", got)
+	}
+}
@@ -0,0 +1,254 @@
+package orchestrator
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago-libs/pkg/ports"
+	evmem "github.com/aescanero/dago/pkg/adapters/events/memory"
+	"github.com/aescanero/dago/pkg/adapters/metrics/prometheus"
+	stmem "github.com/aescanero/dago/pkg/adapters/storage/memory"
+	"github.com/aescanero/dago/pkg/nodes"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// The collector registers its metrics globally, so tests share one
+var (
+	testCollectorOnce sync.Once
+	testCollector     *prometheus.Collector
+)
+
+// workerFunc plays a worker: it receives the data of a work event and
+// returns the fields of the node.completed event to report, or nil to
+// report nothing
+type workerFunc func(data map[string]interface{}) map[string]interface{}
+
+// testHarness runs a manager on the in-memory event bus and storage, with
+// a worker answering executor and router work
+type testHarness struct {
+	manager *Manager
+	bus     *evmem.InMemoryEventBus
+	store   *stmem.InMemoryStateStorage
+
+	mu     sync.Mutex
+	events []ports.Event // graph events, in publication order
+	work   []ports.Event // work events, in publication order
+}
+
+// newTestHarness starts a manager whose workers answer with worker
+func newTestHarness(t *testing.T, worker workerFunc) *testHarness {
+	t.Helper()
+	return newTestHarnessWithStore(t, stmem.NewInMemoryStateStorage(), worker)
+}
+
+// newTestHarnessWithStore starts a manager on a given storage, such as one
+// shared with another harness playing a second replica
+func newTestHarnessWithStore(t *testing.T, store *stmem.InMemoryStateStorage, worker workerFunc) *testHarness {
+	t.Helper()
+	testCollectorOnce.Do(func() { testCollector = prometheus.NewCollector() })
+
+	h := &testHarness{
+		bus:   evmem.NewInMemoryEventBus(),
+		store: store,
+	}
+	h.manager = NewManager(h.bus, h.store, testCollector, NewValidator(), zap.NewNop(), 10*time.Second, 5*time.Second)
+
+	ctx := context.Background()
+	answer := func(ctx context.Context, event ports.Event) error {
+		h.mu.Lock()
+		h.work = append(h.work, event)
+		h.mu.Unlock()
+
+		reply := worker(event.Data)
+		if reply == nil {
+			return nil
+		}
+		data := map[string]interface{}{
+			"node_id":     event.Data["node_id"],
+			"dispatch_id": event.Data["dispatch_id"],
+		}
+		for key, value := range reply {
+			data[key] = value
+		}
+		for _, key := range []string{"map_node", "item_index"} {
+			if value, ok := event.Data[key]; ok {
+				data[key] = value
+			}
+		}
+		return h.bus.Publish(ctx, TopicNodeCompleted, ports.Event{
+			ID:          uuid.New().String(),
+			Type:        ports.EventType(domain.EventTypeNodeCompleted),
+			Timestamp:   time.Now(),
+			ExecutionID: event.ExecutionID,
+			Data:        data,
+		})
+	}
+	for _, topic := range []string{TopicExecutorWork, TopicRouterWork} {
+		if err := h.bus.Subscribe(ctx, topic, answer); err != nil {
+			t.Fatalf("failed to subscribe worker: %v", err)
+		}
+	}
+	err := h.bus.Subscribe(ctx, TopicGraphEvents, func(ctx context.Context, event ports.Event) error {
+		h.mu.Lock()
+		h.events = append(h.events, event)
+		h.mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe to graph events: %v", err)
+	}
+
+	if err := h.manager.Start(); err != nil {
+		t.Fatalf("failed to start manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = h.manager.Shutdown(context.Background())
+		_ = h.bus.Close()
+	})
+	return h
+}
+
+// submit submits a graph and fails the test if it is rejected
+func (h *testHarness) submit(t *testing.T, g *domain.Graph, inputs map[string]interface{}) string {
+	t.Helper()
+	graphID, err := h.manager.SubmitGraph(context.Background(), g, inputs)
+	if err != nil {
+		t.Fatalf("SubmitGraph() error = %v", err)
+	}
+	return graphID
+}
+
+// waitFor polls an execution until done accepts its state
+func (h *testHarness) waitFor(t *testing.T, graphID string, done func(*domain.GraphState) bool) *domain.GraphState {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		state, err := h.manager.GetStatus(context.Background(), graphID)
+		if err == nil && done(state) {
+			return state
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				t.Fatalf("GetStatus() error = %v", err)
+			}
+			t.Fatalf("timed out waiting for execution %s; status %s, error %q", graphID, state.Status, state.Error)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitDone waits until an execution has finished
+func (h *testHarness) waitDone(t *testing.T, graphID string) *domain.GraphState {
+	t.Helper()
+	return h.waitFor(t, graphID, func(state *domain.GraphState) bool {
+		return !executionActive(state.Status)
+	})
+}
+
+// waitNode waits until a node of an execution has a status
+func (h *testHarness) waitNode(t *testing.T, graphID, nodeID string, status domain.ExecutionStatus) *domain.GraphState {
+	t.Helper()
+	return h.waitFor(t, graphID, func(state *domain.GraphState) bool {
+		nodeState := state.NodeStates[nodeID]
+		return nodeState != nil && nodeState.Status == status
+	})
+}
+
+// eventsOf returns the graph events of a type published so far
+func (h *testHarness) eventsOf(eventType domain.EventType) []ports.Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var events []ports.Event
+	for _, event := range h.events {
+		if event.Type == ports.EventType(eventType) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// workFor returns the work events published for a node so far
+func (h *testHarness) workFor(nodeID string) []ports.Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var work []ports.Event
+	for _, event := range h.work {
+		if event.Data["node_id"] == nodeID {
+			work = append(work, event)
+		}
+	}
+	return work
+}
+
+// dispatchOrder returns the nodes work was published for, in order
+func (h *testHarness) dispatchOrder() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var order []string
+	for _, event := range h.work {
+		order = append(order, event.Data["node_id"].(string))
+	}
+	return order
+}
+
+// reply returns a worker that reports the same output for every node
+func reply(output interface{}) workerFunc {
+	return func(map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"output": output}
+	}
+}
+
+// newGraph builds a graph from its entry node, nodes and edges
+func newGraph(entry string, graphNodes []graph.Node, edges ...*graph.Edge) *domain.Graph {
+	g := &domain.Graph{
+		ID:        "test-graph",
+		Version:   "1",
+		EntryNode: entry,
+		Nodes:     make(map[string]graph.Node, len(graphNodes)),
+		Edges:     edges,
+	}
+	for _, node := range graphNodes {
+		g.Nodes[node.GetID()] = node
+	}
+	return g
+}
+
+// startNode, endNode and executorNode build nodes of each type
+func startNode(id string) graph.Node { return nodes.New(id, graph.NodeTypeStart, nil) }
+func endNode(id string) graph.Node   { return nodes.New(id, graph.NodeTypeEnd, nil) }
+func executorNode(id string, cfg map[string]interface{}) graph.Node {
+	return nodes.New(id, graph.NodeTypeExecutor, cfg)
+}
+
+// edge builds an unconditional edge
+func edge(from, to string) *graph.Edge {
+	return &graph.Edge{From: from, To: to}
+}
+
+// assertNodeStatus checks the status of a node
+func assertNodeStatus(t *testing.T, state *domain.GraphState, nodeID string, want domain.ExecutionStatus) {
+	t.Helper()
+	nodeState := state.NodeStates[nodeID]
+	if nodeState == nil {
+		t.Fatalf("node %s has no state", nodeID)
+	}
+	if nodeState.Status != want {
+		t.Errorf("node %s status = %s, want %s", nodeID, nodeState.Status, want)
+	}
+}
+
+// lastErrorClass returns the error class of a node's last failed attempt
+func lastErrorClass(state *domain.GraphState, nodeID string) string {
+	attemptErrors, _ := state.NodeStates[nodeID].Metadata[MetadataAttemptErrors].([]interface{})
+	if len(attemptErrors) == 0 {
+		return ""
+	}
+	last, _ := attemptErrors[len(attemptErrors)-1].(map[string]interface{})
+	class, _ := last["error_class"].(string)
+	return class
+}
@@ -0,0 +1,322 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// NodeTypeHuman is the type of nodes that wait for a reviewer instead of a
+// worker
+const NodeTypeHuman graph.NodeType = "human"
+
+// ExecutionStatusAwaitingInput is the status of an execution while one of
+// its human nodes waits for a response
+const ExecutionStatusAwaitingInput domain.ExecutionStatus = "awaiting_input"
+
+// Human node settings
+const (
+	// ConfigPrompt is shown to reviewers in the awaiting input event
+	ConfigPrompt = "prompt"
+
+	// ConfigDefaultAction is taken when the node's timeout expires
+	ConfigDefaultAction = "default_action"
+)
+
+// Node state metadata keys of human nodes
+const (
+	// MetadataAwaitingInput marks a human node waiting for a response
+	MetadataAwaitingInput = "awaiting_input"
+
+	// MetadataHumanResponse records the response a human node received
+	MetadataHumanResponse = "response"
+)
+
+// ErrorClassRejected is the error class of a rejected human node
+const ErrorClassRejected = "rejected"
+
+// ErrNotHumanNode is returned for responses to nodes that are not human nodes
+var ErrNotHumanNode = errors.New("not a human node")
+
+// Graph events of human nodes
+const (
+	EventTypeNodeAwaitingInput domain.EventType = "node.awaiting_input"
+	EventTypeNodeResponded     domain.EventType = "node.responded"
+)
+
+// HumanAction is a response to a human node
+type HumanAction string
+
+const (
+	// HumanApprove completes the node with the payload, or with
+	// {"approved": true} without one
+	HumanApprove HumanAction = "approve"
+
+	// HumanReject fails the node with error class rejected
+	HumanReject HumanAction = "reject"
+
+	// HumanRespond completes the node with the payload
+	HumanRespond HumanAction = "respond"
+)
+
+// parseDefaultAction reads the action a human node takes when its timeout
+// expires
+func parseDefaultAction(cfg map[string]interface{}) (HumanAction, error) {
+	action := HumanAction(configString(cfg, ConfigDefaultAction))
+	switch action {
+	case "":
+		return HumanReject, nil
+	case HumanApprove, HumanReject:
+		return action, nil
+	}
+	return action, fmt.Errorf("unknown %s: %s", ConfigDefaultAction, action)
+}
+
+// awaitingInput reports whether a node waits for a response
+func awaitingInput(nodeState *domain.NodeState) bool {
+	waiting, _ := nodeState.Metadata[MetadataAwaitingInput].(bool)
+	return waiting && nodeState.Status == domain.ExecutionStatusRunning
+}
+
+// refreshAwaitingStatus moves a running execution to awaiting_input while
+// any of its human nodes waits for a response, to awaiting_signal while only
+// signal nodes wait for their callbacks, and back once none waits
+func refreshAwaitingStatus(state *domain.GraphState) {
+	if !awaiting(state.Status) && state.Status != domain.ExecutionStatusRunning {
+		return
+	}
+	state.Status = domain.ExecutionStatusRunning
+	for _, nodeState := range state.NodeStates {
+		if awaitingInput(nodeState) {
+			state.Status = ExecutionStatusAwaitingInput
+			return
+		}
+		if awaitingSignal(nodeState) {
+			state.Status = ExecutionStatusAwaitingSignal
+		}
+	}
+}
+
+// awaiting reports whether an execution waits for a reviewer or a signal
+func awaiting(status domain.ExecutionStatus) bool {
+	return status == ExecutionStatusAwaitingInput || status == ExecutionStatusAwaitingSignal
+}
+
+// awaitInput parks a human node until a reviewer responds. Callers must
+// hold the execution lock.
+func (m *Manager) awaitInput(ctx context.Context, graphID, nodeID string, state *executionState) error {
+	node := state.Graph.GetNode(nodeID)
+	cfg := nodeConfig(node)
+
+	// Reviewers see the state the node selects
+	inputs, err := nodeInputs(node, SharedState(state.GraphState))
+	if err != nil {
+		m.failNode(ctx, graphID, state, nodeID, err.Error(), "")
+		return nil
+	}
+
+	now := time.Now()
+	dispatchID := uuid.New().String()
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[nodeID]
+		nodeState.StartedAt = &now
+		nodeState.Status = domain.ExecutionStatusRunning
+		setNodeMetadata(nodeState, MetadataDispatchID, dispatchID)
+		setNodeMetadata(nodeState, MetadataAwaitingInput, true)
+		delete(nodeState.Metadata, MetadataHumanResponse)
+		refreshAwaitingStatus(s)
+	})
+	if err != nil {
+		m.logger.Error("failed to save state before awaiting input",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+	}
+
+	// Human nodes only have a deadline when they set a timeout
+	timeout := m.nodeTimeoutFor(node)
+	m.armNodeTimeout(graphID, nodeID, now, timeout)
+
+	m.logger.Info("awaiting input",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID))
+
+	data := map[string]interface{}{
+		"node_id": nodeID,
+		"state":   inputs,
+		"actions": []string{string(HumanApprove), string(HumanReject), string(HumanRespond)},
+	}
+	if prompt := configString(cfg, ConfigPrompt); prompt != "" {
+		data["prompt"] = prompt
+	}
+	if timeout > 0 {
+		action, _ := parseDefaultAction(cfg)
+		data["deadline"] = now.Add(timeout)
+		data["default_action"] = string(action)
+	}
+
+	// Publish awaiting input event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeAwaitingInput, data)
+
+	return nil
+}
+
+// RespondToNode answers a human node waiting for input. The payload becomes
+// the node's output when it is approved or responded to.
+func (m *Manager) RespondToNode(ctx context.Context, graphID, nodeID string, action HumanAction, payload map[string]interface{}) error {
+	switch action {
+	case HumanApprove, HumanReject:
+	case HumanRespond:
+		if payload == nil {
+			return fmt.Errorf("a response payload is required")
+		}
+	default:
+		return fmt.Errorf("unknown action: %s", action)
+	}
+
+	// Any replica may take the answer, not only the one looking after the
+	// execution: it is recorded in the stored state and the node completes
+	// through the event bus
+	unlock := m.lockExecution(graphID)
+	defer unlock()
+
+	state, err := m.loadState(ctx, graphID)
+	if err != nil {
+		return err
+	}
+	if !executionActive(state.Status) {
+		return fmt.Errorf("execution already in terminal state: %s", state.Status)
+	}
+
+	// Only reviewers answer through here; signal nodes wait for their token
+	if node := state.Graph.GetNode(nodeID); node == nil || node.GetType() != NodeTypeHuman {
+		return fmt.Errorf("node %s: %w", nodeID, ErrNotHumanNode)
+	}
+
+	nodeState := state.NodeStates[nodeID]
+	if nodeState == nil || !awaitingInput(nodeState) || abandonedCompletion(state.GraphState, nodeID) != "" {
+		return fmt.Errorf("node %s is not awaiting input", nodeID)
+	}
+
+	return m.respond(ctx, graphID, state, nodeID, action, payload, false)
+}
+
+// respond records the response of a human node and reports the node back
+// the way a worker reports a node. Callers must hold the execution lock.
+func (m *Manager) respond(ctx context.Context, graphID string, state *executionState, nodeID string, action HumanAction, payload map[string]interface{}, byDefault bool) error {
+	now := time.Now()
+	response := map[string]interface{}{
+		"action":       string(action),
+		"responded_at": now,
+	}
+	if payload != nil {
+		response["payload"] = payload
+	}
+	if byDefault {
+		response["default"] = true
+	}
+
+	answered := false
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		// Another replica may have answered the node concurrently
+		nodeState := s.NodeStates[nodeID]
+		answered = awaitingInput(nodeState)
+		if !answered {
+			return
+		}
+		delete(nodeState.Metadata, MetadataAwaitingInput)
+		setNodeMetadata(nodeState, MetadataHumanResponse, response)
+		refreshAwaitingStatus(s)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if !answered {
+		return fmt.Errorf("node %s is not awaiting input", nodeID)
+	}
+
+	m.stopNodeTimeout(graphID, nodeID)
+
+	m.logger.Info("node responded to",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID),
+		zap.String("action", string(action)),
+		zap.Bool("default", byDefault))
+
+	// Publish responded event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeResponded, map[string]interface{}{
+		"node_id": nodeID,
+		"action":  string(action),
+		"default": byDefault,
+	})
+
+	data := map[string]interface{}{
+		"node_id":     nodeID,
+		"dispatch_id": configString(state.NodeStates[nodeID].Metadata, MetadataDispatchID),
+	}
+	switch {
+	case action == HumanReject:
+		reason, _ := payload["reason"].(string)
+		if reason == "" {
+			reason = "no reason given"
+		}
+		data["error"] = fmt.Sprintf("rejected: %s", reason)
+		data["error_class"] = ErrorClassRejected
+	case payload != nil:
+		data["output"] = payload
+	default:
+		data["output"] = map[string]interface{}{"approved": true}
+	}
+
+	return m.publishCompletion(ctx, graphID, data)
+}
+
+// publishCompletion reports a node the orchestrator completed on its own
+// through node.completed, the way a worker reports a node
+func (m *Manager) publishCompletion(ctx context.Context, graphID string, data map[string]interface{}) error {
+	event := ports.Event{
+		ID:          uuid.New().String(),
+		Type:        ports.EventType(domain.EventTypeNodeCompleted),
+		Timestamp:   time.Now(),
+		ExecutionID: graphID,
+		Data:        data,
+	}
+	if err := m.eventBus.Publish(ctx, TopicNodeCompleted, event); err != nil {
+		return fmt.Errorf("failed to publish node completion: %w", err)
+	}
+	return nil
+}
+
+// expireInput takes the default action of a human node whose timeout
+// expired. Callers must hold the execution lock.
+func (m *Manager) expireInput(ctx context.Context, graphID string, state *executionState, nodeID string, timeout time.Duration) {
+	action, err := parseDefaultAction(nodeConfig(state.Graph.GetNode(nodeID)))
+	if err != nil {
+		// Rejected by the validator; reject rather than guess
+		action = HumanReject
+	}
+
+	m.logger.Warn("input deadline expired",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID),
+		zap.Duration("timeout", timeout),
+		zap.String("default_action", string(action)))
+
+	var payload map[string]interface{}
+	if action == HumanReject {
+		payload = map[string]interface{}{"reason": fmt.Sprintf("no response within %s", timeout)}
+	}
+	if err := m.respond(ctx, graphID, state, nodeID, action, payload, true); err != nil {
+		m.logger.Error("failed to take default action",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,139 @@
+package orchestrator
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/nodes"
+)
+
+// reviewGraph runs a human node, review, between start and end
+func reviewGraph(reviewCfg map[string]interface{}) *domain.Graph {
+	return newGraph("start",
+		[]graph.Node{startNode("start"), nodes.New("review", NodeTypeHuman, reviewCfg), endNode("end")},
+		edge("start", "review"), edge("review", "end"))
+}
+
+// awaitReview submits reviewGraph and waits until review awaits input
+func awaitReview(t *testing.T, h *testHarness, reviewCfg map[string]interface{}) string {
+	t.Helper()
+	graphID := h.submit(t, reviewGraph(reviewCfg), nil)
+	h.waitFor(t, graphID, func(state *domain.GraphState) bool {
+		return state.Status == ExecutionStatusAwaitingInput
+	})
+	return graphID
+}
+
+func TestHumanNodeResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     HumanAction
+		payload    map[string]interface{}
+		wantStatus domain.ExecutionStatus
+		wantOutput interface{}
+	}{
+		{"approve", HumanApprove, nil, domain.ExecutionStatusCompleted, map[string]interface{}{"approved": true}},
+		{"approve with body", HumanApprove, map[string]interface{}{"edits": "none"}, domain.ExecutionStatusCompleted, map[string]interface{}{"edits": "none"}},
+		{"respond", HumanRespond, map[string]interface{}{"answer": 42.0}, domain.ExecutionStatusCompleted, map[string]interface{}{"answer": 42.0}},
+		{"reject", HumanReject, map[string]interface{}{"reason": "too informal"}, domain.ExecutionStatusFailed, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, reply(map[string]interface{}{}))
+			graphID := awaitReview(t, h, map[string]interface{}{ConfigPrompt: "Publish?"})
+
+			if err := h.manager.RespondToNode(context.Background(), graphID, "review", tt.action, tt.payload); err != nil {
+				t.Fatalf("RespondToNode() error = %v", err)
+			}
+			state := h.waitDone(t, graphID)
+			if state.Status != tt.wantStatus {
+				t.Fatalf("status = %s (%s), want %s", state.Status, state.Error, tt.wantStatus)
+			}
+
+			review := state.NodeStates["review"]
+			if tt.wantStatus == domain.ExecutionStatusFailed {
+				if !strings.Contains(review.Error, "too informal") {
+					t.Errorf("review error = %q, want the rejection reason", review.Error)
+				}
+			} else if !reflect.DeepEqual(review.Output, tt.wantOutput) {
+				t.Errorf("review output = %v, want %v", review.Output, tt.wantOutput)
+			}
+
+			// Reviewers are not workers
+			if got := len(h.workFor("review")); got != 0 {
+				t.Errorf("published %d work events for the human node, want 0", got)
+			}
+			events := h.eventsOf(EventTypeNodeAwaitingInput)
+			if len(events) != 1 || events[0].Data["prompt"] != "Publish?" {
+				t.Errorf("node.awaiting_input events = %v, want one with the prompt", events)
+			}
+		})
+	}
+}
+
+func TestHumanNodeRejectsInvalidResponses(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t, reply(map[string]interface{}{}))
+	graphID := awaitReview(t, h, nil)
+
+	if err := h.manager.RespondToNode(ctx, graphID, "review", HumanRespond, nil); err == nil {
+		t.Error("RespondToNode(respond) without a payload succeeded")
+	}
+	if err := h.manager.RespondToNode(ctx, graphID, "review", HumanAction("maybe"), nil); err == nil {
+		t.Error("RespondToNode() with an unknown action succeeded")
+	}
+	if err := h.manager.RespondToNode(ctx, graphID, "review", HumanApprove, nil); err != nil {
+		t.Fatalf("RespondToNode() error = %v", err)
+	}
+	h.waitDone(t, graphID)
+	if err := h.manager.RespondToNode(ctx, graphID, "review", HumanApprove, nil); err == nil {
+		t.Error("second RespondToNode() succeeded")
+	}
+}
+
+func TestHumanNodeTakesDefaultActionOnTimeout(t *testing.T) {
+	h := newTestHarness(t, reply(map[string]interface{}{}))
+	graphID := h.submit(t, reviewGraph(map[string]interface{}{
+		ConfigTimeout:       "50ms",
+		ConfigDefaultAction: string(HumanApprove),
+	}), nil)
+
+	state := h.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	response, _ := state.NodeStates["review"].Metadata[MetadataHumanResponse].(map[string]interface{})
+	if response["action"] != string(HumanApprove) || response["default"] != true {
+		t.Errorf("recorded response = %v, want a default approval", response)
+	}
+}
+
+func TestResponseReachingAnotherReplica(t *testing.T) {
+	ctx := context.Background()
+	owner := newTestHarness(t, reply(map[string]interface{}{}))
+	replica := newTestHarnessWithStore(t, owner.store, reply(map[string]interface{}{}))
+
+	graphID := awaitReview(t, owner, map[string]interface{}{ConfigTimeout: "5s"})
+
+	// The reviewer's request lands on a replica that does not look after
+	// the execution
+	payload := map[string]interface{}{"answer": "ship it"}
+	if err := replica.manager.RespondToNode(ctx, graphID, "review", HumanRespond, payload); err != nil {
+		t.Fatalf("RespondToNode() on another replica error = %v", err)
+	}
+	state := owner.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	if got := state.NodeStates["review"].Output; !reflect.DeepEqual(got, payload) {
+		t.Errorf("review output = %v, want %v", got, payload)
+	}
+	if err := owner.manager.RespondToNode(ctx, graphID, "review", HumanApprove, nil); err == nil {
+		t.Error("RespondToNode() on an answered node succeeded")
+	}
+}
@@ -0,0 +1,123 @@
+package orchestrator
+
+import (
+	"fmt"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/internal/schema"
+)
+
+// Graph metadata keys holding JSON Schemas of an execution's inputs and of
+// its shared state once it completes
+const (
+	GraphInputSchema  = "input_schema"
+	GraphOutputSchema = "output_schema"
+)
+
+// ConfigOutputSchema is the node setting holding a JSON Schema the node's
+// output must match
+const ConfigOutputSchema = "output_schema"
+
+// graphSchema compiles the schema under a graph metadata key, or returns nil
+// if the graph declares none
+func graphSchema(g *domain.Graph, key string) (*schema.Schema, error) {
+	return compileSchema(g.Metadata, key)
+}
+
+// nodeOutputSchema compiles a node's output schema, or returns nil if the
+// node declares none
+func nodeOutputSchema(node graph.Node) (*schema.Schema, error) {
+	return compileSchema(nodeConfig(node), ConfigOutputSchema)
+}
+
+// compileSchema compiles the schema under a setting, or returns nil if it
+// is not set
+func compileSchema(cfg map[string]interface{}, key string) (*schema.Schema, error) {
+	raw, ok := cfg[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	compiled, err := schema.Compile(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return compiled, nil
+}
+
+// validateSchemas checks that the schemas a graph declares compile
+func validateSchemas(g *domain.Graph) error {
+	for _, key := range []string{GraphInputSchema, GraphOutputSchema} {
+		if _, err := graphSchema(g, key); err != nil {
+			return &ValidationError{
+				Code:    ValidationCodeInvalidSchema,
+				Message: err.Error(),
+				Details: map[string]interface{}{"schema": key},
+			}
+		}
+	}
+	return nil
+}
+
+// checkInputs validates the inputs of a submission against the graph's
+// input schema. Each field that failed is listed in the error details.
+func checkInputs(g *domain.Graph, inputs map[string]interface{}) error {
+	inputSchema, err := graphSchema(g, GraphInputSchema)
+	if err != nil || inputSchema == nil {
+		return err
+	}
+
+	// Submissions without inputs are checked as an empty object
+	var value interface{} = inputs
+	if inputs == nil {
+		value = map[string]interface{}{}
+	}
+	failures, err := inputSchema.Validate(value)
+	if err != nil {
+		return fmt.Errorf("failed to validate inputs: %w", err)
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return &ValidationError{
+		Code:    ValidationCodeInputs,
+		Message: fmt.Sprintf("inputs do not match the input schema: %s", schema.Describe(failures)),
+		Details: map[string]interface{}{"errors": failures},
+	}
+}
+
+// checkOutput validates the shared state of a completed execution against
+// the graph's output schema
+func checkOutput(state *domain.GraphState) error {
+	outputSchema, err := graphSchema(state.Graph, GraphOutputSchema)
+	if err != nil || outputSchema == nil {
+		return err
+	}
+
+	failures, err := outputSchema.Validate(SharedState(state))
+	if err != nil {
+		return fmt.Errorf("failed to validate output: %w", err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("output does not match the output schema: %s", schema.Describe(failures))
+	}
+	return nil
+}
+
+// checkNodeOutput validates the output a node reported against its output
+// schema
+func checkNodeOutput(node graph.Node, output interface{}) error {
+	outputSchema, err := nodeOutputSchema(node)
+	if err != nil || outputSchema == nil {
+		return err
+	}
+
+	failures, err := outputSchema.Validate(output)
+	if err != nil {
+		return fmt.Errorf("failed to validate output: %w", err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("output does not match the node's output schema: %s", schema.Describe(failures))
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/internal/schema"
+)
+
+// schemaGraph runs one executor, a, under the given graph metadata
+func schemaGraph(metadata map[string]interface{}) *domain.Graph {
+	g := newGraph("start",
+		[]graph.Node{startNode("start"), executorNode("a", nil), endNode("end")},
+		edge("start", "a"), edge("a", "end"))
+	g.Metadata = metadata
+	return g
+}
+
+// ticketInputSchema requires a string title and allows an integer priority
+func ticketInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"title"},
+		"properties": map[string]interface{}{
+			"title":    map[string]interface{}{"type": "string"},
+			"priority": map[string]interface{}{"type": "integer"},
+		},
+	}
+}
+
+func TestSubmitValidatesInputs(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t, reply(map[string]interface{}{}))
+	g := schemaGraph(map[string]interface{}{GraphInputSchema: ticketInputSchema()})
+
+	tests := []struct {
+		name      string
+		inputs    map[string]interface{}
+		wantPaths []string
+	}{
+		{"valid", map[string]interface{}{"title": "Refund", "priority": 1}, nil},
+		{"no inputs", nil, []string{""}},
+		{"mistyped", map[string]interface{}{"title": 7, "priority": "high"}, []string{"/priority", "/title"}},
+	}
+	for _, tt := range tests {
+		graphID, err := h.manager.SubmitGraph(ctx, g, tt.inputs)
+		if tt.wantPaths == nil {
+			if err != nil {
+				t.Errorf("%s: SubmitGraph() error = %v", tt.name, err)
+			} else {
+				h.waitDone(t, graphID)
+			}
+			continue
+		}
+
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Code != ValidationCodeInputs {
+			t.Errorf("%s: SubmitGraph() error = %v, want a %s", tt.name, err, ValidationCodeInputs)
+			continue
+		}
+		failures, _ := validationErr.Details["errors"].([]schema.FieldError)
+		var paths []string
+		for _, failure := range failures {
+			paths = append(paths, failure.Path)
+		}
+		if !reflect.DeepEqual(paths, tt.wantPaths) {
+			t.Errorf("%s: failed paths = %q, want %q", tt.name, paths, tt.wantPaths)
+		}
+	}
+}
+
+func TestSubmitRejectsInvalidSchemas(t *testing.T) {
+	h := newTestHarness(t, reply(map[string]interface{}{}))
+	for _, key := range []string{GraphInputSchema, GraphOutputSchema} {
+		g := schemaGraph(map[string]interface{}{key: map[string]interface{}{"type": 5}})
+		_, err := h.manager.SubmitGraph(context.Background(), g, nil)
+
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Code != ValidationCodeInvalidSchema {
+			t.Errorf("SubmitGraph() with an invalid %s: error = %v, want a %s", key, err, ValidationCodeInvalidSchema)
+		}
+	}
+}
+
+func TestOutputSchemaChecksTheFinalState(t *testing.T) {
+	outputSchema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"summary"},
+	}
+	tests := []struct {
+		name       string
+		output     map[string]interface{}
+		wantStatus domain.ExecutionStatus
+	}{
+		{"matching", map[string]interface{}{"summary": "done"}, domain.ExecutionStatusCompleted},
+		{"missing field", map[string]interface{}{"notes": "done"}, domain.ExecutionStatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, reply(tt.output))
+			g := schemaGraph(map[string]interface{}{GraphOutputSchema: outputSchema})
+			state := h.waitDone(t, h.submit(t, g, nil))
+			if state.Status != tt.wantStatus {
+				t.Fatalf("status = %s (%s), want %s", state.Status, state.Error, tt.wantStatus)
+			}
+			if tt.wantStatus == domain.ExecutionStatusFailed && !strings.Contains(state.Error, "output schema") {
+				t.Errorf("error = %q, want an output schema mismatch", state.Error)
+			}
+		})
+	}
+}
+
+// answerSchema requires a numeric answer
+func answerSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"required":   []interface{}{"answer"},
+		"properties": map[string]interface{}{"answer": map[string]interface{}{"type": "number"}},
+	}
+}
+
+// malformedFirst answers a with a malformed output on its first attempt
+// and a matching one afterwards
+func malformedFirst() workerFunc {
+	var mu sync.Mutex
+	attempts := 0
+	return func(data map[string]interface{}) map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			return map[string]interface{}{"output": map[string]interface{}{"answer": "not a number"}}
+		}
+		return map[string]interface{}{"output": map[string]interface{}{"answer": 3.0}}
+	}
+}
+
+func TestNodeOutputSchema(t *testing.T) {
+	tests := []struct {
+		name         string
+		retry        map[string]interface{}
+		wantStatus   domain.ExecutionStatus
+		wantAttempts int
+	}{
+		{"no retry", nil, domain.ExecutionStatusFailed, 1},
+		{"retried", map[string]interface{}{"max_attempts": 2, "backoff": "1ms"}, domain.ExecutionStatusCompleted, 2},
+		{"retried as schema", map[string]interface{}{"max_attempts": 2, "backoff": "1ms", "retryable_errors": []interface{}{ErrorClassSchema}}, domain.ExecutionStatusCompleted, 2},
+		{"not retryable", map[string]interface{}{"max_attempts": 2, "backoff": "1ms", "retryable_errors": []interface{}{ErrorClassTimeout}}, domain.ExecutionStatusFailed, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := map[string]interface{}{ConfigOutputSchema: answerSchema()}
+			if tt.retry != nil {
+				cfg[ConfigRetry] = tt.retry
+			}
+			h := newTestHarness(t, malformedFirst())
+			g := newGraph("start",
+				[]graph.Node{startNode("start"), executorNode("a", cfg), endNode("end")},
+				edge("start", "a"), edge("a", "end"))
+			state := h.waitDone(t, h.submit(t, g, nil))
+
+			if state.Status != tt.wantStatus {
+				t.Fatalf("status = %s (%s), want %s", state.Status, state.Error, tt.wantStatus)
+			}
+			if got := len(h.workFor("a")); got != tt.wantAttempts {
+				t.Errorf("a dispatched %d times, want %d", got, tt.wantAttempts)
+			}
+
+			// The malformed output is recorded as a schema failure, not applied
+			if got := lastErrorClass(state, "a"); got != ErrorClassSchema {
+				t.Errorf("error class = %q, want %q", got, ErrorClassSchema)
+			}
+			if tt.wantStatus == domain.ExecutionStatusFailed {
+				if got := SharedState(state)["answer"]; got != nil {
+					t.Errorf("shared answer = %v, want the malformed output left out", got)
+				}
+			} else if got := state.NodeStates["a"].Output; !reflect.DeepEqual(got, map[string]interface{}{"answer": 3.0}) {
+				t.Errorf("a output = %v, want the matching output", got)
+			}
+		})
+	}
+}
+
+func TestSubmitRejectsInvalidNodeOutputSchema(t *testing.T) {
+	h := newTestHarness(t, reply(map[string]interface{}{}))
+	g := newGraph("start",
+		[]graph.Node{startNode("start"), executorNode("a", map[string]interface{}{ConfigOutputSchema: map[string]interface{}{"type": 5}}), endNode("end")},
+		edge("start", "a"), edge("a", "end"))
+	if _, err := h.manager.SubmitGraph(context.Background(), g, nil); err == nil {
+		t.Error("SubmitGraph() with an invalid output schema succeeded")
+	}
+}
@@ -0,0 +1,143 @@
+package orchestrator
+
+import (
+	"fmt"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+)
+
+// Node configuration keys for join behaviour
+const (
+	ConfigJoin       = "join"
+	ConfigJoinQuorum = "join_quorum"
+)
+
+// JoinPolicy controls when a node with several incoming edges is dispatched
+type JoinPolicy string
+
+const (
+	// JoinAll waits for every predecessor to complete (default)
+	JoinAll JoinPolicy = "all"
+
+	// JoinAny fires on the first completed predecessor and cancels the
+	// branches of the others
+	JoinAny JoinPolicy = "any"
+
+	// JoinQuorum fires once join_quorum predecessors have completed
+	JoinQuorum JoinPolicy = "quorum"
+)
+
+// joinSpec is the resolved join configuration of a node
+type joinSpec struct {
+	policy JoinPolicy
+	quorum int
+}
+
+// parseJoinSpec reads the join policy from a node configuration
+func parseJoinSpec(cfg map[string]interface{}) (joinSpec, error) {
+	spec := joinSpec{policy: JoinPolicy(configString(cfg, ConfigJoin))}
+
+	switch spec.policy {
+	case "", JoinAll:
+		spec.policy = JoinAll
+	case JoinAny:
+	case JoinQuorum:
+		quorum, ok := configInt(cfg, ConfigJoinQuorum)
+		if !ok || quorum < 1 {
+			return spec, fmt.Errorf("%s must be a positive integer for quorum joins", ConfigJoinQuorum)
+		}
+		spec.quorum = quorum
+	default:
+		return spec, fmt.Errorf("unknown join policy: %s", spec.policy)
+	}
+
+	return spec, nil
+}
+
+// required returns how many of n predecessors must complete before the join fires
+func (j joinSpec) required(n int) int {
+	switch j.policy {
+	case JoinAny:
+		return 1
+	case JoinQuorum:
+		if j.quorum < n {
+			return j.quorum
+		}
+	}
+	return n
+}
+
+// predecessors returns the distinct source nodes of a node's incoming edges.
+// Loop back-edges are not counted, so a loop head does not wait for its tail,
+// and neither are on_error edges.
+func predecessors(g *domain.Graph, nodeID string) []string {
+	edges := g.GetIncomingEdges(nodeID)
+	preds := make([]string, 0, len(edges))
+	seen := make(map[string]bool, len(edges))
+	for _, edge := range edges {
+		if seen[edge.From] || isLoopEdge(edge) || isErrorEdge(edge) {
+			continue
+		}
+		seen[edge.From] = true
+		preds = append(preds, edge.From)
+	}
+	return preds
+}
+
+// joinReady evaluates a join node's policy against the edges from its
+// predecessors. Predecessors whose edge into the join was left untaken are
+// not waited for. Nodes with a single predecessor are always ready.
+func (m *Manager) joinReady(state *domain.GraphState, nodeID string) bool {
+	preds := predecessors(state.Graph, nodeID)
+	if len(preds) < 2 {
+		return true
+	}
+
+	// A join fires once; later arrivals find it already dispatched. A join
+	// skipped in an earlier loop iteration may be reached again.
+	if nodeState := state.NodeStates[nodeID]; nodeState != nil &&
+		nodeState.Status != domain.ExecutionStatusPending && nodeState.Status != ExecutionStatusSkipped {
+		return false
+	}
+
+	spec, err := parseJoinSpec(nodeConfig(state.Graph.GetNode(nodeID)))
+	if err != nil {
+		// Rejected by the validator; fall back to waiting for everyone
+		spec = joinSpec{policy: JoinAll}
+	}
+
+	arrived, live := 0, 0
+	for _, pred := range preds {
+		switch m.edgeBetween(state, pred, nodeID) {
+		case edgeTaken:
+			arrived++
+			live++
+		case edgeUndecided:
+			live++
+		}
+	}
+
+	return arrived > 0 && arrived >= spec.required(live)
+}
+
+// raceJoin reports whether a node is an any join, which cancels the
+// branches of its other predecessors once it fires
+func raceJoin(g *domain.Graph, nodeID string) bool {
+	if len(predecessors(g, nodeID)) < 2 {
+		return false
+	}
+	spec, err := parseJoinSpec(nodeConfig(g.GetNode(nodeID)))
+	return err == nil && spec.policy == JoinAny
+}
+
+// joinOutputs merges the outputs of a join node's completed predecessors,
+// keyed by predecessor node ID
+func joinOutputs(state *domain.GraphState, nodeID string) map[string]interface{} {
+	outputs := make(map[string]interface{})
+	for _, pred := range predecessors(state.Graph, nodeID) {
+		if predState := state.NodeStates[pred]; predState != nil && predState.Status == domain.ExecutionStatusCompleted {
+			outputs[pred] = predState.Output
+		}
+	}
+	return outputs
+}
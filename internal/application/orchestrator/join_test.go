@@ -0,0 +1,110 @@
+package orchestrator
+
+import (
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+// joinGraph fans start out to a, b and c, which meet at join
+func joinGraph(joinCfg map[string]interface{}) *domain.Graph {
+	return newGraph("start",
+		[]graph.Node{
+			startNode("start"),
+			executorNode("a", nil),
+			executorNode("b", nil),
+			executorNode("c", nil),
+			executorNode("join", joinCfg),
+			endNode("end"),
+		},
+		edge("start", "a"), edge("start", "b"), edge("start", "c"),
+		edge("a", "join"), edge("b", "join"), edge("c", "join"),
+		edge("join", "end"))
+}
+
+// slowC answers every node, c only after a delay
+func slowC(data map[string]interface{}) map[string]interface{} {
+	if data["node_id"] == "c" {
+		time.Sleep(200 * time.Millisecond)
+	}
+	return map[string]interface{}{"output": data["node_id"]}
+}
+
+// joinedPredecessors returns the predecessors whose outputs the join was
+// dispatched with
+func joinedPredecessors(t *testing.T, h *testHarness) []string {
+	t.Helper()
+	work := h.workFor("join")
+	if len(work) != 1 {
+		t.Fatalf("join dispatched %d times, want 1", len(work))
+	}
+	outputs, _ := work[0].Data["predecessor_outputs"].(map[string]interface{})
+	var preds []string
+	for pred := range outputs {
+		preds = append(preds, pred)
+	}
+	sort.Strings(preds)
+	return preds
+}
+
+func TestJoinPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		joinCfg map[string]interface{}
+		want    []string
+	}{
+		{"default waits for all", nil, []string{"a", "b", "c"}},
+		{"all", map[string]interface{}{ConfigJoin: "all"}, []string{"a", "b", "c"}},
+		{"quorum", map[string]interface{}{ConfigJoin: "quorum", ConfigJoinQuorum: 2}, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, slowC)
+			graphID := h.submit(t, joinGraph(tt.joinCfg), nil)
+
+			state := h.waitDone(t, graphID)
+			if state.Status != domain.ExecutionStatusCompleted {
+				t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+			}
+			if got := joinedPredecessors(t, h); !slices.Equal(got, tt.want) {
+				t.Errorf("join ran with outputs of %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJoinSpec(t *testing.T) {
+	tests := []struct {
+		cfg     map[string]interface{}
+		want    joinSpec
+		wantErr bool
+	}{
+		{cfg: nil, want: joinSpec{policy: JoinAll}},
+		{cfg: map[string]interface{}{ConfigJoin: "any"}, want: joinSpec{policy: JoinAny}},
+		{cfg: map[string]interface{}{ConfigJoin: "quorum", ConfigJoinQuorum: 2}, want: joinSpec{policy: JoinQuorum, quorum: 2}},
+		{cfg: map[string]interface{}{ConfigJoin: "quorum"}, wantErr: true},
+		{cfg: map[string]interface{}{ConfigJoin: "quorum", ConfigJoinQuorum: 0}, wantErr: true},
+		{cfg: map[string]interface{}{ConfigJoin: "most"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseJoinSpec(tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseJoinSpec(%v) error = %v, wantErr %v", tt.cfg, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseJoinSpec(%v) = %+v, want %+v", tt.cfg, got, tt.want)
+		}
+	}
+
+	// A quorum larger than the predecessors still running needs all of them
+	spec := joinSpec{policy: JoinQuorum, quorum: 3}
+	if got := spec.required(2); got != 2 {
+		t.Errorf("required(2) = %d, want 2", got)
+	}
+}
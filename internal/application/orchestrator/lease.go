@@ -0,0 +1,104 @@
+package orchestrator
+
+import (
+	"context"
+	"time"
+
+	"github.com/aescanero/dago/pkg/adapters/storage"
+	"go.uber.org/zap"
+)
+
+// An execution is looked after by the replica that submitted or recovered
+// it, which holds a lease on it in the state storage and renews it every
+// leaseRenewInterval. Once a replica stops, its leases lapse and another
+// replica recovers the executions on its next sweep.
+const (
+	executionLeaseTTL  = 30 * time.Second
+	leaseRenewInterval = executionLeaseTTL / 3
+)
+
+// executionLeaseKey returns the name of an execution's lease
+func executionLeaseKey(graphID string) string {
+	return "execution:" + graphID
+}
+
+// acquireExecution takes or renews the lease on an execution and reports
+// whether this replica holds it. Storages without leases always grant it.
+func (m *Manager) acquireExecution(ctx context.Context, graphID string) (bool, error) {
+	leases, ok := m.storage.(storage.LeaseStorage)
+	if !ok {
+		return true, nil
+	}
+	return leases.AcquireLease(ctx, executionLeaseKey(graphID), m.instanceID, executionLeaseTTL)
+}
+
+// releaseExecution gives up the lease on an execution that finished
+func (m *Manager) releaseExecution(ctx context.Context, graphID string) {
+	leases, ok := m.storage.(storage.LeaseStorage)
+	if !ok {
+		return
+	}
+	if err := leases.ReleaseLease(ctx, executionLeaseKey(graphID), m.instanceID); err != nil {
+		m.logger.Warn("failed to release execution lease",
+			zap.String("graph_id", graphID),
+			zap.Error(err))
+	}
+}
+
+// maintainLeases renews the leases on this replica's executions and
+// recovers executions whose owner let its lease lapse
+func (m *Manager) maintainLeases() {
+	renew := time.NewTicker(leaseRenewInterval)
+	defer renew.Stop()
+	sweep := time.NewTicker(executionLeaseTTL)
+	defer sweep.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-renew.C:
+			m.renewLeases()
+		case <-sweep.C:
+			if err := m.recoverExecutions(m.ctx, false); err != nil {
+				m.logger.Error("failed to recover executions", zap.Error(err))
+			}
+		}
+	}
+}
+
+// renewLeases renews the lease on each execution this replica looks after.
+// Executions whose lease another replica took over are let go.
+func (m *Manager) renewLeases() {
+	m.executions.Range(func(key, value interface{}) bool {
+		graphID := key.(string)
+		held, err := m.acquireExecution(m.ctx, graphID)
+		if err != nil {
+			m.logger.Warn("failed to renew execution lease",
+				zap.String("graph_id", graphID),
+				zap.Error(err))
+			return true
+		}
+		if !held {
+			m.logger.Warn("execution lease taken over by another replica",
+				zap.String("graph_id", graphID))
+			m.dropExecution(graphID)
+		}
+		return true
+	})
+}
+
+// dropExecution stops looking after an execution without changing its state
+func (m *Manager) dropExecution(graphID string) {
+	val, ok := m.executions.Load(graphID)
+	if !ok {
+		return
+	}
+	execCtx := val.(*executionContext)
+	execCtx.mu.Lock()
+	defer execCtx.mu.Unlock()
+
+	execCtx.cancelFunc()
+	stopNodeTimers(execCtx)
+	m.executions.Delete(graphID)
+}
@@ -0,0 +1,318 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/internal/expression"
+	"go.uber.org/zap"
+)
+
+// Edge metadata keys declaring a loop back-edge. A loop edge needs at least
+// one of them.
+const (
+	// EdgeMaxIterations caps how many times the loop body runs
+	EdgeMaxIterations = "max_iterations"
+
+	// EdgeExitCondition ends the loop once it evaluates to true
+	EdgeExitCondition = "exit_condition"
+)
+
+// Node state metadata keys for loops
+const (
+	MetadataIteration     = "iteration"
+	MetadataOutputHistory = "output_history"
+	MetadataLoopCounts    = "loop_counts"
+)
+
+// EventTypeLoopIteration is published when a loop edge restarts its body
+const EventTypeLoopIteration domain.EventType = "loop.iteration"
+
+// exitConditions holds the compiled exit conditions of loop edges by their
+// source. Edges are decoded anew whenever a state is loaded, so the source
+// is what identifies an edge's condition across loads.
+var exitConditions sync.Map // map[string]*expression.Program
+
+// loopSpec is the resolved loop declaration of a back-edge
+type loopSpec struct {
+	maxIterations int // 0 when only the exit condition bounds the loop
+	exitCondition *expression.Program
+}
+
+// loopIteration describes a loop edge that was taken
+type loopIteration struct {
+	edge      *graph.Edge
+	iteration int      // iteration of the body about to run
+	body      []string // nodes reset for the new iteration
+	running   []string // body nodes that were still running when reset
+}
+
+// parseLoopSpec reads the loop declaration from an edge's metadata. The
+// boolean result is false for ordinary edges.
+func parseLoopSpec(edge *graph.Edge) (loopSpec, bool, error) {
+	var spec loopSpec
+	if !isLoopEdge(edge) {
+		return spec, false, nil
+	}
+
+	if _, hasMax := edge.Metadata[EdgeMaxIterations]; hasMax {
+		maxIterations, ok := configInt(edge.Metadata, EdgeMaxIterations)
+		if !ok || maxIterations < 1 {
+			return spec, true, fmt.Errorf("%s must be a positive integer", EdgeMaxIterations)
+		}
+		spec.maxIterations = maxIterations
+	}
+
+	if _, hasExit := edge.Metadata[EdgeExitCondition]; hasExit {
+		source, ok := edge.Metadata[EdgeExitCondition].(string)
+		if !ok || source == "" {
+			return spec, true, fmt.Errorf("%s must be a non-empty expression", EdgeExitCondition)
+		}
+		program, err := compileExitCondition(source)
+		if err != nil {
+			return spec, true, err
+		}
+		spec.exitCondition = program
+	}
+
+	return spec, true, nil
+}
+
+// compileExitCondition compiles a loop exit condition once per source
+func compileExitCondition(source string) (*expression.Program, error) {
+	if program, ok := exitConditions.Load(source); ok {
+		return program.(*expression.Program), nil
+	}
+	program, err := expression.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	exitConditions.Store(source, program)
+	return program, nil
+}
+
+// isLoopEdge reports whether an edge is declared as a loop back-edge. It
+// only looks at the declaration; parseLoopSpec checks it.
+func isLoopEdge(edge *graph.Edge) bool {
+	_, hasMax := edge.Metadata[EdgeMaxIterations]
+	_, hasExit := edge.Metadata[EdgeExitCondition]
+	return hasMax || hasExit
+}
+
+// edgeKey identifies an edge in node state metadata
+func edgeKey(edge *graph.Edge) string {
+	if edge.ID != "" {
+		return edge.ID
+	}
+	return edge.From + "->" + edge.To
+}
+
+// nodeIteration returns the loop iteration a node is in (1-based)
+func nodeIteration(nodeState *domain.NodeState) int {
+	if iteration, ok := configInt(nodeState.Metadata, MetadataIteration); ok && iteration > 0 {
+		return iteration
+	}
+	return 1
+}
+
+// takeLoop follows a loop edge out of a just completed node when its bound
+// and exit condition allow another iteration, resetting the loop body in
+// state. It returns nil when the node has no loop edge to follow.
+func (m *Manager) takeLoop(state *domain.GraphState, nodeID string) *loopIteration {
+	tailState := state.NodeStates[nodeID]
+	chosen, _ := tailState.Metadata[MetadataNextNode].(string)
+
+	for _, edge := range state.Graph.GetOutgoingEdges(nodeID) {
+		spec, ok, err := parseLoopSpec(edge)
+		if !ok || err != nil {
+			continue
+		}
+
+		// Routers loop only when they chose the loop head
+		if chosen != "" && chosen != edge.To {
+			continue
+		}
+
+		counts, _ := tailState.Metadata[MetadataLoopCounts].(map[string]interface{})
+		taken, _ := configInt(counts, edgeKey(edge))
+		iteration := taken + 1 // iterations of the body so far
+
+		if spec.maxIterations > 0 && iteration >= spec.maxIterations {
+			m.logger.Info("loop reached max iterations",
+				zap.String("graph_id", state.GraphID),
+				zap.String("from", edge.From),
+				zap.String("to", edge.To),
+				zap.Int("iterations", iteration))
+			continue
+		}
+
+		if spec.exitCondition != nil {
+			env := expressionEnv(state, nodeID)
+			env["iteration"] = iteration
+			exit, err := spec.exitCondition.EvalBool(env)
+			if err != nil {
+				// A broken condition must not keep the loop spinning
+				m.logger.Warn("loop exit condition failed, leaving loop",
+					zap.String("graph_id", state.GraphID),
+					zap.String("from", edge.From),
+					zap.String("to", edge.To),
+					zap.Error(err))
+				continue
+			}
+			if exit {
+				continue
+			}
+		}
+
+		updated := make(map[string]interface{}, len(counts)+1)
+		for key, value := range counts {
+			updated[key] = value
+		}
+		updated[edgeKey(edge)] = taken + 1
+		setNodeMetadata(tailState, MetadataLoopCounts, updated)
+
+		loop := &loopIteration{
+			edge:      edge,
+			iteration: iteration + 1,
+			body:      loopBody(state.Graph, edge.To, nodeID),
+		}
+		for _, bodyID := range loop.body {
+			if bodyState := state.NodeStates[bodyID]; bodyState != nil {
+				if bodyState.Status == domain.ExecutionStatusRunning {
+					loop.running = append(loop.running, bodyID)
+				}
+				resetForIteration(bodyState, bodyID == nodeID)
+			}
+		}
+		return loop
+	}
+
+	return nil
+}
+
+// loopBody returns the nodes on forward paths from a loop's head to its tail
+func loopBody(g *domain.Graph, head, tail string) []string {
+	adj := buildAdjacency(g, false)
+
+	fromHead := map[string]bool{head: true}
+	queue := []string{head}
+	for len(queue) > 0 {
+		nodeID := queue[0]
+		queue = queue[1:]
+		for _, next := range adj[nodeID] {
+			if !fromHead[next] {
+				fromHead[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	reverse := make(adjacency, len(adj))
+	for from, targets := range adj {
+		for _, to := range targets {
+			reverse[to] = append(reverse[to], from)
+		}
+	}
+	toTail := map[string]bool{tail: true}
+	queue = []string{tail}
+	for len(queue) > 0 {
+		nodeID := queue[0]
+		queue = queue[1:]
+		for _, prev := range reverse[nodeID] {
+			if !toTail[prev] {
+				toTail[prev] = true
+				queue = append(queue, prev)
+			}
+		}
+	}
+
+	var body []string
+	for _, nodeID := range sortedNodeIDs(g) {
+		if fromHead[nodeID] && toTail[nodeID] {
+			body = append(body, nodeID)
+		}
+	}
+	return body
+}
+
+// resetForIteration returns a loop body node to pending for the next
+// iteration, moving its last output into the output history. Loop counters
+// of nested loops restart; the tail keeps the counter of its own loop.
+func resetForIteration(nodeState *domain.NodeState, isTail bool) {
+	// Nodes the previous iteration never reached stay as they are
+	if nodeState.Status == domain.ExecutionStatusPending {
+		return
+	}
+
+	iteration := nodeIteration(nodeState)
+	if nodeState.Status == domain.ExecutionStatusCompleted {
+		history, _ := nodeState.Metadata[MetadataOutputHistory].([]interface{})
+		entry := map[string]interface{}{
+			"iteration": iteration,
+			"output":    nodeState.Output,
+		}
+		if nodeState.CompletedAt != nil {
+			entry["completed_at"] = *nodeState.CompletedAt
+		}
+		if seq, ok := nodeState.Metadata[MetadataStateSeq]; ok {
+			entry[MetadataStateSeq] = seq
+		}
+		setNodeMetadata(nodeState, MetadataOutputHistory, append(history, entry))
+	}
+
+	nodeState.Status = domain.ExecutionStatusPending
+	nodeState.Output = nil
+	nodeState.Error = ""
+	nodeState.StartedAt = nil
+	nodeState.CompletedAt = nil
+	setNodeMetadata(nodeState, MetadataIteration, iteration+1)
+	delete(nodeState.Metadata, MetadataAttempt)
+	delete(nodeState.Metadata, MetadataRetryAt)
+	delete(nodeState.Metadata, MetadataNextNode)
+	delete(nodeState.Metadata, MetadataAwaitingInput)
+	delete(nodeState.Metadata, MetadataAwaitingSignal)
+	delete(nodeState.Metadata, MetadataSignalToken)
+	delete(nodeState.Metadata, MetadataNextNodes)
+	delete(nodeState.Metadata, MetadataErrorHandled)
+	delete(nodeState.Metadata, MetadataStateSeq)
+	if !isTail {
+		delete(nodeState.Metadata, MetadataLoopCounts)
+	}
+}
+
+// startLoopIteration dispatches the head of a loop whose body was reset.
+// Callers must hold the execution lock.
+func (m *Manager) startLoopIteration(ctx context.Context, graphID string, state *executionState, loop *loopIteration) {
+	// Branches of the previous iteration that were still in flight are abandoned
+	for _, nodeID := range loop.running {
+		m.stopNodeTimeout(graphID, nodeID)
+	}
+
+	m.logger.Info("starting loop iteration",
+		zap.String("graph_id", graphID),
+		zap.String("from", loop.edge.From),
+		zap.String("to", loop.edge.To),
+		zap.Int("iteration", loop.iteration))
+
+	// Publish loop event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeLoopIteration, map[string]interface{}{
+		"from":      loop.edge.From,
+		"to":        loop.edge.To,
+		"iteration": loop.iteration,
+	})
+
+	m.dispatchNodes(ctx, graphID, state, []string{loop.edge.To})
+}
+
+// isLoopTarget reports whether a node reaches target through a loop edge
+func (m *Manager) isLoopTarget(g *domain.Graph, nodeID, target string) bool {
+	for _, edge := range g.GetOutgoingEdges(nodeID) {
+		if edge.To == target && isLoopEdge(edge) {
+			return true
+		}
+	}
+	return false
+}
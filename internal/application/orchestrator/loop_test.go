@@ -0,0 +1,167 @@
+package orchestrator
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+// loopEdge builds a loop back-edge declared by its metadata
+func loopEdge(from, to string, metadata map[string]interface{}) *graph.Edge {
+	return &graph.Edge{From: from, To: to, Metadata: metadata}
+}
+
+// loopGraph runs a -> b, looping from b back to a
+func loopGraph(loop map[string]interface{}) *domain.Graph {
+	return newGraph("s",
+		[]graph.Node{startNode("s"), executorNode("a", nil), executorNode("b", nil), endNode("e")},
+		edge("s", "a"), edge("a", "b"), loopEdge("b", "a", loop), edge("b", "e"))
+}
+
+// countingWorker answers each node with how often it ran so far
+func countingWorker() workerFunc {
+	var mu sync.Mutex
+	runs := make(map[string]int)
+	return func(data map[string]interface{}) map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		nodeID := data["node_id"].(string)
+		runs[nodeID]++
+		return map[string]interface{}{"output": map[string]interface{}{"run": runs[nodeID]}}
+	}
+}
+
+func TestLoopBounds(t *testing.T) {
+	tests := []struct {
+		name string
+		loop map[string]interface{}
+		want int
+	}{
+		{"max iterations", map[string]interface{}{EdgeMaxIterations: 3}, 3},
+		{"exit condition on the output", map[string]interface{}{EdgeExitCondition: "output.run >= 2"}, 2},
+		{"exit condition on the iteration", map[string]interface{}{EdgeExitCondition: "iteration >= 4"}, 4},
+		{"bound before the exit condition", map[string]interface{}{EdgeMaxIterations: 2, EdgeExitCondition: "output.run >= 5"}, 2},
+		{"broken exit condition leaves the loop", map[string]interface{}{EdgeExitCondition: "len(output.run) > 0"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, countingWorker())
+			state := h.waitDone(t, h.submit(t, loopGraph(tt.loop), nil))
+			if state.Status != domain.ExecutionStatusCompleted {
+				t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+			}
+			for _, nodeID := range []string{"a", "b"} {
+				if got := len(h.workFor(nodeID)); got != tt.want {
+					t.Errorf("%s dispatched %d times, want %d", nodeID, got, tt.want)
+				}
+			}
+			if got := len(h.eventsOf(EventTypeLoopIteration)); got != tt.want-1 {
+				t.Errorf("published %d loop.iteration events, want %d", got, tt.want-1)
+			}
+			assertNodeStatus(t, state, "e", domain.ExecutionStatusCompleted)
+		})
+	}
+}
+
+func TestLoopKeepsOutputHistory(t *testing.T) {
+	h := newTestHarness(t, countingWorker())
+	state := h.waitDone(t, h.submit(t, loopGraph(map[string]interface{}{EdgeMaxIterations: 3}), nil))
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+
+	// The last iteration's output stays the node's output, earlier ones
+	// move to the history
+	b := state.NodeStates["b"]
+	if got := nodeIteration(b); got != 3 {
+		t.Errorf("b iteration = %d, want 3", got)
+	}
+	if run := b.Output.(map[string]interface{})["run"]; run != 3 {
+		t.Errorf("b output run = %v, want 3", run)
+	}
+	history, _ := b.Metadata[MetadataOutputHistory].([]interface{})
+	if len(history) != 2 {
+		t.Fatalf("b output history has %d entries, want 2", len(history))
+	}
+	for i, raw := range history {
+		entry := raw.(map[string]interface{})
+		if iteration, _ := configInt(entry, "iteration"); iteration != i+1 {
+			t.Errorf("history[%d] iteration = %v, want %d", i, entry["iteration"], i+1)
+		}
+		if run := entry["output"].(map[string]interface{})["run"]; run != i+1 {
+			t.Errorf("history[%d] output run = %v, want %d", i, run, i+1)
+		}
+	}
+}
+
+func TestLoopInsideJoin(t *testing.T) {
+	// The loop a <-> b runs next to c; j joins b and c once the loop exits
+	h := newTestHarness(t, countingWorker())
+	g := newGraph("s",
+		[]graph.Node{
+			startNode("s"),
+			executorNode("a", nil),
+			executorNode("b", nil),
+			executorNode("c", nil),
+			executorNode("j", nil),
+			endNode("e"),
+		},
+		edge("s", "a"), edge("s", "c"),
+		edge("a", "b"), loopEdge("b", "a", map[string]interface{}{EdgeMaxIterations: 3}),
+		edge("b", "j"), edge("c", "j"), edge("j", "e"))
+	state := h.waitDone(t, h.submit(t, g, nil))
+
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	if got := len(h.workFor("b")); got != 3 {
+		t.Errorf("b dispatched %d times, want 3", got)
+	}
+	if got := len(h.workFor("j")); got != 1 {
+		t.Errorf("j dispatched %d times, want 1", got)
+	}
+	if got := len(h.workFor("c")); got != 1 {
+		t.Errorf("c dispatched %d times, want 1", got)
+	}
+}
+
+func TestParseLoopSpec(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		wantLoop bool
+		wantErr  bool
+	}{
+		{"ordinary edge", nil, false, false},
+		{"bounded", map[string]interface{}{EdgeMaxIterations: 3.0}, true, false},
+		{"exit condition", map[string]interface{}{EdgeExitCondition: "output.done"}, true, false},
+		{"zero bound", map[string]interface{}{EdgeMaxIterations: 0}, true, true},
+		{"empty exit condition", map[string]interface{}{EdgeExitCondition: ""}, true, true},
+		{"exit condition that does not parse", map[string]interface{}{EdgeExitCondition: "output.done &&"}, true, true},
+	}
+	for _, tt := range tests {
+		edge := loopEdge("b", "a", tt.metadata)
+		_, isLoop, err := parseLoopSpec(edge)
+		if isLoop != tt.wantLoop || (err != nil) != tt.wantErr {
+			t.Errorf("%s: parseLoopSpec() = %v, %v; want loop %v, error %v", tt.name, isLoop, err, tt.wantLoop, tt.wantErr)
+		}
+		if isLoopEdge(edge) != tt.wantLoop {
+			t.Errorf("%s: isLoopEdge() = %v, want %v", tt.name, !tt.wantLoop, tt.wantLoop)
+		}
+	}
+}
+
+func TestExitConditionsCompileOnce(t *testing.T) {
+	// Each state load decodes the edge anew; its condition is compiled once
+	first, _, err := parseLoopSpec(loopEdge("b", "a", map[string]interface{}{EdgeExitCondition: "output.score > 0.9"}))
+	if err != nil {
+		t.Fatalf("parseLoopSpec() error = %v", err)
+	}
+	second, _, _ := parseLoopSpec(loopEdge("b", "a", map[string]interface{}{EdgeExitCondition: "output.score > 0.9"}))
+	if first.exitCondition != second.exitCondition {
+		t.Error("exit condition compiled again for an edge with the same source")
+	}
+}
@@ -3,36 +3,42 @@ package orchestrator
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/aescanero/dago-libs/pkg/domain"
 	"github.com/aescanero/dago-libs/pkg/domain/graph"
 	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/aescanero/dago/pkg/adapters/storage"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 // Event topics for worker communication
 const (
-	TopicExecutorWork   = "executor.work"
-	TopicRouterWork     = "router.work"
-	TopicNodeCompleted  = "node.completed"
-	TopicGraphEvents    = "graph.events"
+	TopicExecutorWork  = "executor.work"
+	TopicRouterWork    = "router.work"
+	TopicNodeCompleted = "node.completed"
+	TopicNodeCancel    = "node.cancel"
+	TopicGraphEvents   = "graph.events"
 )
 
 // Manager coordinates graph execution by publishing work to workers
 // and listening for completion events
 type Manager struct {
-	eventBus   ports.EventBus
-	storage    ports.StateStorage
-	metrics    ports.MetricsCollector
-	validator  *Validator
-	logger     *zap.Logger
+	eventBus  ports.EventBus
+	storage   ports.StateStorage
+	metrics   ports.MetricsCollector
+	validator *Validator
+	logger    *zap.Logger
 
 	// Track active executions
 	executions sync.Map // map[string]*executionContext
 
+	// Identifies this replica as the owner of execution leases
+	instanceID string
+
 	// Configuration
 	graphTimeout time.Duration
 	nodeTimeout  time.Duration
@@ -48,6 +54,7 @@ type executionContext struct {
 	status     domain.ExecutionStatus
 	startedAt  time.Time
 	cancelFunc context.CancelFunc
+	nodeTimers map[string]*time.Timer // per-node execution deadlines
 	mu         sync.RWMutex
 }
 
@@ -67,6 +74,7 @@ func NewManager(
 		metrics:      metrics,
 		validator:    validator,
 		logger:       logger,
+		instanceID:   uuid.New().String(),
 		graphTimeout: graphTimeout,
 		nodeTimeout:  nodeTimeout,
 		ctx:          ctx,
@@ -78,11 +86,28 @@ func NewManager(
 func (m *Manager) Start() error {
 	m.logger.Info("starting orchestrator manager")
 
+	// Resume executions left running by a previous process. Reading every
+	// stored state once also indexes executions stored before the index of
+	// active executions existed.
+	if err := m.recoverExecutions(m.ctx, true); err != nil {
+		return fmt.Errorf("failed to recover executions: %w", err)
+	}
+
 	// Subscribe to node completion events from workers
 	if err := m.eventBus.Subscribe(m.ctx, TopicNodeCompleted, m.handleNodeCompleted); err != nil {
 		return fmt.Errorf("failed to subscribe to node completed events: %w", err)
 	}
 
+	// Fire delay timers kept by the state storage
+	if timers, ok := m.storage.(storage.TimerStorage); ok {
+		go m.pollTimers(timers)
+	}
+
+	// Keep this replica's executions and adopt those of replicas that stopped
+	if _, ok := m.storage.(storage.LeaseStorage); ok {
+		go m.maintainLeases()
+	}
+
 	m.logger.Info("orchestrator manager started, listening for node completion events")
 	return nil
 }
@@ -98,18 +123,27 @@ func (m *Manager) SubmitGraph(ctx context.Context, g *domain.Graph, inputs map[s
 		return "", fmt.Errorf("validation failed: %w", err)
 	}
 
+	// Validate inputs against the graph's input schema
+	if err := checkInputs(g, inputs); err != nil {
+		m.logger.Error("input validation failed",
+			zap.String("graph_id", g.ID),
+			zap.Error(err))
+		m.metrics.RecordGraphSubmitted(string(domain.ExecutionStatusFailed))
+		return "", err
+	}
+
 	// Generate execution ID
 	graphID := uuid.New().String()
 
 	// Create initial state
-	state := &domain.GraphState{
+	state := &executionState{GraphState: &domain.GraphState{
 		GraphID:     graphID,
 		Graph:       g,
 		Status:      domain.ExecutionStatusRunning,
 		Inputs:      inputs,
 		NodeStates:  make(map[string]*domain.NodeState),
 		SubmittedAt: time.Now(),
-	}
+	}}
 
 	// Initialize node states
 	for nodeID := range g.Nodes {
@@ -119,11 +153,34 @@ func (m *Manager) SubmitGraph(ctx context.Context, g *domain.Graph, inputs map[s
 		}
 	}
 
+	// Track execution. The lease is taken before the state is stored so
+	// that no other replica recovers the new execution, and the execution
+	// indexed so that sweeps find it even if this replica stops right after.
+	if _, err := m.acquireExecution(ctx, graphID); err != nil {
+		return "", fmt.Errorf("failed to acquire execution lease: %w", err)
+	}
+	if err := m.indexExecution(ctx, graphID); err != nil {
+		m.releaseExecution(ctx, graphID)
+		return "", fmt.Errorf("failed to index execution: %w", err)
+	}
+	execCtx, cancel := context.WithTimeout(context.Background(), m.graphTimeout)
+	exec := &executionContext{
+		graphID:    graphID,
+		status:     domain.ExecutionStatusRunning,
+		startedAt:  time.Now(),
+		cancelFunc: cancel,
+		nodeTimers: make(map[string]*time.Timer),
+	}
+	m.executions.Store(graphID, exec)
+
 	// Store initial state
-	if err := m.storage.SaveState(ctx, state); err != nil {
+	if err := m.saveState(ctx, state); err != nil {
 		m.logger.Error("failed to save initial state",
 			zap.String("graph_id", graphID),
 			zap.Error(err))
+		cancel()
+		m.executions.Delete(graphID)
+		m.releaseExecution(ctx, graphID)
 		return "", fmt.Errorf("failed to save state: %w", err)
 	}
 
@@ -134,15 +191,6 @@ func (m *Manager) SubmitGraph(ctx context.Context, g *domain.Graph, inputs map[s
 		return "", err
 	}
 
-	// Track execution
-	execCtx, cancel := context.WithTimeout(context.Background(), m.graphTimeout)
-	m.executions.Store(graphID, &executionContext{
-		graphID:    graphID,
-		status:     domain.ExecutionStatusRunning,
-		startedAt:  time.Now(),
-		cancelFunc: cancel,
-	})
-
 	m.metrics.RecordGraphSubmitted(string(domain.ExecutionStatusSubmitted))
 	m.logger.Info("graph submitted",
 		zap.String("graph_id", graphID),
@@ -152,16 +200,13 @@ func (m *Manager) SubmitGraph(ctx context.Context, g *domain.Graph, inputs map[s
 	// Start execution monitoring in background
 	go m.monitorExecution(execCtx, graphID)
 
-	// Publish work for entry node
-	if err := m.publishNodeWork(ctx, graphID, g.EntryNode, state); err != nil {
-		m.logger.Error("failed to publish entry node work",
-			zap.String("graph_id", graphID),
-			zap.String("node_id", g.EntryNode),
-			zap.Error(err))
-		return graphID, nil // Return graphID even on error, execution will timeout
-	}
+	// Publish work for entry node. Completions may arrive before dispatch
+	// returns, so hold the execution lock while the entry branch is set up.
+	exec.mu.Lock()
+	defer exec.mu.Unlock()
+	m.dispatchNodes(ctx, graphID, state, []string{g.EntryNode})
 
-	return graphID, nil
+	return graphID, nil // Return graphID even on dispatch error, execution will timeout
 }
 
 // handleNodeCompleted processes node completion events from workers
@@ -170,7 +215,10 @@ func (m *Manager) handleNodeCompleted(ctx context.Context, event ports.Event) er
 	nodeID, _ := event.Data["node_id"].(string)
 	output := event.Data["output"]
 	errorMsg, hasError := event.Data["error"].(string)
+	errorClass, _ := event.Data["error_class"].(string)
 	nextNodeID, _ := event.Data["next_node"].(string) // For router nodes
+	dispatchID, _ := event.Data["dispatch_id"].(string)
+	attempt, _ := configInt(event.Data, "attempt")
 
 	m.logger.Info("received node completed event",
 		zap.String("graph_id", graphID),
@@ -178,8 +226,12 @@ func (m *Manager) handleNodeCompleted(ctx context.Context, event ports.Event) er
 		zap.Bool("has_error", hasError),
 		zap.String("next_node", nextNodeID))
 
+	// Branches of the same execution complete concurrently
+	unlock := m.lockExecution(graphID)
+	defer unlock()
+
 	// Get current state
-	stateInterface, err := m.storage.GetState(ctx, graphID)
+	state, err := m.loadState(ctx, graphID)
 	if err != nil {
 		m.logger.Error("failed to get state on node completion",
 			zap.String("graph_id", graphID),
@@ -187,10 +239,40 @@ func (m *Manager) handleNodeCompleted(ctx context.Context, event ports.Event) er
 		return nil // Don't return error to avoid reprocessing
 	}
 
-	state, ok := stateInterface.(*domain.GraphState)
-	if !ok {
-		m.logger.Error("invalid state type",
-			zap.String("graph_id", graphID))
+	// Late completions from other branches of a finished execution are dropped
+	if !executionActive(state.Status) {
+		m.logger.Info("ignoring node completion for finished execution",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.String("status", string(state.Status)))
+		return nil
+	}
+
+	// Once the outcome is reached, only finally and compensation nodes
+	// advance the graph
+	if reason := abandonedCompletion(state.GraphState, nodeID); reason != "" {
+		m.logger.Info("ignoring node completion of abandoned branch",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.String("reason", reason))
+		return nil
+	}
+
+	// Map items report back under the executor node the map runs
+	if mapID := mapNodeOf(state.Graph, nodeID); mapID != "" {
+		if hasError && errorMsg == "" {
+			errorMsg = "map item failed"
+		}
+		if !hasError {
+			if err := checkNodeOutput(state.Graph.GetNode(nodeID), output); err != nil {
+				errorMsg, errorClass = err.Error(), ErrorClassSchema
+			}
+		}
+		itemIndex, ok := configInt(event.Data, "item_index")
+		if !ok {
+			itemIndex = -1
+		}
+		m.completeMapItem(ctx, graphID, state, mapID, dispatchID, itemIndex, output, errorMsg, errorClass)
 		return nil
 	}
 
@@ -203,89 +285,288 @@ func (m *Manager) handleNodeCompleted(ctx context.Context, event ports.Event) er
 		return nil
 	}
 
-	now := time.Now()
-	nodeState.CompletedAt = &now
+	// Delivery is at-least-once: redelivered completions and completions of
+	// superseded attempts must not advance the graph again
+	if reason := staleCompletion(nodeState, dispatchID, attempt); reason != "" {
+		m.logger.Info("ignoring stale node completion",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.String("dispatch_id", dispatchID),
+			zap.String("reason", reason))
+		return nil
+	}
+	if dispatchID == "" {
+		m.logger.Warn("accepting node completion without dispatch id; workers must echo it",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID))
+	}
+
+	// The node reported back, its deadline no longer applies
+	m.stopNodeTimeout(graphID, nodeID)
 
+	// If node failed, apply the failure policy
 	if hasError {
-		nodeState.Status = domain.ExecutionStatusFailed
-		nodeState.Error = errorMsg
-	} else {
+		m.failNode(ctx, graphID, state, nodeID, errorMsg, errorClass)
+		return nil
+	}
+
+	// Outputs that do not match the node's output schema fail the node, and
+	// are retried under its retry policy like any other failure
+	if err := checkNodeOutput(state.Graph.GetNode(nodeID), output); err != nil {
+		m.failNode(ctx, graphID, state, nodeID, err.Error(), ErrorClassSchema)
+		return nil
+	}
+
+	// Routers may only hand over to nodes they have an edge to
+	if nextNodeID != "" && !validChoice(state.Graph, nodeID, nextNodeID) {
+		nextNodeID, err = m.resolveInvalidChoice(ctx, graphID, state.Graph, nodeID, nextNodeID)
+		if err != nil {
+			m.failNode(ctx, graphID, state, nodeID, err.Error(), ErrorClassInvalidChoice)
+			return nil
+		}
+	}
+
+	now := time.Now()
+	applied := false
+	var loop *loopIteration
+	var routeErr error
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		// A concurrent writer may have applied this completion already
+		nodeState := s.NodeStates[nodeID]
+		applied = executionActive(s.Status) && staleCompletion(nodeState, dispatchID, attempt) == ""
+		if !applied {
+			return
+		}
+		routeErr = nil
+
+		setNodeMetadata(nodeState, MetadataStateSeq, nextStateSeq(s))
+		nodeState.CompletedAt = &now
 		nodeState.Status = domain.ExecutionStatusCompleted
 		nodeState.Output = output
-	}
+		nodeState.Error = ""
+
+		// Record the router's choice so the successors can be recomputed later
+		if nextNodeID != "" {
+			setNodeMetadata(nodeState, MetadataNextNode, nextNodeID)
+		} else {
+			delete(nodeState.Metadata, MetadataNextNode)
+		}
 
-	// Save state
-	if err := m.storage.SaveState(ctx, state); err != nil {
+		// Loop edges restart their body instead of following the exit edges
+		loop = m.takeLoop(s, nodeID)
+		if loop == nil && nextNodeID == "" {
+			routeErr = recordEdgeSelection(s, nodeID)
+		}
+		if loop != nil {
+			// Human nodes of the previous iteration no longer wait
+			refreshAwaitingStatus(s)
+		}
+	})
+	if err != nil {
 		m.logger.Error("failed to save state after node completion",
 			zap.String("graph_id", graphID),
 			zap.Error(err))
 	}
-
-	// If node failed, mark graph as failed
-	if hasError {
-		m.completeGraph(ctx, graphID, state, domain.ExecutionStatusFailed, errorMsg)
+	if !applied {
 		return nil
 	}
 
-	// Determine next node
-	var nextNode string
-
-	if nextNodeID != "" {
-		// Router provided next node
-		nextNode = nextNodeID
-	} else {
-		// Find next node from edges
-		nextNode = m.findNextNode(state.Graph, nodeID)
+	// An output the edge conditions cannot route fails the node
+	if routeErr != nil {
+		m.failNode(ctx, graphID, state, nodeID, routeErr.Error(), ErrorClassCondition)
+		return nil
 	}
 
-	if nextNode == "" {
-		// No more nodes, graph complete
-		m.completeGraph(ctx, graphID, state, domain.ExecutionStatusCompleted, "")
+	if loop != nil {
+		m.startLoopIteration(ctx, graphID, state, loop)
 		return nil
 	}
 
-	// Publish work for next node
-	if err := m.publishNodeWork(ctx, graphID, nextNode, state); err != nil {
-		m.logger.Error("failed to publish next node work",
+	m.dispatchNodes(ctx, graphID, state, m.nextNodes(ctx, graphID, state, nodeID))
+
+	return nil
+}
+
+// failNode records a node failure and applies the failure policy
+func (m *Manager) failNode(ctx context.Context, graphID string, state *executionState, nodeID, errorMsg, errorClass string) {
+	// Transient failures are retried under the node's retry policy
+	if m.scheduleRetry(ctx, graphID, state, nodeID, errorMsg, errorClass) {
+		return
+	}
+
+	// on_error edges and the continue policy keep the graph going
+	handling := errorHandling(state.Graph, nodeID)
+
+	now := time.Now()
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[nodeID]
+		recordAttemptError(nodeState, errorMsg, errorClass)
+		nodeState.Status = domain.ExecutionStatusFailed
+		nodeState.Error = errorMsg
+		nodeState.CompletedAt = &now
+		if handling == string(ErrorPolicyContinue) {
+			// Followed like a node that completed without output
+			nodeState.Status = domain.ExecutionStatusCompleted
+			nodeState.Output = nil
+		}
+		if handling != "" {
+			setNodeMetadata(nodeState, MetadataErrorHandled, handling)
+		}
+	})
+	if err != nil {
+		m.logger.Error("failed to save state after node failure",
 			zap.String("graph_id", graphID),
-			zap.String("node_id", nextNode),
+			zap.String("node_id", nodeID),
 			zap.Error(err))
 	}
 
-	return nil
+	if handling != "" {
+		m.handleNodeError(ctx, graphID, state, nodeID, errorMsg, handling)
+		return
+	}
+
+	// An unhandled failure fails the graph
+	m.completeGraph(ctx, graphID, state, domain.ExecutionStatusFailed, errorMsg)
+}
+
+// successors returns the nodes that follow a completed node: the router's
+// choice or the edges selected by conditions when recorded, otherwise every
+// outgoing edge target. A failed node is followed by its on_error edges.
+func (m *Manager) successors(state *domain.GraphState, nodeID string) []string {
+	if nodeState := state.NodeStates[nodeID]; nodeState != nil {
+		if nodeState.Status == domain.ExecutionStatusFailed {
+			if errorRouted(nodeState) {
+				return errorTargets(state.Graph, nodeID)
+			}
+			return nil
+		}
+		if next, ok := nodeState.Metadata[MetadataNextNode].(string); ok && next != "" && !m.isLoopTarget(state.Graph, nodeID, next) {
+			// Router provided next node
+			return []string{next}
+		}
+		if next, ok := toStringSlice(nodeState.Metadata[MetadataNextNodes]); ok {
+			return next
+		}
+	}
+
+	// Conditions not recorded yet are evaluated against the current state
+	if hasEdgeConditions(state.Graph, nodeID) {
+		next, err := selectEdges(state, nodeID)
+		if err != nil {
+			return nil
+		}
+		return next
+	}
+
+	// Fan out to every outgoing edge
+	return m.findNextNodes(state.Graph, nodeID)
 }
 
-// findNextNode finds the next node to execute based on edges
-func (m *Manager) findNextNode(g *domain.Graph, currentNodeID string) string {
+// findNextNodes returns the targets of every outgoing edge of a node except
+// loop back-edges, which are followed by takeLoop, and on_error edges.
+// Router nodes will provide next_node explicitly.
+func (m *Manager) findNextNodes(g *domain.Graph, currentNodeID string) []string {
 	edges := g.GetOutgoingEdges(currentNodeID)
-	if len(edges) == 0 {
-		return ""
+	nextNodes := make([]string, 0, len(edges))
+	seen := make(map[string]bool, len(edges))
+	for _, edge := range edges {
+		if seen[edge.To] || isLoopEdge(edge) || isErrorEdge(edge) {
+			continue
+		}
+		seen[edge.To] = true
+		nextNodes = append(nextNodes, edge.To)
+	}
+	return nextNodes
+}
+
+// dispatchNodes publishes work for each of the given nodes and completes the
+// graph once no branch is left running
+func (m *Manager) dispatchNodes(ctx context.Context, graphID string, state *executionState, nodeIDs []string) {
+	// Another writer may have finished the execution in the meantime
+	if !executionActive(state.Status) {
+		return
+	}
+
+	// Once the outcome is reached only the finally node runs
+	if finalizing(state.GraphState) {
+		m.dispatchFinally(ctx, graphID, state, nodeIDs)
+		return
+	}
+
+	// A compensating execution only runs its compensation nodes, in order
+	if state.Status == ExecutionStatusCompensating {
+		if !m.compensate(ctx, graphID, state, "") {
+			m.completeGraph(ctx, graphID, state, domain.ExecutionStatusFailed, state.Error)
+		}
+		return
+	}
+
+	// A paused execution holds new dispatches back until it is resumed
+	if state.Status == ExecutionStatusPaused {
+		return
+	}
+
+	dispatched := true
+	for _, nodeID := range nodeIDs {
+		if err := m.publishNodeWork(ctx, graphID, nodeID, state); err != nil {
+			m.logger.Error("failed to publish node work",
+				zap.String("graph_id", graphID),
+				zap.String("node_id", nodeID),
+				zap.Error(err))
+			dispatched = false
+		}
+	}
+
+	// A failed dispatch leaves the execution to the graph timeout
+	if !dispatched || state.Status != domain.ExecutionStatusRunning {
+		return
+	}
+
+	if !hasRunningNodes(state.GraphState) {
+		// A branch that reached a node which cannot run leaves the graph unfinished
+		if stalled := m.stalledNodes(state.GraphState); len(stalled) > 0 {
+			m.completeGraph(ctx, graphID, state, domain.ExecutionStatusFailed,
+				fmt.Sprintf("nodes can no longer run: %s", strings.Join(stalled, ", ")))
+			return
+		}
+
+		// No more nodes, graph complete
+		m.completeGraph(ctx, graphID, state, domain.ExecutionStatusCompleted, "")
 	}
-	// For now, just take the first edge (linear flow)
-	// Router nodes will provide next_node explicitly
-	return edges[0].To
+}
+
+// hasRunningNodes reports whether any branch of the execution is still in flight
+func hasRunningNodes(state *domain.GraphState) bool {
+	for _, nodeState := range state.NodeStates {
+		if nodeState.Status == domain.ExecutionStatusRunning {
+			return true
+		}
+	}
+	return false
 }
 
 // publishNodeWork publishes a work event for a node
-func (m *Manager) publishNodeWork(ctx context.Context, graphID, nodeID string, state *domain.GraphState) error {
+func (m *Manager) publishNodeWork(ctx context.Context, graphID, nodeID string, state *executionState) error {
 	node := state.Graph.GetNode(nodeID)
 	if node == nil {
 		return fmt.Errorf("node not found: %s", nodeID)
 	}
 
-	// Update node state to running
-	nodeState := state.NodeStates[nodeID]
-	now := time.Now()
-	nodeState.Status = domain.ExecutionStatusRunning
-	nodeState.StartedAt = &now
-
-	if err := m.storage.SaveState(ctx, state); err != nil {
-		m.logger.Error("failed to save state before node work",
+	// Join nodes wait until their policy is satisfied
+	if !m.joinReady(state.GraphState, nodeID) {
+		m.logger.Debug("join node waiting for predecessors",
 			zap.String("graph_id", graphID),
-			zap.String("node_id", nodeID),
-			zap.Error(err))
+			zap.String("node_id", nodeID))
+		return nil
 	}
 
+	// The first predecessor of an any join wins the race
+	if raceJoin(state.Graph, nodeID) {
+		m.cancelLosers(ctx, graphID, state, nodeID)
+	}
+
+	now := time.Now()
+
 	// Determine topic based on node type
 	var topic string
 	switch node.GetType() {
@@ -293,20 +574,87 @@ func (m *Manager) publishNodeWork(ctx context.Context, graphID, nodeID string, s
 		topic = TopicExecutorWork
 	case graph.NodeTypeRouter:
 		topic = TopicRouterWork
+	case NodeTypeSubgraph:
+		// Subgraph nodes run a child execution instead of a worker
+		return m.startSubgraph(ctx, graphID, nodeID, state)
+	case NodeTypeMap:
+		// Map nodes dispatch their executor node once per item
+		return m.startMap(ctx, graphID, nodeID, state)
+	case NodeTypeHuman:
+		// Human nodes wait for a reviewer instead of a worker
+		return m.awaitInput(ctx, graphID, nodeID, state)
+	case NodeTypeWaitForSignal:
+		// Signal nodes wait for an outside system to post to their token
+		return m.awaitSignal(ctx, graphID, nodeID, state)
+	case NodeTypeDelay:
+		// Delay nodes hold back their branch on a timer
+		return m.startDelay(ctx, graphID, nodeID, state)
+	case NodeTypeAggregate:
+		// Aggregate nodes are evaluated by the orchestrator itself
+		return m.runAggregate(ctx, graphID, nodeID, state)
+	case NodeTypeTransform:
+		// Transform nodes are evaluated by the orchestrator itself
+		return m.runTransform(ctx, graphID, nodeID, state)
 	default:
-		// For other types (start, end), find next node
+		// Start and end nodes pass through without a worker
+		var routeErr error
+		err := m.updateState(ctx, state, func(s *domain.GraphState) {
+			nodeState := s.NodeStates[nodeID]
+			nodeState.StartedAt = &now
+			nodeState.Status = domain.ExecutionStatusCompleted
+			nodeState.CompletedAt = &now
+			routeErr = recordEdgeSelection(s, nodeID)
+		})
+		if err != nil {
+			m.logger.Error("failed to save state after pass-through node",
+				zap.String("graph_id", graphID),
+				zap.String("node_id", nodeID),
+				zap.Error(err))
+		}
+
+		// End nodes terminate their branch; the graph completes once every
+		// branch has finished
 		if node.GetType() == graph.NodeTypeEnd {
-			m.completeGraph(ctx, graphID, state, domain.ExecutionStatusCompleted, "")
 			return nil
 		}
-		// For start node, find next
-		nextNode := m.findNextNode(state.Graph, nodeID)
-		if nextNode != "" {
-			return m.publishNodeWork(ctx, graphID, nextNode, state)
+
+		if routeErr != nil {
+			m.failNode(ctx, graphID, state, nodeID, routeErr.Error(), ErrorClassCondition)
+			return nil
 		}
+
+		// For start node, continue with every selected successor
+		for _, nextNode := range m.nextNodes(ctx, graphID, state, nodeID) {
+			if err := m.publishNodeWork(ctx, graphID, nextNode, state); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Select the shared state the node works on
+	inputs, err := nodeInputs(node, SharedState(state.GraphState))
+	if err != nil {
+		m.failNode(ctx, graphID, state, nodeID, err.Error(), "")
 		return nil
 	}
 
+	// Update node state to running. Each dispatch gets its own ID so that
+	// completions can be matched to the attempt they belong to.
+	dispatchID := uuid.New().String()
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[nodeID]
+		nodeState.StartedAt = &now
+		nodeState.Status = domain.ExecutionStatusRunning
+		setNodeMetadata(nodeState, MetadataDispatchID, dispatchID)
+	})
+	if err != nil {
+		m.logger.Error("failed to save state before node work",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+	}
+
 	// Build work event
 	event := ports.Event{
 		ID:          uuid.New().String(),
@@ -314,14 +662,34 @@ func (m *Manager) publishNodeWork(ctx context.Context, graphID, nodeID string, s
 		Timestamp:   time.Now(),
 		ExecutionID: graphID,
 		Data: map[string]interface{}{
-			"node_id":    nodeID,
-			"node_type":  string(node.GetType()),
-			"graph_id":   graphID,
-			"state":      state.Inputs,
-			"node_state": state.NodeStates,
+			"node_id":     nodeID,
+			"node_type":   string(node.GetType()),
+			"graph_id":    graphID,
+			"state":       inputs,
+			"node_state":  state.NodeStates,
+			"dispatch_id": dispatchID,
+			"attempt":     nodeAttempt(state.NodeStates[nodeID]),
 		},
 	}
 
+	// Join nodes receive the outputs of every completed predecessor
+	if len(predecessors(state.Graph, nodeID)) > 1 {
+		event.Data["predecessor_outputs"] = joinOutputs(state.GraphState, nodeID)
+	}
+
+	// Compensation nodes receive the node they undo and its output
+	if compensated := compensatedNode(state.Graph, nodeID); compensated != "" {
+		event.Data["compensate"] = map[string]interface{}{
+			"node_id": compensated,
+			"output":  state.NodeStates[compensated].Output,
+		}
+	}
+
+	// Error handlers receive the errors of the failed nodes routed to them
+	if failures := handledErrors(state.GraphState, nodeID); len(failures) > 0 {
+		event.Data["errors"] = failures
+	}
+
 	m.logger.Info("publishing node work",
 		zap.String("topic", topic),
 		zap.String("graph_id", graphID),
@@ -332,6 +700,9 @@ func (m *Manager) publishNodeWork(ctx context.Context, graphID, nodeID string, s
 		return fmt.Errorf("failed to publish work event: %w", err)
 	}
 
+	// Fail the node if the worker does not report back in time
+	m.armNodeTimeout(graphID, nodeID, now, m.nodeTimeoutFor(node))
+
 	// Publish node started event (ignore error as it's non-critical)
 	_ = m.publishGraphEvent(ctx, graphID, domain.EventTypeNodeStarted, map[string]interface{}{
 		"node_id": nodeID,
@@ -340,16 +711,40 @@ func (m *Manager) publishNodeWork(ctx context.Context, graphID, nodeID string, s
 	return nil
 }
 
-// completeGraph marks a graph execution as complete
-func (m *Manager) completeGraph(ctx context.Context, graphID string, state *domain.GraphState, status domain.ExecutionStatus, errorMsg string) {
-	now := time.Now()
-	state.Status = status
-	state.CompletedAt = &now
-	if errorMsg != "" {
-		state.Error = errorMsg
+// completeGraph marks a graph execution as complete, once the compensations
+// of a failed execution and its finally node have run
+func (m *Manager) completeGraph(ctx context.Context, graphID string, state *executionState, status domain.ExecutionStatus, errorMsg string) {
+	// A completed execution whose state does not match the output schema fails
+	if status == domain.ExecutionStatusCompleted {
+		if err := checkOutput(state.GraphState); err != nil {
+			status = domain.ExecutionStatusFailed
+			errorMsg = err.Error()
+		}
+	}
+
+	if status == domain.ExecutionStatusFailed {
+		if m.compensate(ctx, graphID, state, errorMsg) {
+			return
+		}
+		// The graph fails with the error that started the compensation
+		if state.Status == ExecutionStatusCompensating {
+			errorMsg = state.Error
+		}
 	}
 
-	if err := m.storage.SaveState(ctx, state); err != nil {
+	if m.runFinally(ctx, graphID, state, status, errorMsg) {
+		return
+	}
+
+	now := time.Now()
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		s.Status = status
+		s.CompletedAt = &now
+		if errorMsg != "" {
+			s.Error = errorMsg
+		}
+	})
+	if err != nil {
 		m.logger.Error("failed to save final state",
 			zap.String("graph_id", graphID),
 			zap.Error(err))
@@ -359,13 +754,24 @@ func (m *Manager) completeGraph(ctx context.Context, graphID string, state *doma
 	if val, ok := m.executions.Load(graphID); ok {
 		execCtx := val.(*executionContext)
 		execCtx.cancelFunc()
+		stopNodeTimers(execCtx)
 		m.executions.Delete(graphID)
 	}
+	m.releaseExecution(ctx, graphID)
+
+	// Child executions of abandoned subgraph nodes are no longer needed, and
+	// the subgraph node of a child execution completes with it
+	m.cancelChildren(ctx, state.GraphState)
+	m.notifyParent(ctx, state.GraphState, status, errorMsg)
 
 	// Publish completion event
 	eventType := domain.EventTypeGraphCompleted
-	if status == domain.ExecutionStatusFailed {
+	switch status {
+	case domain.ExecutionStatusFailed:
 		eventType = domain.EventTypeGraphFailed
+	case domain.ExecutionStatusCancelled:
+		// Cancelled executions end here once their finally node has run
+		eventType = domain.EventTypeGraphCancelled
 	}
 
 	data := map[string]interface{}{}
@@ -406,20 +812,17 @@ func (m *Manager) publishGraphEvent(ctx context.Context, graphID string, eventTy
 
 // GetStatus retrieves the current status of a graph execution
 func (m *Manager) GetStatus(ctx context.Context, graphID string) (*domain.GraphState, error) {
-	stateInterface, err := m.storage.GetState(ctx, graphID)
+	state, err := m.loadState(ctx, graphID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get state: %w", err)
-	}
-
-	state, ok := stateInterface.(*domain.GraphState)
-	if !ok {
-		return nil, fmt.Errorf("invalid state type")
+		return nil, err
 	}
-
-	return state, nil
+	return state.GraphState, nil
 }
 
-// CancelExecution cancels a running graph execution
+// CancelExecution cancels a running graph execution. A graph with a finally
+// node abandons its branches in flight and runs the finally node first; the
+// execution ends cancelled once it has. Compensating executions and those
+// already running their finally node stop at once.
 func (m *Manager) CancelExecution(ctx context.Context, graphID string) error {
 	// Get execution context
 	val, ok := m.executions.Load(graphID)
@@ -438,26 +841,33 @@ func (m *Manager) CancelExecution(ctx context.Context, graphID string) error {
 		return fmt.Errorf("execution already in terminal state: %s", execCtx.status)
 	}
 
-	// Cancel context
-	execCtx.cancelFunc()
-	execCtx.status = domain.ExecutionStatusCancelled
-
-	// Update state in storage
-	stateInterface, err := m.storage.GetState(ctx, graphID)
+	state, err := m.loadState(ctx, graphID)
 	if err != nil {
-		return fmt.Errorf("failed to get state: %w", err)
+		return err
 	}
 
-	state, ok := stateInterface.(*domain.GraphState)
-	if !ok {
-		return fmt.Errorf("invalid state type")
+	// The finally node runs before the execution ends cancelled
+	if state.Status != ExecutionStatusCompensating && !finalizing(state.GraphState) {
+		m.cancelChildren(ctx, state.GraphState)
+		if m.runFinally(ctx, graphID, state, domain.ExecutionStatusCancelled, "") {
+			m.logger.Info("graph execution cancelling, running finally node",
+				zap.String("graph_id", graphID))
+			return nil
+		}
 	}
 
-	now := time.Now()
-	state.Status = domain.ExecutionStatusCancelled
-	state.CompletedAt = &now
+	// Cancel context
+	execCtx.cancelFunc()
+	stopNodeTimers(execCtx)
+	execCtx.status = domain.ExecutionStatusCancelled
 
-	if err := m.storage.SaveState(ctx, state); err != nil {
+	// Update state in storage
+	now := time.Now()
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		s.Status = domain.ExecutionStatusCancelled
+		s.CompletedAt = &now
+	})
+	if err != nil {
 		return fmt.Errorf("failed to save state: %w", err)
 	}
 
@@ -465,6 +875,12 @@ func (m *Manager) CancelExecution(ctx context.Context, graphID string) error {
 	_ = m.publishGraphEvent(ctx, graphID, domain.EventTypeGraphCancelled, nil)
 
 	m.executions.Delete(graphID)
+	m.releaseExecution(ctx, graphID)
+
+	// Cancellation cascades to child executions and fails the subgraph node
+	// of a cancelled child
+	m.cancelChildren(ctx, state.GraphState)
+	m.notifyParent(ctx, state.GraphState, domain.ExecutionStatusCancelled, "")
 
 	m.logger.Info("graph execution cancelled",
 		zap.String("graph_id", graphID))
@@ -472,6 +888,19 @@ func (m *Manager) CancelExecution(ctx context.Context, graphID string) error {
 	return nil
 }
 
+// lockExecution serializes state updates for a single execution and returns
+// the function that releases the lock
+func (m *Manager) lockExecution(graphID string) func() {
+	val, ok := m.executions.Load(graphID)
+	if !ok {
+		return func() {}
+	}
+
+	execCtx := val.(*executionContext)
+	execCtx.mu.Lock()
+	return execCtx.mu.Unlock
+}
+
 // monitorExecution monitors graph execution and handles timeouts
 func (m *Manager) monitorExecution(ctx context.Context, graphID string) {
 	<-ctx.Done()
@@ -484,13 +913,13 @@ func (m *Manager) monitorExecution(ctx context.Context, graphID string) {
 
 // handleTimeout handles graph execution timeout
 func (m *Manager) handleTimeout(graphID string) {
-	m.logger.Warn("graph execution timed out",
-		zap.String("graph_id", graphID))
-
 	ctx := context.Background()
 
+	unlock := m.lockExecution(graphID)
+	defer unlock()
+
 	// Update state
-	stateInterface, err := m.storage.GetState(ctx, graphID)
+	state, err := m.loadState(ctx, graphID)
 	if err != nil {
 		m.logger.Error("failed to get state during timeout",
 			zap.String("graph_id", graphID),
@@ -498,13 +927,19 @@ func (m *Manager) handleTimeout(graphID string) {
 		return
 	}
 
-	state, ok := stateInterface.(*domain.GraphState)
-	if !ok {
-		m.logger.Error("invalid state type during timeout",
-			zap.String("graph_id", graphID))
+	// The deadline is suspended while paused, and extended once resumed: a
+	// deadline armed before a pause is left to the one armed on resume
+	if state.Status == ExecutionStatusPaused || time.Now().Before(m.graphDeadline(state.GraphState)) {
+		return
+	}
+	// A deadline kept as a durable timer may outlive the execution
+	if !executionActive(state.Status) {
 		return
 	}
 
+	m.logger.Warn("graph execution timed out",
+		zap.String("graph_id", graphID))
+
 	m.completeGraph(ctx, graphID, state, domain.ExecutionStatusFailed, "execution timeout")
 }
 
@@ -515,10 +950,15 @@ func (m *Manager) Shutdown(ctx context.Context) error {
 	// Cancel subscriptions
 	m.cancel()
 
-	// Cancel all active executions
+	// Cancel all active executions; their leases are given up so that
+	// other replicas recover them without waiting for the leases to lapse
 	m.executions.Range(func(key, value interface{}) bool {
 		execCtx := value.(*executionContext)
+		execCtx.mu.Lock()
 		execCtx.cancelFunc()
+		stopNodeTimers(execCtx)
+		execCtx.mu.Unlock()
+		m.releaseExecution(ctx, key.(string))
 		return true
 	})
 
@@ -0,0 +1,60 @@
+package orchestrator
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+func TestFanOutDispatchesEveryTargetInParallel(t *testing.T) {
+	branches := []string{"a", "b", "c"}
+
+	// Each branch holds its worker until every branch was dispatched, which
+	// only happens when they run side by side
+	var mu sync.Mutex
+	arrived := 0
+	allArrived := make(chan struct{})
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		switch data["node_id"] {
+		case "a", "b", "c":
+			mu.Lock()
+			arrived++
+			if arrived == len(branches) {
+				close(allArrived)
+			}
+			mu.Unlock()
+
+			select {
+			case <-allArrived:
+			case <-time.After(2 * time.Second):
+				return map[string]interface{}{"error": "branches were not dispatched in parallel"}
+			}
+		}
+		return map[string]interface{}{"output": map[string]interface{}{}}
+	})
+
+	graphNodes := []graph.Node{startNode("start"), executorNode("join", nil), endNode("end")}
+	edges := []*graph.Edge{edge("join", "end")}
+	for _, branch := range branches {
+		graphNodes = append(graphNodes, executorNode(branch, nil))
+		edges = append(edges, edge("start", branch), edge(branch, "join"))
+	}
+	graphID := h.submit(t, newGraph("start", graphNodes, edges...), nil)
+
+	state := h.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	for _, branch := range branches {
+		assertNodeStatus(t, state, branch, domain.ExecutionStatusCompleted)
+		if got := len(h.workFor(branch)); got != 1 {
+			t.Errorf("branch %s dispatched %d times, want 1", branch, got)
+		}
+	}
+	if got := len(h.workFor("join")); got != 1 {
+		t.Errorf("join dispatched %d times, want 1", got)
+	}
+}
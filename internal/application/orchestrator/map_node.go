@@ -0,0 +1,822 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/aescanero/dago/internal/expression"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// NodeTypeMap is the type of nodes that run an executor node once per
+// element of a list
+const NodeTypeMap graph.NodeType = "map"
+
+// Map node settings
+const (
+	// ConfigMapItems is the expression over the shared state selecting the list
+	ConfigMapItems = "items"
+
+	// ConfigMapNode names the executor node run for every item
+	ConfigMapNode = "node"
+
+	// ConfigMapConcurrency caps the items in flight at once (0 for no cap)
+	ConfigMapConcurrency = "concurrency"
+
+	// ConfigMapMaxFailures is the number of failed items tolerated (default 0)
+	ConfigMapMaxFailures = "max_failures"
+)
+
+// MetadataMapItems records the sub-state of every item of a map node
+const MetadataMapItems = "items"
+
+// ErrorClassMap is the error class of a map node with too many failed items
+const ErrorClassMap = "map"
+
+// Graph events of map item progress
+const (
+	EventTypeMapItemCompleted domain.EventType = "map.item_completed"
+	EventTypeMapItemFailed    domain.EventType = "map.item_failed"
+)
+
+// mapItem is the sub-state of one element of a map node's list
+type mapItem struct {
+	Index       int                    `json:"index"`
+	Item        interface{}            `json:"item"`
+	Status      domain.ExecutionStatus `json:"status"`
+	DispatchID  string                 `json:"dispatch_id,omitempty"`
+	Attempt     int                    `json:"attempt,omitempty"`
+	RetryAt     *time.Time             `json:"retry_at,omitempty"`
+	Output      interface{}            `json:"output,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	StartedAt   *time.Time             `json:"started_at,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+}
+
+// mapSpec holds the settings of a map node
+type mapSpec struct {
+	Items       string
+	Node        string
+	Concurrency int
+	MaxFailures int
+}
+
+// attempt returns the current attempt number of an item (1-based)
+func (item *mapItem) attempt() int {
+	if item.Attempt > 0 {
+		return item.Attempt
+	}
+	return 1
+}
+
+// parseMapSpec reads the settings of a map node
+func parseMapSpec(cfg map[string]interface{}) (mapSpec, error) {
+	spec := mapSpec{
+		Items: configString(cfg, ConfigMapItems),
+		Node:  configString(cfg, ConfigMapNode),
+	}
+	if spec.Items == "" {
+		return spec, fmt.Errorf("%s must be an expression selecting a list", ConfigMapItems)
+	}
+	if _, err := expression.Compile(spec.Items); err != nil {
+		return spec, fmt.Errorf("%s: %w", ConfigMapItems, err)
+	}
+	if spec.Node == "" {
+		return spec, fmt.Errorf("%s must name an executor node", ConfigMapNode)
+	}
+
+	for key, target := range map[string]*int{
+		ConfigMapConcurrency: &spec.Concurrency,
+		ConfigMapMaxFailures: &spec.MaxFailures,
+	} {
+		if _, ok := cfg[key]; !ok {
+			continue
+		}
+		n, ok := configInt(cfg, key)
+		if !ok || n < 0 {
+			return spec, fmt.Errorf("%s must be a non-negative integer", key)
+		}
+		*target = n
+	}
+	return spec, nil
+}
+
+// mapNodeOf returns the map node that runs nodeID for its items, or ""
+func mapNodeOf(g *domain.Graph, nodeID string) string {
+	for _, id := range sortedNodeIDs(g) {
+		node := g.Nodes[id]
+		if node.GetType() == NodeTypeMap && configString(nodeConfig(node), ConfigMapNode) == nodeID {
+			return id
+		}
+	}
+	return ""
+}
+
+// validateMaps checks that every map node runs an executor outside the
+// edges of the graph that no other map node runs
+func validateMaps(g *domain.Graph) error {
+	runBy := make(map[string]string)
+	for _, mapID := range sortedNodeIDs(g) {
+		if g.Nodes[mapID].GetType() != NodeTypeMap {
+			continue
+		}
+		nodeID := configString(nodeConfig(g.Nodes[mapID]), ConfigMapNode)
+
+		node, exists := g.Nodes[nodeID]
+		switch {
+		case !exists:
+			return fmt.Errorf("node %s of map node %s not found in graph", nodeID, mapID)
+		case node.GetType() != graph.NodeTypeExecutor:
+			return fmt.Errorf("node %s of map node %s must be an executor", nodeID, mapID)
+		case nodeID == g.EntryNode || nodeID == finallyNode(g) || compensatedNode(g, nodeID) != "":
+			return fmt.Errorf("node %s cannot be run by a map node", nodeID)
+		case hasEdges(g, nodeID):
+			return fmt.Errorf("node %s of map node %s cannot have edges", nodeID, mapID)
+		}
+
+		if other, taken := runBy[nodeID]; taken {
+			return fmt.Errorf("node %s is run by both map nodes %s and %s", nodeID, other, mapID)
+		}
+		runBy[nodeID] = mapID
+	}
+	return nil
+}
+
+// mapItems returns a copy of the item sub-states of a map node
+func mapItems(nodeState *domain.NodeState) []*mapItem {
+	raw, ok := nodeState.Metadata[MetadataMapItems]
+	if !ok {
+		return nil
+	}
+
+	// Metadata holds the items as written in memory and as generic values
+	// once the state went through JSON
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var items []*mapItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil
+	}
+	return items
+}
+
+// updateMapItem applies update to one item sub-state of a map node
+func updateMapItem(nodeState *domain.NodeState, index int, update func(*mapItem)) {
+	updateMapItems(nodeState, func(items []*mapItem) {
+		if index >= 0 && index < len(items) {
+			update(items[index])
+		}
+	})
+}
+
+// updateMapItems applies update to the item sub-states of a map node,
+// decoding and storing them once however many items it changes
+func updateMapItems(nodeState *domain.NodeState, update func([]*mapItem)) {
+	items := mapItems(nodeState)
+	update(items)
+	setNodeMetadata(nodeState, MetadataMapItems, items)
+}
+
+// mapItemTimer is the key of an item's deadline among the node timers
+func mapItemTimer(mapID string, index int) string {
+	return fmt.Sprintf("%s[%d]", mapID, index)
+}
+
+// startMap evaluates a map node's list and dispatches its first items.
+// Callers must hold the execution lock.
+func (m *Manager) startMap(ctx context.Context, graphID, mapID string, state *executionState) error {
+	node := state.Graph.GetNode(mapID)
+	spec, err := parseMapSpec(nodeConfig(node))
+	if err != nil {
+		m.failNode(ctx, graphID, state, mapID, err.Error(), "")
+		return nil
+	}
+
+	list, err := evalMapItems(state.GraphState, spec)
+	if err != nil {
+		m.failNode(ctx, graphID, state, mapID, err.Error(), ErrorClassMap)
+		return nil
+	}
+
+	now := time.Now()
+	dispatchID := uuid.New().String()
+	items := make([]*mapItem, len(list))
+	for i, element := range list {
+		items[i] = &mapItem{Index: i, Item: element, Status: domain.ExecutionStatusPending}
+	}
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[mapID]
+		nodeState.StartedAt = &now
+		nodeState.Status = domain.ExecutionStatusRunning
+		setNodeMetadata(nodeState, MetadataDispatchID, dispatchID)
+		setNodeMetadata(nodeState, MetadataMapItems, items)
+	})
+	if err != nil {
+		m.logger.Error("failed to save state before map items",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", mapID),
+			zap.Error(err))
+	}
+
+	m.logger.Info("starting map node",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", mapID),
+		zap.Int("items", len(items)))
+
+	// The map node only times out when it sets a timeout of its own
+	m.armNodeTimeout(graphID, mapID, now, m.nodeTimeoutFor(node))
+
+	// Publish node started event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, domain.EventTypeNodeStarted, map[string]interface{}{
+		"node_id": mapID,
+		"items":   len(items),
+	})
+
+	m.advanceMap(ctx, graphID, state, mapID)
+	return nil
+}
+
+// evalMapItems evaluates the list a map node fans out over
+func evalMapItems(state *domain.GraphState, spec mapSpec) ([]interface{}, error) {
+	program, err := expression.Compile(spec.Items)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ConfigMapItems, err)
+	}
+	value, err := program.Eval(mappingEnv(SharedState(state), "state"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ConfigMapItems, err)
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+	if list, ok := value.([]interface{}); ok {
+		return list, nil
+	}
+
+	// Inputs given from Go may hold typed slices
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%s must select a list, got %T", ConfigMapItems, value)
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, nil
+}
+
+// advanceMap dispatches pending items up to the concurrency cap, and reports
+// the map node back once its items finished or too many of them failed.
+// Callers must hold the execution lock.
+func (m *Manager) advanceMap(ctx context.Context, graphID string, state *executionState, mapID string) {
+	nodeState := state.NodeStates[mapID]
+	spec, _ := parseMapSpec(nodeConfig(state.Graph.GetNode(mapID)))
+	items := mapItems(nodeState)
+
+	running, failed := 0, 0
+	var pending []int
+	var firstFailure *mapItem
+	for _, item := range items {
+		switch item.Status {
+		case domain.ExecutionStatusPending:
+			pending = append(pending, item.Index)
+		case domain.ExecutionStatusRunning:
+			running++
+		case domain.ExecutionStatusFailed:
+			failed++
+			if firstFailure == nil {
+				firstFailure = item
+			}
+		}
+	}
+
+	if failed > spec.MaxFailures {
+		m.finishMap(ctx, graphID, state, mapID, nil,
+			fmt.Sprintf("%d of %d map items failed, first item %d: %s", failed, len(items), firstFailure.Index, firstFailure.Error))
+		return
+	}
+
+	// A paused execution holds pending items back until it is resumed
+	if state.Status == ExecutionStatusPaused && len(pending) > 0 {
+		return
+	}
+
+	var batch []*mapItem
+	for _, index := range pending {
+		if spec.Concurrency > 0 && running+len(batch) >= spec.Concurrency {
+			break
+		}
+		batch = append(batch, items[index])
+	}
+	if len(batch) > 0 {
+		// Items that could not be dispatched failed; count them first
+		if m.dispatchMapItems(ctx, graphID, state, mapID, spec, batch) < len(batch) {
+			m.advanceMap(ctx, graphID, state, mapID)
+			return
+		}
+		running += len(batch)
+	}
+
+	if running > 0 {
+		return
+	}
+
+	// Gather the results in list order; failed items tolerated leave a gap
+	results := make([]interface{}, len(items))
+	for _, item := range mapItems(state.NodeStates[mapID]) {
+		if item.Status == domain.ExecutionStatusCompleted {
+			results[item.Index] = item.Output
+		}
+	}
+	m.finishMap(ctx, graphID, state, mapID, results, "")
+}
+
+// mapDispatch is the work of one map item about to be dispatched
+type mapDispatch struct {
+	index      int
+	attempt    int
+	dispatchID string
+	inputs     map[string]interface{}
+	err        error
+}
+
+// dispatchMapItems publishes the work events of a batch of items of a map
+// node. The items are recorded as running in one state update before their
+// work is published, so that completions find them whichever replica they
+// reach. It returns how many items were dispatched. Callers must hold the
+// execution lock.
+func (m *Manager) dispatchMapItems(ctx context.Context, graphID string, state *executionState, mapID string, spec mapSpec, batch []*mapItem) int {
+	node := state.Graph.GetNode(spec.Node)
+	shared := SharedState(state.GraphState)
+
+	dispatches := make([]*mapDispatch, len(batch))
+	for i, item := range batch {
+		// Items see the shared state with their element and its position added
+		itemState := make(map[string]interface{}, len(shared)+2)
+		for key, value := range shared {
+			itemState[key] = value
+		}
+		itemState["item"] = item.Item
+		itemState["item_index"] = item.Index
+
+		inputs, err := nodeInputs(node, itemState)
+		dispatches[i] = &mapDispatch{
+			index:      item.Index,
+			attempt:    item.attempt(),
+			dispatchID: uuid.New().String(),
+			inputs:     inputs,
+			err:        err,
+		}
+	}
+
+	now := time.Now()
+	m.saveMapDispatches(ctx, graphID, state, mapID, dispatches, now)
+
+	// Workers get the map node's state without the item sub-states, which
+	// would make every work event as large as the whole list
+	parent := mapNodeSummary(state.NodeStates[mapID])
+
+	dispatched := 0
+	var failed []*mapDispatch
+	for _, d := range dispatches {
+		if d.err == nil {
+			d.err = m.publishMapItem(ctx, graphID, mapID, node, parent, d)
+			if d.err != nil {
+				failed = append(failed, d)
+			}
+		}
+		if d.err != nil {
+			m.logger.Warn("failed to dispatch map item",
+				zap.String("graph_id", graphID),
+				zap.String("node_id", mapID),
+				zap.Int("item_index", d.index),
+				zap.Error(d.err))
+			continue
+		}
+		dispatched++
+
+		// Fail the item if the worker does not report back in time
+		if timeout := m.nodeTimeoutFor(node); timeout > 0 {
+			m.armNodeTimer(nodeTimer{
+				Kind:       timerMapItemTimeout,
+				GraphID:    graphID,
+				NodeID:     mapID,
+				ItemIndex:  d.index,
+				DispatchID: d.dispatchID,
+				Timeout:    timeout,
+			}, timeout)
+		}
+	}
+
+	if len(failed) > 0 {
+		m.saveMapDispatches(ctx, graphID, state, mapID, failed, now)
+	}
+	return dispatched
+}
+
+// saveMapDispatches records a batch of dispatched map items in one state
+// update: running under their dispatch ID, or failed if their work could
+// not be built or published
+func (m *Manager) saveMapDispatches(ctx context.Context, graphID string, state *executionState, mapID string, dispatches []*mapDispatch, now time.Time) {
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		updateMapItems(s.NodeStates[mapID], func(items []*mapItem) {
+			for _, d := range dispatches {
+				if d.index < 0 || d.index >= len(items) {
+					continue
+				}
+				item := items[d.index]
+				item.StartedAt = &now
+				if d.err != nil {
+					item.Status = domain.ExecutionStatusFailed
+					item.Error = d.err.Error()
+					item.CompletedAt = &now
+					continue
+				}
+				item.Status = domain.ExecutionStatusRunning
+				item.DispatchID = d.dispatchID
+				item.Attempt = d.attempt
+			}
+		})
+	})
+	if err != nil {
+		m.logger.Error("failed to save map item states",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", mapID),
+			zap.Int("items", len(dispatches)),
+			zap.Error(err))
+	}
+}
+
+// mapNodeSummary copies a map node's state without its item sub-states
+func mapNodeSummary(nodeState *domain.NodeState) *domain.NodeState {
+	summary := *nodeState
+	summary.Metadata = make(map[string]interface{}, len(nodeState.Metadata))
+	for key, value := range nodeState.Metadata {
+		if key != MetadataMapItems {
+			summary.Metadata[key] = value
+		}
+	}
+	return &summary
+}
+
+// publishMapItem publishes an item's work event, which looks like the work
+// event of the executor node the map runs. Its node_state only holds the
+// map node's state, summarized.
+func (m *Manager) publishMapItem(ctx context.Context, graphID, mapID string, node graph.Node, parent *domain.NodeState, d *mapDispatch) error {
+	event := ports.Event{
+		ID:          uuid.New().String(),
+		Type:        ports.EventType("node.work"),
+		Timestamp:   time.Now(),
+		ExecutionID: graphID,
+		Data: map[string]interface{}{
+			"node_id":     node.GetID(),
+			"node_type":   string(node.GetType()),
+			"graph_id":    graphID,
+			"state":       d.inputs,
+			"node_state":  map[string]*domain.NodeState{mapID: parent},
+			"dispatch_id": d.dispatchID,
+			"attempt":     d.attempt,
+			"map_node":    mapID,
+			"item_index":  d.index,
+		},
+	}
+
+	m.logger.Info("publishing map item work",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", mapID),
+		zap.Int("item_index", d.index),
+		zap.Int("attempt", d.attempt))
+
+	if err := m.eventBus.Publish(ctx, TopicExecutorWork, event); err != nil {
+		return fmt.Errorf("failed to publish work event: %w", err)
+	}
+	return nil
+}
+
+// completeMapItem records the completion of a map item reported under the
+// executor node the map runs. Items are matched by the dispatch_id workers
+// echo; a completion without one, from a worker that predates dispatch ids,
+// applies to the item it names by item_index. Callers must hold the
+// execution lock.
+func (m *Manager) completeMapItem(ctx context.Context, graphID string, state *executionState, mapID, dispatchID string, itemIndex int, output interface{}, errorMsg, errorClass string) {
+	nodeState := state.NodeStates[mapID]
+	index := -1
+	if nodeState.Status == domain.ExecutionStatusRunning {
+		for _, item := range mapItems(nodeState) {
+			// Items waiting for a retry have no work out
+			if item.Status != domain.ExecutionStatusRunning || item.RetryAt != nil {
+				continue
+			}
+			if dispatchID != "" && item.DispatchID == dispatchID || dispatchID == "" && item.Index == itemIndex {
+				index = item.Index
+			}
+		}
+	}
+	if index < 0 {
+		m.logger.Info("ignoring stale map item completion",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", mapID),
+			zap.String("dispatch_id", dispatchID),
+			zap.Int("item_index", itemIndex))
+		return
+	}
+
+	if dispatchID == "" {
+		m.logger.Warn("accepting map item completion without dispatch id; workers must echo it",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", mapID),
+			zap.Int("item_index", index))
+	}
+
+	m.stopNodeTimeout(graphID, mapItemTimer(mapID, index))
+	if errorMsg != "" && m.scheduleMapItemRetry(ctx, graphID, state, mapID, index, errorMsg, errorClass) {
+		return
+	}
+	m.settleMapItem(ctx, graphID, state, mapID, index, output, errorMsg)
+	m.advanceMap(ctx, graphID, state, mapID)
+}
+
+// scheduleMapItemRetry arranges another attempt for a failed map item when
+// the retry policy of the executor node the map runs allows it. The item
+// keeps its place among the items in flight while it waits. Callers must
+// hold the execution lock.
+func (m *Manager) scheduleMapItemRetry(ctx context.Context, graphID string, state *executionState, mapID string, index int, errorMsg, errorClass string) bool {
+	spec, err := parseMapSpec(nodeConfig(state.Graph.GetNode(mapID)))
+	if err != nil {
+		return false
+	}
+	policy, err := parseRetryPolicy(nodeConfig(state.Graph.GetNode(spec.Node)))
+	if err != nil {
+		return false
+	}
+
+	items := mapItems(state.NodeStates[mapID])
+	if index < 0 || index >= len(items) {
+		return false
+	}
+	attempt := items[index].attempt()
+	if attempt >= policy.MaxAttempts || !policy.Retryable(errorMsg, errorClass) {
+		return false
+	}
+
+	nextAttempt := attempt + 1
+	delay := policy.Backoff(nextAttempt)
+	retryAt := time.Now().Add(delay)
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		updateMapItem(s.NodeStates[mapID], index, func(item *mapItem) {
+			item.Attempt = nextAttempt
+			item.Error = errorMsg
+			item.DispatchID = ""
+			item.RetryAt = &retryAt
+		})
+	})
+	if err != nil {
+		m.logger.Error("failed to save state before map item retry",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", mapID),
+			zap.Int("item_index", index),
+			zap.Error(err))
+	}
+
+	m.logger.Info("scheduling map item retry",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", mapID),
+		zap.Int("item_index", index),
+		zap.Int("attempt", nextAttempt),
+		zap.Int("max_attempts", policy.MaxAttempts),
+		zap.Duration("backoff", delay))
+
+	// Publish retry event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeRetrying, map[string]interface{}{
+		"node_id":    mapID,
+		"item_index": index,
+		"attempt":    nextAttempt,
+		"error":      errorMsg,
+		"retry_at":   retryAt,
+	})
+
+	m.armNodeTimer(nodeTimer{Kind: timerMapItemRetry, GraphID: graphID, NodeID: mapID, ItemIndex: index, Attempt: nextAttempt}, delay)
+	return true
+}
+
+// retryMapItem puts a map item back among the pending items once its
+// backoff has elapsed
+func (m *Manager) retryMapItem(graphID, mapID string, index, attempt int) {
+	ctx := context.Background()
+
+	unlock := m.lockExecution(graphID)
+	defer unlock()
+
+	state, err := m.loadState(ctx, graphID)
+	if err != nil {
+		m.logger.Error("failed to get state for map item retry",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", mapID),
+			zap.Error(err))
+		return
+	}
+	if !executionActive(state.Status) || abandonedCompletion(state.GraphState, mapID) != "" {
+		return
+	}
+
+	nodeState := state.NodeStates[mapID]
+	if nodeState == nil || nodeState.Status != domain.ExecutionStatusRunning {
+		return
+	}
+	items := mapItems(nodeState)
+	if index < 0 || index >= len(items) {
+		return
+	}
+	item := items[index]
+	if item.Status != domain.ExecutionStatusRunning || item.RetryAt == nil || item.attempt() != attempt {
+		return
+	}
+
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		updateMapItem(s.NodeStates[mapID], index, func(item *mapItem) {
+			item.Status = domain.ExecutionStatusPending
+			item.RetryAt = nil
+		})
+	})
+	if err != nil {
+		m.logger.Error("failed to save state before map item retry",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", mapID),
+			zap.Int("item_index", index),
+			zap.Error(err))
+	}
+
+	m.advanceMap(ctx, graphID, state, mapID)
+}
+
+// settleMapItem records the outcome of a map item and publishes it as a
+// graph event
+func (m *Manager) settleMapItem(ctx context.Context, graphID string, state *executionState, mapID string, index int, output interface{}, errorMsg string) {
+	now := time.Now()
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		updateMapItem(s.NodeStates[mapID], index, func(item *mapItem) {
+			item.CompletedAt = &now
+			if errorMsg != "" {
+				item.Status = domain.ExecutionStatusFailed
+				item.Error = errorMsg
+				return
+			}
+			item.Status = domain.ExecutionStatusCompleted
+			item.Output = output
+			item.Error = ""
+		})
+	})
+	if err != nil {
+		m.logger.Error("failed to save map item state",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", mapID),
+			zap.Int("item_index", index),
+			zap.Error(err))
+	}
+
+	eventType := EventTypeMapItemCompleted
+	data := map[string]interface{}{
+		"node_id":    mapID,
+		"item_index": index,
+	}
+	if errorMsg != "" {
+		eventType = EventTypeMapItemFailed
+		data["error"] = errorMsg
+	}
+
+	// Publish item event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, eventType, data)
+}
+
+// finishMap reports a map node back the way a worker reports a node, so that
+// its completion goes through the usual edge, loop and failure handling.
+// Items still in flight are abandoned.
+func (m *Manager) finishMap(ctx context.Context, graphID string, state *executionState, mapID string, results []interface{}, errorMsg string) {
+	nodeState := state.NodeStates[mapID]
+	now := time.Now()
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		mapState := s.NodeStates[mapID]
+		items := mapItems(mapState)
+		for _, item := range items {
+			if item.Status == domain.ExecutionStatusPending || item.Status == domain.ExecutionStatusRunning {
+				item.Status = domain.ExecutionStatusCancelled
+				item.CompletedAt = &now
+			}
+		}
+		setNodeMetadata(mapState, MetadataMapItems, items)
+	})
+	if err != nil {
+		m.logger.Error("failed to save map item state",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", mapID),
+			zap.Error(err))
+	}
+	for _, item := range mapItems(nodeState) {
+		m.stopNodeTimeout(graphID, mapItemTimer(mapID, item.Index))
+	}
+
+	data := map[string]interface{}{
+		"node_id":     mapID,
+		"dispatch_id": configString(nodeState.Metadata, MetadataDispatchID),
+	}
+	if errorMsg != "" {
+		data["error"] = errorMsg
+		data["error_class"] = ErrorClassMap
+	} else {
+		data["output"] = results
+	}
+
+	event := ports.Event{
+		ID:          uuid.New().String(),
+		Type:        ports.EventType(domain.EventTypeNodeCompleted),
+		Timestamp:   time.Now(),
+		ExecutionID: graphID,
+		Data:        data,
+	}
+	if err := m.eventBus.Publish(ctx, TopicNodeCompleted, event); err != nil {
+		m.logger.Error("failed to publish map node completion",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", mapID),
+			zap.Error(err))
+	}
+}
+
+// handleMapItemTimeout fails a map item that did not complete within the
+// deadline of the executor node the map runs
+func (m *Manager) handleMapItemTimeout(graphID, mapID string, index int, dispatchID string, timeout time.Duration) {
+	ctx := context.Background()
+
+	unlock := m.lockExecution(graphID)
+	defer unlock()
+
+	state, err := m.loadState(ctx, graphID)
+	if err != nil {
+		m.logger.Error("failed to get state during map item timeout",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", mapID),
+			zap.Error(err))
+		return
+	}
+	if !executionActive(state.Status) || abandonedCompletion(state.GraphState, mapID) != "" {
+		return
+	}
+
+	m.logger.Warn("map item timed out",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", mapID),
+		zap.Int("item_index", index),
+		zap.Duration("timeout", timeout))
+
+	m.completeMapItem(ctx, graphID, state, mapID, dispatchID, index, nil, fmt.Sprintf("map item execution timeout after %s", timeout), ErrorClassTimeout)
+}
+
+// reconcileMap re-arms the deadlines and retries of the items of a map node
+// that was running before a restart, and moves the map on if no item is in flight.
+// Callers must hold the execution lock.
+func (m *Manager) reconcileMap(ctx context.Context, graphID string, state *executionState, mapID string) {
+	spec, err := parseMapSpec(nodeConfig(state.Graph.GetNode(mapID)))
+	if err != nil {
+		return
+	}
+	node := state.Graph.GetNode(spec.Node)
+	timeout := m.nodeTimeoutFor(node)
+
+	running := 0
+	for _, item := range mapItems(state.NodeStates[mapID]) {
+		if item.Status != domain.ExecutionStatusRunning {
+			continue
+		}
+		running++
+		index, dispatchID := item.Index, item.DispatchID
+		if item.RetryAt != nil {
+			attempt := item.attempt()
+			m.armNodeTimer(nodeTimer{Kind: timerMapItemRetry, GraphID: graphID, NodeID: mapID, ItemIndex: index, Attempt: attempt}, time.Until(*item.RetryAt))
+			continue
+		}
+		if item.StartedAt == nil || timeout <= 0 {
+			continue
+		}
+		m.armNodeTimer(nodeTimer{
+			Kind:       timerMapItemTimeout,
+			GraphID:    graphID,
+			NodeID:     mapID,
+			ItemIndex:  index,
+			DispatchID: dispatchID,
+			Timeout:    timeout,
+		}, time.Until(item.StartedAt.Add(timeout)))
+	}
+
+	if running == 0 {
+		m.advanceMap(ctx, graphID, state, mapID)
+	}
+}
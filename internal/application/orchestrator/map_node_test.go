@@ -0,0 +1,220 @@
+package orchestrator
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/nodes"
+)
+
+// mapGraph builds start -> m -> end, where m maps worker over docs
+func mapGraph(mapCfg, workerCfg map[string]interface{}) *domain.Graph {
+	return newGraph("start",
+		[]graph.Node{
+			startNode("start"),
+			nodes.New("m", NodeTypeMap, mapCfg),
+			executorNode("worker", workerCfg),
+			endNode("end"),
+		},
+		edge("start", "m"), edge("m", "end"))
+}
+
+// echoItems answers every item with its element
+func echoItems(data map[string]interface{}) map[string]interface{} {
+	state, _ := data["state"].(map[string]interface{})
+	if data["node_id"] != "worker" {
+		return map[string]interface{}{"output": map[string]interface{}{}}
+	}
+	return map[string]interface{}{"output": state["item"]}
+}
+
+func TestMapNodeCollectsItemOutputsInOrder(t *testing.T) {
+	h := newTestHarness(t, echoItems)
+
+	graphID := h.submit(t, mapGraph(map[string]interface{}{"items": "docs", "node": "worker", "concurrency": 2}, nil),
+		map[string]interface{}{"docs": []interface{}{"a", "b", "c"}})
+	state := h.waitDone(t, graphID)
+
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	want := []interface{}{"a", "b", "c"}
+	if got := state.NodeStates["m"].Output; !reflect.DeepEqual(got, want) {
+		t.Errorf("map output = %v, want %v", got, want)
+	}
+}
+
+func TestMapNodeToleratesMaxFailures(t *testing.T) {
+	for _, tt := range []struct {
+		maxFailures int
+		want        domain.ExecutionStatus
+	}{
+		{0, domain.ExecutionStatusFailed},
+		{1, domain.ExecutionStatusCompleted},
+	} {
+		h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+			if data["node_id"] == "worker" && data["state"].(map[string]interface{})["item"] == "bad" {
+				return map[string]interface{}{"error": "unreadable document"}
+			}
+			return echoItems(data)
+		})
+
+		graphID := h.submit(t, mapGraph(map[string]interface{}{"items": "docs", "node": "worker", "max_failures": tt.maxFailures}, nil),
+			map[string]interface{}{"docs": []interface{}{"a", "bad", "c"}})
+		state := h.waitDone(t, graphID)
+		if state.Status != tt.want {
+			t.Errorf("max_failures %d: status = %s (%s), want %s", tt.maxFailures, state.Status, state.Error, tt.want)
+		}
+	}
+}
+
+func TestMapItemsRetryUnderTheExecutorPolicy(t *testing.T) {
+	var mu sync.Mutex
+	attempts := make(map[interface{}]int)
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] != "worker" {
+			return echoItems(data)
+		}
+		item := data["state"].(map[string]interface{})["item"]
+		mu.Lock()
+		attempts[item]++
+		attempt := attempts[item]
+		mu.Unlock()
+
+		// The flaky item fails its first attempt only
+		if item == "flaky" && attempt == 1 {
+			return map[string]interface{}{"error": "worker overloaded", "error_class": "transient"}
+		}
+		return echoItems(data)
+	})
+
+	retry := map[string]interface{}{"retry": map[string]interface{}{"max_attempts": 2, "backoff": "1ms"}}
+	graphID := h.submit(t, mapGraph(map[string]interface{}{"items": "docs", "node": "worker"}, retry),
+		map[string]interface{}{"docs": []interface{}{"ok", "flaky"}})
+	state := h.waitDone(t, graphID)
+
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	want := []interface{}{"ok", "flaky"}
+	if got := state.NodeStates["m"].Output; !reflect.DeepEqual(got, want) {
+		t.Errorf("map output = %v, want %v", got, want)
+	}
+
+	items := mapItems(state.NodeStates["m"])
+	if items[0].attempt() != 1 || items[1].attempt() != 2 {
+		t.Errorf("item attempts = %d, %d, want 1, 2", items[0].attempt(), items[1].attempt())
+	}
+	var retried []interface{}
+	for _, work := range h.workFor("worker") {
+		if attempt, _ := configInt(work.Data, "attempt"); attempt == 2 {
+			retried = append(retried, work.Data["item_index"])
+		}
+	}
+	if !reflect.DeepEqual(retried, []interface{}{1}) {
+		t.Errorf("second attempts published for items %v, want [1]", retried)
+	}
+	if events := h.eventsOf(EventTypeNodeRetrying); len(events) != 1 {
+		t.Errorf("got %d node.retrying events, want 1", len(events))
+	}
+}
+
+func TestMapItemsStopRetryingAtMaxAttempts(t *testing.T) {
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] == "worker" {
+			return map[string]interface{}{"error": "always broken"}
+		}
+		return echoItems(data)
+	})
+
+	retry := map[string]interface{}{"retry": map[string]interface{}{"max_attempts": 3, "backoff": "1ms"}}
+	graphID := h.submit(t, mapGraph(map[string]interface{}{"items": "docs", "node": "worker"}, retry),
+		map[string]interface{}{"docs": []interface{}{"x"}})
+	state := h.waitDone(t, graphID)
+
+	if state.Status != domain.ExecutionStatusFailed {
+		t.Fatalf("status = %s, want failed", state.Status)
+	}
+	if got := len(h.workFor("worker")); got != 3 {
+		t.Errorf("item dispatched %d times, want 3", got)
+	}
+}
+
+func TestMapItemCompletionWithoutDispatchIDIsAccepted(t *testing.T) {
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		reply := echoItems(data)
+		if data["node_id"] == "worker" && data["state"].(map[string]interface{})["item"] == "b" {
+			reply["dispatch_id"] = ""
+		}
+		return reply
+	})
+
+	// The item is matched by its item_index instead
+	graphID := h.submit(t, mapGraph(map[string]interface{}{"items": "docs", "node": "worker"}, nil),
+		map[string]interface{}{"docs": []interface{}{"a", "b"}})
+	state := h.waitDone(t, graphID)
+
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	want := []interface{}{"a", "b"}
+	if got := state.NodeStates["m"].Output; !reflect.DeepEqual(got, want) {
+		t.Errorf("map output = %v, want %v", got, want)
+	}
+}
+
+func TestMapItemWorkCarriesOnlyTheMapNodeState(t *testing.T) {
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} { return nil })
+
+	graphID := h.submit(t, mapGraph(map[string]interface{}{"items": "docs", "node": "worker"}, nil),
+		map[string]interface{}{"docs": []interface{}{"a", "b", "c"}})
+	h.waitFor(t, graphID, func(state *domain.GraphState) bool {
+		return len(h.workFor("worker")) == 3
+	})
+
+	for _, work := range h.workFor("worker") {
+		nodeStates, ok := work.Data["node_state"].(map[string]*domain.NodeState)
+		if !ok || len(nodeStates) != 1 || nodeStates["m"] == nil {
+			t.Fatalf("node_state = %v, want the map node's only", work.Data["node_state"])
+		}
+		if _, ok := nodeStates["m"].Metadata[MetadataMapItems]; ok {
+			t.Errorf("node_state of item %v carries the item sub-states", work.Data["item_index"])
+		}
+	}
+}
+
+func TestMapItemsAreRecordedInOneStateUpdate(t *testing.T) {
+	// The state version once every item is running does not depend on how
+	// many items were dispatched
+	versions := make(map[int]int64)
+	for _, n := range []int{2, 20} {
+		h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} { return nil })
+		docs := make([]interface{}, n)
+		for i := range docs {
+			docs[i] = i
+		}
+		graphID := h.submit(t, mapGraph(map[string]interface{}{"items": "docs", "node": "worker"}, nil),
+			map[string]interface{}{"docs": docs})
+		h.waitFor(t, graphID, func(state *domain.GraphState) bool {
+			for _, item := range mapItems(state.NodeStates["m"]) {
+				if item.Status != domain.ExecutionStatusRunning {
+					return false
+				}
+			}
+			return state.NodeStates["m"].Status == domain.ExecutionStatusRunning
+		})
+
+		_, version, err := h.store.GetStateVersion(context.Background(), graphID)
+		if err != nil {
+			t.Fatalf("GetStateVersion() error = %v", err)
+		}
+		versions[n] = version
+	}
+	if versions[2] != versions[20] {
+		t.Errorf("state version with 2 items = %d, with 20 items = %d, want equal", versions[2], versions[20])
+	}
+}
@@ -0,0 +1,86 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+// configurableNode is implemented by custom node types that expose
+// orchestration settings
+type configurableNode interface {
+	GetConfig() map[string]interface{}
+}
+
+// nodeConfig returns the orchestration settings declared on a node.
+// Executor nodes carry them in Config, router nodes in their metadata.
+func nodeConfig(node graph.Node) map[string]interface{} {
+	switch n := node.(type) {
+	case *graph.ExecutorNode:
+		return n.Config
+	case *graph.RouterNode:
+		return n.Metadata
+	case configurableNode:
+		return n.GetConfig()
+	}
+	return nil
+}
+
+// configString reads a string setting
+func configString(cfg map[string]interface{}, key string) string {
+	s, _ := cfg[key].(string)
+	return s
+}
+
+// configInt reads an integer setting, accepting the numeric types produced
+// by Go literals and JSON decoding
+func configInt(cfg map[string]interface{}, key string) (int, bool) {
+	switch v := cfg[key].(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err == nil
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// configDuration reads a duration setting given as a Go duration string
+// ("30s") or as a number of seconds
+func configDuration(cfg map[string]interface{}, key string) (time.Duration, bool) {
+	switch v := cfg[key].(type) {
+	case time.Duration:
+		return v, true
+	case string:
+		d, err := time.ParseDuration(v)
+		return d, err == nil
+	}
+
+	seconds, ok := configFloat(cfg, key)
+	return time.Duration(seconds * float64(time.Second)), ok
+}
+
+// configFloat reads a floating point setting
+func configFloat(cfg map[string]interface{}, key string) (float64, bool) {
+	switch v := cfg[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
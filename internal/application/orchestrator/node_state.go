@@ -0,0 +1,74 @@
+package orchestrator
+
+import (
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+)
+
+// Node state metadata keys for dispatch bookkeeping
+const (
+	// MetadataNextNode records the successor chosen by a router node
+	MetadataNextNode = "next_node"
+
+	// MetadataDispatchID identifies the work event a running node waits on
+	MetadataDispatchID = "dispatch_id"
+)
+
+// setNodeMetadata stores a value in a node state's metadata
+func setNodeMetadata(nodeState *domain.NodeState, key string, value interface{}) {
+	if nodeState.Metadata == nil {
+		nodeState.Metadata = make(map[string]interface{})
+	}
+	nodeState.Metadata[key] = value
+}
+
+// metadataTime reads a timestamp from metadata, which holds a time.Time in
+// memory and an RFC 3339 string once the state went through JSON
+func metadataTime(meta map[string]interface{}, key string) (time.Time, bool) {
+	switch v := meta[key].(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// abandonedCompletion explains why a completion belongs to a branch that was
+// abandoned once the execution reached its outcome, or returns "" when the
+// node still advances the execution
+func abandonedCompletion(state *domain.GraphState, nodeID string) string {
+	if finalizing(state) {
+		if nodeID != finallyNode(state.Graph) {
+			return "execution is running its finally node"
+		}
+		return ""
+	}
+	if state.Status == ExecutionStatusCompensating && compensatedNode(state.Graph, nodeID) == "" {
+		return "execution is compensating"
+	}
+	return ""
+}
+
+// staleCompletion explains why a completion event does not belong to the
+// node's current dispatch, or returns "" when it should be applied. Workers
+// echo the dispatch_id of the work event they processed, which must match
+// the one recorded for the node. Completions without one are still applied
+// for workers that predate dispatch ids; this is deprecated.
+func staleCompletion(nodeState *domain.NodeState, dispatchID string, attempt int) string {
+	if nodeState.Status != domain.ExecutionStatusRunning {
+		return "node is not running"
+	}
+	if _, waiting := nodeState.Metadata[MetadataRetryAt]; waiting {
+		return "node is waiting for its next attempt"
+	}
+	if current, _ := nodeState.Metadata[MetadataDispatchID].(string); current != "" && dispatchID != "" && dispatchID != current {
+		return "dispatch id does not match the current dispatch"
+	}
+	if attempt > 0 && attempt != nodeAttempt(nodeState) {
+		return "attempt does not match the current attempt"
+	}
+	return ""
+}
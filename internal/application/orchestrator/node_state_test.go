@@ -0,0 +1,113 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/google/uuid"
+)
+
+func TestStaleCompletion(t *testing.T) {
+	running := func(metadata map[string]interface{}) *domain.NodeState {
+		return &domain.NodeState{NodeID: "a", Status: domain.ExecutionStatusRunning, Metadata: metadata}
+	}
+	dispatched := map[string]interface{}{MetadataDispatchID: "d1", MetadataAttempt: 2}
+
+	tests := []struct {
+		name       string
+		nodeState  *domain.NodeState
+		dispatchID string
+		attempt    int
+		stale      bool
+	}{
+		{"matching dispatch", running(dispatched), "d1", 0, false},
+		{"matching dispatch and attempt", running(dispatched), "d1", 2, false},
+		{"missing dispatch id", running(dispatched), "", 0, false},
+		{"missing dispatch id with matching attempt", running(dispatched), "", 2, false},
+		{"missing dispatch id with other attempt", running(dispatched), "", 1, true},
+		{"other dispatch", running(dispatched), "d0", 0, true},
+		{"other attempt", running(dispatched), "d1", 1, true},
+		{"nothing recorded", running(nil), "", 0, false},
+		{"not running", &domain.NodeState{Status: domain.ExecutionStatusCompleted, Metadata: dispatched}, "d1", 0, true},
+		{"waiting for retry", running(map[string]interface{}{MetadataDispatchID: "d1", MetadataRetryAt: "later"}), "d1", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := staleCompletion(tt.nodeState, tt.dispatchID, tt.attempt)
+			if (reason != "") != tt.stale {
+				t.Errorf("staleCompletion() = %q, want stale %v", reason, tt.stale)
+			}
+		})
+	}
+}
+
+func TestCompletionWithoutDispatchIDIsAccepted(t *testing.T) {
+	// The worker predates dispatch ids and does not echo them
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"dispatch_id": "", "output": map[string]interface{}{}}
+	})
+	g := newGraph("s",
+		[]graph.Node{startNode("s"), executorNode("a", nil), endNode("e")},
+		edge("s", "a"),
+		edge("a", "e"),
+	)
+	state := h.waitDone(t, h.submit(t, g, nil))
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	assertNodeStatus(t, state, "a", domain.ExecutionStatusCompleted)
+}
+
+func TestRedeliveredCompletionIsIgnored(t *testing.T) {
+	// b never reports back, so the execution waits while a's completion is
+	// delivered again
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] == "b" {
+			return nil
+		}
+		return map[string]interface{}{"output": "done"}
+	})
+	g := newGraph("s",
+		[]graph.Node{startNode("s"), executorNode("a", nil), executorNode("b", nil), endNode("e")},
+		edge("s", "a"),
+		edge("a", "b"),
+		edge("b", "e"),
+	)
+	graphID := h.submit(t, g, nil)
+	h.waitNode(t, graphID, "b", domain.ExecutionStatusRunning)
+
+	work := h.workFor("a")
+	if len(work) != 1 {
+		t.Fatalf("a dispatched %d times, want 1", len(work))
+	}
+	err := h.bus.Publish(context.Background(), TopicNodeCompleted, ports.Event{
+		ID:          uuid.New().String(),
+		Type:        ports.EventType(domain.EventTypeNodeCompleted),
+		Timestamp:   time.Now(),
+		ExecutionID: graphID,
+		Data: map[string]interface{}{
+			"node_id":     "a",
+			"dispatch_id": work[0].Data["dispatch_id"],
+			"output":      "again",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := len(h.workFor("b")); got != 1 {
+		t.Errorf("b dispatched %d times, want 1", got)
+	}
+	state, err := h.manager.GetStatus(context.Background(), graphID)
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if got := state.NodeStates["a"].Output; got != "done" {
+		t.Errorf("a output = %v, want the first completion's", got)
+	}
+}
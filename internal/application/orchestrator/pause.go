@@ -0,0 +1,187 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"go.uber.org/zap"
+)
+
+// ExecutionStatusPaused is the status of an execution that holds back new
+// dispatches until it is resumed
+const ExecutionStatusPaused domain.ExecutionStatus = "paused"
+
+// Graph metadata keys recording pauses, which suspend the graph deadline
+const (
+	GraphPausedAt  = "paused_at"
+	GraphPausedFor = "paused_for"
+)
+
+// Graph events of pausing and resuming an execution
+const (
+	EventTypeGraphPaused  domain.EventType = "graph.paused"
+	EventTypeGraphResumed domain.EventType = "graph.resumed"
+)
+
+// pausedFor returns how long an execution has been paused in total, not
+// counting a pause in progress
+func pausedFor(g *domain.Graph) time.Duration {
+	if g == nil {
+		return 0
+	}
+	d, _ := configDuration(g.Metadata, GraphPausedFor)
+	return d
+}
+
+// graphDeadline returns when an execution times out: the graph timeout
+// after its submission, extended by the time it spent paused
+func (m *Manager) graphDeadline(state *domain.GraphState) time.Time {
+	return state.SubmittedAt.Add(m.graphTimeout + pausedFor(state.Graph))
+}
+
+// setGraphMetadata stores a value in the metadata of an execution's graph
+func setGraphMetadata(g *domain.Graph, key string, value interface{}) {
+	if g.Metadata == nil {
+		g.Metadata = make(map[string]interface{})
+	}
+	g.Metadata[key] = value
+}
+
+// pausable reports whether an execution can be paused. Compensating and
+// finalizing executions already reached their outcome.
+func pausable(state *domain.GraphState) bool {
+	active := state.Status == domain.ExecutionStatusRunning || awaiting(state.Status)
+	return active && !finalizing(state)
+}
+
+// PauseExecution pauses a running graph execution. Nodes in flight may still
+// complete, but their successors are only dispatched once it is resumed.
+// Any replica may pause an execution: the pause is recorded in the stored
+// state, which every replica reads before dispatching.
+func (m *Manager) PauseExecution(ctx context.Context, graphID string) error {
+	unlock := m.lockExecution(graphID)
+	defer unlock()
+
+	state, err := m.loadState(ctx, graphID)
+	if err != nil {
+		return err
+	}
+	if !pausable(state.GraphState) {
+		return fmt.Errorf("execution cannot be paused in status %s", state.Status)
+	}
+
+	now := time.Now()
+	paused := false
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		// Another replica may have changed the status concurrently
+		paused = pausable(s)
+		if !paused {
+			return
+		}
+		s.Status = ExecutionStatusPaused
+		setGraphMetadata(s.Graph, GraphPausedAt, now)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if !paused {
+		return fmt.Errorf("execution cannot be paused in status %s", state.Status)
+	}
+
+	// The graph deadline is suspended while paused. The replica looking
+	// after the execution finds it paused when its deadline fires.
+	if val, ok := m.executions.Load(graphID); ok {
+		execCtx := val.(*executionContext)
+		execCtx.cancelFunc()
+		execCtx.status = ExecutionStatusPaused
+	}
+
+	// Publish pause event (ignore error as state is already saved)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeGraphPaused, map[string]interface{}{
+		"status": string(ExecutionStatusPaused),
+	})
+
+	m.logger.Info("graph execution paused",
+		zap.String("graph_id", graphID))
+
+	return nil
+}
+
+// ResumeExecution resumes a paused graph execution, dispatching the nodes
+// held back while it was paused. Like a pause, it may reach any replica.
+func (m *Manager) ResumeExecution(ctx context.Context, graphID string) error {
+	unlock := m.lockExecution(graphID)
+	defer unlock()
+
+	state, err := m.loadState(ctx, graphID)
+	if err != nil {
+		return err
+	}
+
+	if state.Status != ExecutionStatusPaused {
+		return fmt.Errorf("execution is not paused: %s", state.Status)
+	}
+
+	now := time.Now()
+	var paused time.Duration
+	resumed := false
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		// Another replica may have resumed it concurrently
+		resumed = s.Status == ExecutionStatusPaused
+		if !resumed {
+			return
+		}
+		paused = 0
+		if pausedAt, ok := metadataTime(s.Graph.Metadata, GraphPausedAt); ok {
+			paused = now.Sub(pausedAt)
+		}
+		s.Status = domain.ExecutionStatusRunning
+		setGraphMetadata(s.Graph, GraphPausedFor, (pausedFor(s.Graph) + paused).String())
+		delete(s.Graph.Metadata, GraphPausedAt)
+		refreshAwaitingStatus(s)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if !resumed {
+		return fmt.Errorf("execution is not paused: %s", state.Status)
+	}
+
+	// The graph deadline resumes, extended by the pause. A replica that does
+	// not look after the execution keeps it as a durable timer.
+	deadline := m.graphDeadline(state.GraphState)
+	if val, ok := m.executions.Load(graphID); ok {
+		execCtx := val.(*executionContext)
+		monitorCtx, cancel := context.WithDeadline(context.Background(), deadline)
+		execCtx.cancelFunc = cancel
+		execCtx.status = domain.ExecutionStatusRunning
+		go m.monitorExecution(monitorCtx, graphID)
+	} else if err := m.scheduleNodeTimer(ctx, nodeTimer{Kind: timerGraphTimeout, GraphID: graphID}, deadline); err != nil {
+		m.logger.Error("failed to schedule graph deadline",
+			zap.String("graph_id", graphID),
+			zap.Error(err))
+	}
+
+	// Publish resume event (ignore error as state is already saved)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeGraphResumed, map[string]interface{}{
+		"status":     string(state.Status),
+		"paused_for": paused.String(),
+	})
+
+	m.logger.Info("graph execution resumed",
+		zap.String("graph_id", graphID),
+		zap.Duration("paused_for", paused))
+
+	// Dispatch the items and successors held back while paused
+	for _, nodeID := range sortedNodeIDs(state.Graph) {
+		nodeState := state.NodeStates[nodeID]
+		if state.Graph.Nodes[nodeID].GetType() == NodeTypeMap && nodeState.Status == domain.ExecutionStatusRunning {
+			m.advanceMap(ctx, graphID, state, nodeID)
+		}
+	}
+	m.dispatchNodes(ctx, graphID, state, m.stalledSuccessors(state.GraphState))
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+func TestPauseHoldsBackDispatchUntilResumed(t *testing.T) {
+	ctx := context.Background()
+
+	// a only reports back once released, after the execution was paused
+	release := make(chan struct{})
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] == "a" {
+			<-release
+		}
+		return map[string]interface{}{"output": map[string]interface{}{}}
+	})
+
+	g := newGraph("start",
+		[]graph.Node{startNode("start"), executorNode("a", nil), executorNode("b", nil), endNode("end")},
+		edge("start", "a"), edge("a", "b"), edge("b", "end"))
+	graphID := h.submit(t, g, nil)
+	h.waitNode(t, graphID, "a", domain.ExecutionStatusRunning)
+
+	if err := h.manager.PauseExecution(ctx, graphID); err != nil {
+		t.Fatalf("PauseExecution() error = %v", err)
+	}
+	if err := h.manager.PauseExecution(ctx, graphID); err == nil {
+		t.Error("PauseExecution() of a paused execution succeeded")
+	}
+
+	// The node in flight completes, but its successor waits
+	close(release)
+	state := h.waitNode(t, graphID, "a", domain.ExecutionStatusCompleted)
+	if state.Status != ExecutionStatusPaused {
+		t.Fatalf("status = %s, want paused", state.Status)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := len(h.workFor("b")); got != 0 {
+		t.Fatalf("b dispatched %d times while paused, want 0", got)
+	}
+
+	if err := h.manager.ResumeExecution(ctx, graphID); err != nil {
+		t.Fatalf("ResumeExecution() error = %v", err)
+	}
+	if err := h.manager.ResumeExecution(ctx, graphID); err == nil {
+		t.Error("ResumeExecution() of a running execution succeeded")
+	}
+
+	state = h.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	if got := len(h.workFor("b")); got != 1 {
+		t.Errorf("b dispatched %d times, want 1", got)
+	}
+	if pausedFor(state.Graph) < 50*time.Millisecond {
+		t.Errorf("paused_for = %s, want at least the pause", pausedFor(state.Graph))
+	}
+	if len(h.eventsOf(EventTypeGraphPaused)) != 1 || len(h.eventsOf(EventTypeGraphResumed)) != 1 {
+		t.Error("want one graph.paused and one graph.resumed event")
+	}
+}
+
+func TestPauseAndResumeReachingAnotherReplica(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	worker := func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] == "a" {
+			<-release
+		}
+		return map[string]interface{}{"output": map[string]interface{}{}}
+	}
+	owner := newTestHarness(t, worker)
+	replica := newTestHarnessWithStore(t, owner.store, worker)
+
+	g := newGraph("start",
+		[]graph.Node{startNode("start"), executorNode("a", nil), executorNode("b", nil), endNode("end")},
+		edge("start", "a"), edge("a", "b"), edge("b", "end"))
+	graphID := owner.submit(t, g, nil)
+	owner.waitNode(t, graphID, "a", domain.ExecutionStatusRunning)
+
+	// Both calls land on a replica that does not look after the execution
+	if err := replica.manager.PauseExecution(ctx, graphID); err != nil {
+		t.Fatalf("PauseExecution() on another replica error = %v", err)
+	}
+	close(release)
+	state := owner.waitNode(t, graphID, "a", domain.ExecutionStatusCompleted)
+	if state.Status != ExecutionStatusPaused {
+		t.Fatalf("status = %s, want paused", state.Status)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := len(owner.workFor("b")); got != 0 {
+		t.Fatalf("b dispatched %d times while paused, want 0", got)
+	}
+
+	if err := replica.manager.ResumeExecution(ctx, graphID); err != nil {
+		t.Fatalf("ResumeExecution() on another replica error = %v", err)
+	}
+	state = owner.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+}
+
+func TestResumeOnAnotherReplicaKeepsTheGraphDeadline(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	worker := func(data map[string]interface{}) map[string]interface{} {
+		<-release
+		return map[string]interface{}{"output": map[string]interface{}{}}
+	}
+	owner := newTestHarness(t, worker)
+	replica := newTestHarnessWithStore(t, owner.store, worker)
+	owner.manager.graphTimeout = 300 * time.Millisecond
+	replica.manager.graphTimeout = 300 * time.Millisecond
+
+	g := newGraph("start",
+		[]graph.Node{startNode("start"), executorNode("a", nil), endNode("end")},
+		edge("start", "a"), edge("a", "end"))
+	graphID := owner.submit(t, g, nil)
+	owner.waitNode(t, graphID, "a", domain.ExecutionStatusRunning)
+
+	// The owner's deadline passes while paused and is ignored
+	if err := replica.manager.PauseExecution(ctx, graphID); err != nil {
+		t.Fatalf("PauseExecution() error = %v", err)
+	}
+	time.Sleep(400 * time.Millisecond)
+	state := owner.waitNode(t, graphID, "a", domain.ExecutionStatusRunning)
+	if state.Status != ExecutionStatusPaused {
+		t.Fatalf("status = %s (%s), want paused", state.Status, state.Error)
+	}
+
+	// The extended deadline is armed by the replica that resumed it
+	if err := replica.manager.ResumeExecution(ctx, graphID); err != nil {
+		t.Fatalf("ResumeExecution() error = %v", err)
+	}
+	state = owner.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusFailed || state.Error != "execution timeout" {
+		t.Fatalf("status = %s (%s), want failed by the execution timeout", state.Status, state.Error)
+	}
+}
@@ -0,0 +1,253 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// MetadataCancelledBy records the any join a node lost the race to
+const MetadataCancelledBy = "cancelled_by"
+
+// EventTypeNodeCancelled is published for each node of a branch that lost
+// the race to an any join
+const EventTypeNodeCancelled domain.EventType = "node.cancelled"
+
+// raceLosers returns the nodes an any join abandons once it fires: nodes
+// upstream of the join, but not of a predecessor that completed, whose only
+// way forward is the join. Only nodes that are still running or pending are
+// returned.
+func raceLosers(state *domain.GraphState, joinID string) []string {
+	g := state.Graph
+	adj := buildAdjacency(g, false)
+	reverse := make(adjacency, len(adj))
+	for from, targets := range adj {
+		for _, to := range targets {
+			reverse[to] = append(reverse[to], from)
+		}
+	}
+	upstream := func(from string, seen map[string]bool) {
+		queue := []string{from}
+		for len(queue) > 0 {
+			nodeID := queue[0]
+			queue = queue[1:]
+			for _, prev := range reverse[nodeID] {
+				if !seen[prev] {
+					seen[prev] = true
+					queue = append(queue, prev)
+				}
+			}
+		}
+	}
+
+	// Winners and everything they depend on stay
+	keep := make(map[string]bool)
+	for _, pred := range predecessors(g, joinID) {
+		if predState := state.NodeStates[pred]; predState != nil && predState.Status == domain.ExecutionStatusCompleted {
+			keep[pred] = true
+			upstream(pred, keep)
+		}
+	}
+
+	candidates := make(map[string]bool)
+	upstream(joinID, candidates)
+	delete(candidates, joinID)
+	for nodeID := range keep {
+		delete(candidates, nodeID)
+	}
+
+	// Nodes that also lead elsewhere stay; on_error edges belong to the branch
+	for changed := true; changed; {
+		changed = false
+		for nodeID := range candidates {
+			handlers := make(map[string]bool)
+			for _, target := range errorTargets(g, nodeID) {
+				handlers[target] = true
+			}
+			for _, next := range adj[nodeID] {
+				if next != joinID && !candidates[next] && !handlers[next] {
+					delete(candidates, nodeID)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var losers []string
+	for nodeID := range candidates {
+		nodeState := state.NodeStates[nodeID]
+		if nodeState != nil && (nodeState.Status == domain.ExecutionStatusRunning || nodeState.Status == domain.ExecutionStatusPending) {
+			losers = append(losers, nodeID)
+		}
+	}
+	sort.Strings(losers)
+	return losers
+}
+
+// cancelLosers cancels the branches that lost the race to an any join and
+// tells workers to abandon their work. Callers must hold the execution lock.
+func (m *Manager) cancelLosers(ctx context.Context, graphID string, state *executionState, joinID string) {
+	losers := raceLosers(state.GraphState, joinID)
+	if len(losers) == 0 {
+		return
+	}
+
+	// Work in flight: the dispatches of running nodes and map items
+	type dispatch struct {
+		nodeID, dispatchID string
+		mapID              string
+		index              int
+	}
+	var inFlight []dispatch
+	for _, nodeID := range losers {
+		nodeState := state.NodeStates[nodeID]
+		if nodeState.Status != domain.ExecutionStatusRunning {
+			continue
+		}
+
+		// A subgraph node's child execution is abandoned with it
+		m.cancelChild(ctx, state.GraphState, nodeID)
+		m.stopNodeTimeout(graphID, nodeID)
+
+		// Only workers have work to abandon
+		node := state.Graph.GetNode(nodeID)
+		switch node.GetType() {
+		case graph.NodeTypeExecutor, graph.NodeTypeRouter:
+			// Nodes waiting for a retry have no work out
+			if _, waiting := nodeState.Metadata[MetadataRetryAt]; !waiting {
+				inFlight = append(inFlight, dispatch{
+					nodeID:     nodeID,
+					dispatchID: configString(nodeState.Metadata, MetadataDispatchID),
+				})
+			}
+		case NodeTypeMap:
+			spec, _ := parseMapSpec(nodeConfig(node))
+			for _, item := range mapItems(nodeState) {
+				m.stopNodeTimeout(graphID, mapItemTimer(nodeID, item.Index))
+				// Items waiting for a retry have no work out
+				if item.Status == domain.ExecutionStatusRunning && item.RetryAt == nil {
+					inFlight = append(inFlight, dispatch{
+						nodeID:     spec.Node,
+						dispatchID: item.DispatchID,
+						mapID:      nodeID,
+						index:      item.Index,
+					})
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	var skipped, released []string
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		for _, nodeID := range losers {
+			nodeState := s.NodeStates[nodeID]
+			nodeState.Status = domain.ExecutionStatusCancelled
+			nodeState.CompletedAt = &now
+			setNodeMetadata(nodeState, MetadataCancelledBy, joinID)
+			delete(nodeState.Metadata, MetadataRetryAt)
+			delete(nodeState.Metadata, MetadataAwaitingInput)
+			delete(nodeState.Metadata, MetadataAwaitingSignal)
+			delete(nodeState.Metadata, MetadataSignalToken)
+
+			if s.Graph.GetNode(nodeID).GetType() == NodeTypeMap {
+				items := mapItems(nodeState)
+				for _, item := range items {
+					if item.Status == domain.ExecutionStatusPending || item.Status == domain.ExecutionStatusRunning {
+						item.Status = domain.ExecutionStatusCancelled
+						item.CompletedAt = &now
+					}
+				}
+				setNodeMetadata(nodeState, MetadataMapItems, items)
+			}
+		}
+
+		// Whatever only the cancelled branches led to is skipped
+		skipped, released = nil, nil
+		for _, nodeID := range losers {
+			nodeSkipped, nodeReleased := m.skipUntaken(s, nodeID)
+			skipped = append(skipped, nodeSkipped...)
+			for _, next := range nodeReleased {
+				if next != joinID && !slices.Contains(released, next) {
+					released = append(released, next)
+				}
+			}
+		}
+		refreshAwaitingStatus(s)
+	})
+	if err != nil {
+		m.logger.Error("failed to save cancelled branches",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", joinID),
+			zap.Error(err))
+		return
+	}
+
+	m.logger.Info("cancelled branches that lost the race",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", joinID),
+		zap.Strings("cancelled", losers))
+
+	reason := fmt.Sprintf("lost the race to %s", joinID)
+	for _, work := range inFlight {
+		data := map[string]interface{}{
+			"node_id":     work.nodeID,
+			"graph_id":    graphID,
+			"dispatch_id": work.dispatchID,
+			"reason":      reason,
+		}
+		if work.mapID != "" {
+			data["map_node"] = work.mapID
+			data["item_index"] = work.index
+		}
+		event := ports.Event{
+			ID:          uuid.New().String(),
+			Type:        ports.EventType(TopicNodeCancel),
+			Timestamp:   now,
+			ExecutionID: graphID,
+			Data:        data,
+		}
+		// Workers that miss the notice only waste work; the completion is
+		// dropped either way
+		if err := m.eventBus.Publish(ctx, TopicNodeCancel, event); err != nil {
+			m.logger.Warn("failed to publish cancellation notice",
+				zap.String("graph_id", graphID),
+				zap.String("node_id", work.nodeID),
+				zap.Error(err))
+		}
+	}
+
+	for _, nodeID := range losers {
+		// Publish cancelled event (ignore error as it's non-critical)
+		_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeCancelled, map[string]interface{}{
+			"node_id":      nodeID,
+			"cancelled_by": joinID,
+		})
+	}
+	for _, nodeID := range skipped {
+		// Publish skipped event (ignore error as it's non-critical)
+		_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeSkipped, map[string]interface{}{
+			"node_id":    nodeID,
+			"skipped_by": joinID,
+		})
+	}
+
+	// Joins elsewhere may have been waiting for the cancelled branches only
+	for _, nodeID := range released {
+		if err := m.publishNodeWork(ctx, graphID, nodeID, state); err != nil {
+			m.logger.Error("failed to publish node work",
+				zap.String("graph_id", graphID),
+				zap.String("node_id", nodeID),
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package orchestrator
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago-libs/pkg/ports"
+)
+
+func TestAnyJoinCancelsTheLosingBranch(t *testing.T) {
+	// slow never reports back; side runs next to the race
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] == "slow" {
+			return nil
+		}
+		return map[string]interface{}{"output": data["node_id"]}
+	})
+
+	var mu sync.Mutex
+	var notices []map[string]interface{}
+	err := h.bus.Subscribe(context.Background(), TopicNodeCancel, func(ctx context.Context, event ports.Event) error {
+		mu.Lock()
+		notices = append(notices, event.Data)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	g := newGraph("start",
+		[]graph.Node{
+			startNode("start"),
+			executorNode("fast", nil),
+			executorNode("slow", nil),
+			executorNode("slow_next", nil),
+			executorNode("side", nil),
+			executorNode("join", map[string]interface{}{ConfigJoin: "any"}),
+			endNode("end"),
+			endNode("side_end"),
+		},
+		edge("start", "fast"), edge("start", "slow"), edge("start", "side"),
+		edge("slow", "slow_next"),
+		edge("fast", "join"), edge("slow_next", "join"),
+		edge("join", "end"), edge("side", "side_end"))
+	state := h.waitDone(t, h.submit(t, g, nil))
+
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	if got := len(h.workFor("join")); got != 1 {
+		t.Errorf("join dispatched %d times, want 1", got)
+	}
+	assertNodeStatus(t, state, "side", domain.ExecutionStatusCompleted)
+
+	// The running node and the one still pending behind it lose
+	for _, nodeID := range []string{"slow", "slow_next"} {
+		assertNodeStatus(t, state, nodeID, domain.ExecutionStatusCancelled)
+		if got := state.NodeStates[nodeID].Metadata[MetadataCancelledBy]; got != "join" {
+			t.Errorf("%s cancelled_by = %v, want join", nodeID, got)
+		}
+	}
+	if got := len(h.eventsOf(EventTypeNodeCancelled)); got != 2 {
+		t.Errorf("published %d node.cancelled events, want 2", got)
+	}
+	if got := len(h.workFor("slow_next")); got != 0 {
+		t.Errorf("slow_next dispatched %d times, want 0", got)
+	}
+
+	// Only slow had work out to abandon
+	h.waitFor(t, state.GraphID, func(*domain.GraphState) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(notices) > 0
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if len(notices) != 1 || notices[0]["node_id"] != "slow" {
+		t.Errorf("cancellation notices = %v, want one for slow", notices)
+	}
+}
@@ -0,0 +1,254 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago/pkg/adapters/storage"
+	"go.uber.org/zap"
+)
+
+// EventTypeGraphRecovered is published when a running execution is resumed after a restart
+const EventTypeGraphRecovered domain.EventType = "graph.recovered"
+
+// recoverExecutions rehydrates running executions from storage so they can
+// be cancelled, time out and advance again after an orchestrator restart.
+// Only executions whose lease this replica acquires are recovered. Sweeps
+// look at the executions in the storage's index of active executions, or
+// at every stored one when all is set, indexing those still active.
+func (m *Manager) recoverExecutions(ctx context.Context, all bool) error {
+	index, indexed := m.storage.(storage.ExecutionIndexStorage)
+
+	var graphIDs []string
+	var err error
+	if indexed && !all {
+		graphIDs, err = index.ListActiveExecutions(ctx)
+	} else {
+		graphIDs, err = m.storage.List(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list executions: %w", err)
+	}
+
+	recovered := 0
+	for _, graphID := range graphIDs {
+		if _, ok := m.executions.Load(graphID); ok {
+			continue
+		}
+
+		// Executions another replica still looks after stay with it, and
+		// their state is not read
+		held, err := m.acquireExecution(ctx, graphID)
+		if err != nil {
+			m.logger.Warn("skipping execution whose lease is unavailable",
+				zap.String("graph_id", graphID),
+				zap.Error(err))
+			continue
+		}
+		if !held {
+			continue
+		}
+
+		state, err := m.loadState(ctx, graphID)
+		if err != nil {
+			m.releaseExecution(ctx, graphID)
+			// States expire, leaving their executions in the index
+			if exists, existsErr := m.storage.Exists(ctx, graphID); existsErr == nil && !exists {
+				m.unindexExecution(ctx, graphID)
+				continue
+			}
+			m.logger.Warn("skipping unreadable execution state",
+				zap.String("graph_id", graphID),
+				zap.Error(err))
+			continue
+		}
+
+		if !executionActive(state.Status) || state.Graph == nil {
+			m.releaseExecution(ctx, graphID)
+			if !executionActive(state.Status) {
+				m.unindexExecution(ctx, graphID)
+			}
+			continue
+		}
+		if all {
+			if err := m.indexExecution(ctx, graphID); err != nil {
+				m.logger.Warn("failed to index active execution",
+					zap.String("graph_id", graphID),
+					zap.Error(err))
+			}
+		}
+
+		m.recoverExecution(ctx, state)
+		recovered++
+	}
+
+	if recovered > 0 {
+		m.logger.Info("recovered running executions",
+			zap.Int("count", recovered))
+	}
+	return nil
+}
+
+// indexExecution adds an execution to the storage's index of active
+// executions. Storages without an index are swept in full.
+func (m *Manager) indexExecution(ctx context.Context, graphID string) error {
+	index, ok := m.storage.(storage.ExecutionIndexStorage)
+	if !ok {
+		return nil
+	}
+	return index.AddActiveExecution(ctx, graphID)
+}
+
+// unindexExecution removes an execution that is no longer active from the
+// storage's index of active executions
+func (m *Manager) unindexExecution(ctx context.Context, graphID string) {
+	index, ok := m.storage.(storage.ExecutionIndexStorage)
+	if !ok {
+		return
+	}
+	if err := index.RemoveActiveExecution(ctx, graphID); err != nil {
+		m.logger.Warn("failed to remove execution from the active index",
+			zap.String("graph_id", graphID),
+			zap.Error(err))
+	}
+}
+
+// recoverExecution re-arms the deadlines of a running execution and
+// reconciles nodes whose work was dispatched before the restart
+func (m *Manager) recoverExecution(ctx context.Context, state *executionState) {
+	graphID := state.GraphID
+
+	// The graph deadline still counts from the original submission, and is
+	// suspended while the execution is paused
+	paused := state.Status == ExecutionStatusPaused
+	execCtx, cancel := context.WithDeadline(context.Background(), m.graphDeadline(state.GraphState))
+	exec := &executionContext{
+		graphID:    graphID,
+		status:     domain.ExecutionStatusRunning,
+		startedAt:  state.SubmittedAt,
+		cancelFunc: cancel,
+		nodeTimers: make(map[string]*time.Timer),
+	}
+	if paused {
+		cancel()
+		exec.status = ExecutionStatusPaused
+	}
+	m.executions.Store(graphID, exec)
+
+	exec.mu.Lock()
+	defer exec.mu.Unlock()
+
+	if !paused {
+		go m.monitorExecution(execCtx, graphID)
+	}
+
+	running := 0
+	for nodeID, nodeState := range state.NodeStates {
+		if nodeState.Status != domain.ExecutionStatusRunning {
+			continue
+		}
+		running++
+		m.reconcileNode(graphID, nodeID, state.GraphState)
+		m.reconcileChild(ctx, nodeState)
+		switch state.Graph.GetNode(nodeID).GetType() {
+		case NodeTypeMap:
+			m.reconcileMap(ctx, graphID, state, nodeID)
+		case NodeTypeDelay:
+			m.reconcileDelay(ctx, graphID, nodeID, nodeState)
+		}
+	}
+
+	m.logger.Info("recovered execution",
+		zap.String("graph_id", graphID),
+		zap.Int("running_nodes", running))
+
+	// Publish recovery event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeGraphRecovered, map[string]interface{}{
+		"running_nodes": running,
+	})
+
+	// Nothing in flight: the previous process stopped between recording a
+	// completion and dispatching its successors. A finalizing execution
+	// only waits for its finally node, a compensating one for its
+	// compensation nodes.
+	if running == 0 || finalizing(state.GraphState) || state.Status == ExecutionStatusCompensating {
+		m.dispatchNodes(ctx, graphID, state, m.stalledSuccessors(state.GraphState))
+	}
+}
+
+// reconcileNode re-arms the timer of a node that was running before the restart.
+// Callers must hold the execution lock.
+func (m *Manager) reconcileNode(graphID, nodeID string, state *domain.GraphState) {
+	nodeState := state.NodeStates[nodeID]
+	node := state.Graph.GetNode(nodeID)
+	if node == nil {
+		return
+	}
+
+	// Nodes waiting for another attempt resume their backoff
+	if retryAt, ok := metadataTime(nodeState.Metadata, MetadataRetryAt); ok {
+		attempt := nodeAttempt(nodeState)
+		m.armNodeTimer(nodeTimer{Kind: timerNodeRetry, GraphID: graphID, NodeID: nodeID, Attempt: attempt}, time.Until(retryAt))
+		return
+	}
+
+	// Dispatched nodes get the rest of their deadline. Work that no worker
+	// acknowledged fails (and is retried) once it expires.
+	timeout := m.nodeTimeoutFor(node)
+	if nodeState.StartedAt == nil || timeout <= 0 {
+		return
+	}
+
+	startedAt := *nodeState.StartedAt
+	m.armNodeTimer(nodeTimer{
+		Kind:      timerNodeTimeout,
+		GraphID:   graphID,
+		NodeID:    nodeID,
+		StartedAt: &startedAt,
+		Timeout:   timeout,
+	}, time.Until(startedAt.Add(timeout)))
+}
+
+// stalledSuccessors returns the nodes a stalled execution dispatches next:
+// the entry node if nothing ran yet, the finally node of a finalizing
+// execution, otherwise the pending successors of completed nodes and of
+// failed nodes routed to on_error edges
+func (m *Manager) stalledSuccessors(state *domain.GraphState) []string {
+	if finalizing(state) {
+		finallyID := finallyNode(state.Graph)
+		if state.NodeStates[finallyID].Status == domain.ExecutionStatusPending {
+			return []string{finallyID}
+		}
+		return nil
+	}
+
+	started := false
+	seen := make(map[string]bool)
+	var next []string
+
+	for nodeID, nodeState := range state.NodeStates {
+		if nodeState.Status == domain.ExecutionStatusPending {
+			continue
+		}
+		started = true
+		if nodeState.Status != domain.ExecutionStatusCompleted && !errorRouted(nodeState) {
+			continue
+		}
+
+		for _, succ := range m.successors(state, nodeID) {
+			succState := state.NodeStates[succ]
+			if seen[succ] || succState == nil || succState.Status != domain.ExecutionStatusPending {
+				continue
+			}
+			seen[succ] = true
+			next = append(next, succ)
+		}
+	}
+
+	if !started {
+		return []string{state.Graph.EntryNode}
+	}
+	return next
+}
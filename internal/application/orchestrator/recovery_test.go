@@ -0,0 +1,149 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+func TestRecoveryRearmsRunningExecutions(t *testing.T) {
+	// The worker never reports back, so the execution stays running
+	silent := func(map[string]interface{}) map[string]interface{} { return nil }
+	owner := newTestHarness(t, silent)
+
+	g := newGraph("s",
+		[]graph.Node{startNode("s"), executorNode("a", map[string]interface{}{ConfigTimeout: "300ms"}), endNode("e")},
+		edge("s", "a"),
+		edge("a", "e"),
+	)
+	graphID := owner.submit(t, g, nil)
+	owner.waitNode(t, graphID, "a", domain.ExecutionStatusRunning)
+	if err := owner.manager.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	// A manager started on the same storage picks the execution up, and
+	// the node's deadline still applies
+	replica := newTestHarnessWithStore(t, owner.store, silent)
+	if _, ok := replica.manager.executions.Load(graphID); !ok {
+		t.Fatal("execution was not recovered")
+	}
+	state := replica.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusFailed {
+		t.Fatalf("status = %s, want failed by the node timeout", state.Status)
+	}
+	if got := len(replica.eventsOf(EventTypeGraphRecovered)); got != 1 {
+		t.Errorf("published %d graph.recovered events, want 1", got)
+	}
+	replica.waitFor(t, graphID, func(*domain.GraphState) bool {
+		return len(replica.eventsOf(EventTypeNodeTimeout)) == 1
+	})
+}
+
+func TestRecoveryLeavesLeasedExecutionsToTheirOwner(t *testing.T) {
+	// The worker never reports back, so the execution stays running
+	silent := func(map[string]interface{}) map[string]interface{} { return nil }
+	owner := newTestHarness(t, silent)
+
+	g := newGraph("s",
+		[]graph.Node{startNode("s"), executorNode("a", nil), endNode("e")},
+		edge("s", "a"),
+		edge("a", "e"),
+	)
+	graphID := owner.submit(t, g, nil)
+	owner.waitNode(t, graphID, "a", domain.ExecutionStatusRunning)
+
+	// A second replica starting up leaves the execution to its owner
+	replica := newTestHarnessWithStore(t, owner.store, silent)
+	if _, ok := replica.manager.executions.Load(graphID); ok {
+		t.Fatal("replica recovered an execution another replica holds the lease on")
+	}
+	if got := len(replica.eventsOf(EventTypeGraphRecovered)); got != 0 {
+		t.Errorf("replica published %d graph.recovered events, want 0", got)
+	}
+
+	// Once the owner stops, the replica's next sweep adopts it
+	if err := owner.manager.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if err := replica.manager.recoverExecutions(context.Background(), false); err != nil {
+		t.Fatalf("recoverExecutions() error = %v", err)
+	}
+	if _, ok := replica.manager.executions.Load(graphID); !ok {
+		t.Fatal("replica did not recover the execution after the owner released it")
+	}
+
+	// Only one replica holds the lease at a time
+	if held, err := owner.manager.acquireExecution(context.Background(), graphID); err != nil || held {
+		t.Errorf("acquireExecution() on the former owner = %v, %v; want false, nil", held, err)
+	}
+}
+
+func TestRecoverySweepsTheActiveIndex(t *testing.T) {
+	ctx := context.Background()
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] == "slow" {
+			<-block
+		}
+		return map[string]interface{}{"output": map[string]interface{}{}}
+	})
+	graphOf := func(nodeID string) *domain.Graph {
+		return newGraph("s",
+			[]graph.Node{startNode("s"), executorNode(nodeID, nil), endNode("e")},
+			edge("s", nodeID),
+			edge(nodeID, "e"))
+	}
+
+	running := h.submit(t, graphOf("slow"), nil)
+	h.waitNode(t, running, "slow", domain.ExecutionStatusRunning)
+	done := h.waitDone(t, h.submit(t, graphOf("fast"), nil))
+
+	// Finished executions leave the index; so do executions whose state
+	// expired, once a sweep finds them gone
+	if err := h.store.AddActiveExecution(ctx, "expired"); err != nil {
+		t.Fatalf("AddActiveExecution() error = %v", err)
+	}
+	if err := h.manager.recoverExecutions(ctx, false); err != nil {
+		t.Fatalf("recoverExecutions() error = %v", err)
+	}
+	active, err := h.store.ListActiveExecutions(ctx)
+	if err != nil {
+		t.Fatalf("ListActiveExecutions() error = %v", err)
+	}
+	if len(active) != 1 || active[0] != running {
+		t.Errorf("active executions = %v, want only %s (not %s or expired)", active, running, done.GraphID)
+	}
+}
+
+func TestStartupSweepIndexesStoredExecutions(t *testing.T) {
+	ctx := context.Background()
+	silent := func(map[string]interface{}) map[string]interface{} { return nil }
+	owner := newTestHarness(t, silent)
+
+	g := newGraph("s",
+		[]graph.Node{startNode("s"), executorNode("a", nil), endNode("e")},
+		edge("s", "a"),
+		edge("a", "e"),
+	)
+	graphID := owner.submit(t, g, nil)
+	owner.waitNode(t, graphID, "a", domain.ExecutionStatusRunning)
+	if err := owner.manager.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	// The execution was stored before the index existed
+	if err := owner.store.RemoveActiveExecution(ctx, graphID); err != nil {
+		t.Fatalf("RemoveActiveExecution() error = %v", err)
+	}
+	replica := newTestHarnessWithStore(t, owner.store, silent)
+	if _, ok := replica.manager.executions.Load(graphID); !ok {
+		t.Fatal("execution was not recovered")
+	}
+	if active, _ := owner.store.ListActiveExecutions(ctx); len(active) != 1 || active[0] != graphID {
+		t.Errorf("active executions = %v, want %s indexed again", active, graphID)
+	}
+}
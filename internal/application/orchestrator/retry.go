@@ -0,0 +1,315 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"go.uber.org/zap"
+)
+
+// ConfigRetry holds a node's retry settings
+const ConfigRetry = "retry"
+
+// EventTypeNodeRetrying is published when a failed node is scheduled for another attempt
+const EventTypeNodeRetrying domain.EventType = "node.retrying"
+
+// Error classes reported by the orchestrator itself
+const (
+	ErrorClassTimeout       = "timeout"
+	ErrorClassCondition     = "condition"
+	ErrorClassInvalidChoice = "invalid_choice"
+	ErrorClassSchema        = "schema"
+)
+
+// Node state metadata keys for retries
+const (
+	MetadataAttempt       = "attempt"
+	MetadataAttemptErrors = "attempt_errors"
+	MetadataRetryAt       = "retry_at"
+)
+
+// RetryPolicy describes how a failed node is retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the second attempt
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+
+	// Multiplier grows the delay after each attempt
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to this fraction (0-1)
+	Jitter float64
+
+	// RetryableErrors lists the error classes worth retrying. An error
+	// matches when its class equals an entry or its message contains one.
+	// An empty list retries every error.
+	RetryableErrors []string
+}
+
+// defaultRetryPolicy runs a node exactly once
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    1,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Minute,
+		Multiplier:     2,
+	}
+}
+
+// parseRetryPolicy reads the retry settings from a node configuration
+func parseRetryPolicy(cfg map[string]interface{}) (RetryPolicy, error) {
+	policy := defaultRetryPolicy()
+
+	raw, ok := cfg[ConfigRetry]
+	if !ok {
+		return policy, nil
+	}
+	retryCfg, ok := raw.(map[string]interface{})
+	if !ok {
+		return policy, fmt.Errorf("%s must be an object", ConfigRetry)
+	}
+
+	if _, ok := retryCfg["max_attempts"]; ok {
+		attempts, ok := configInt(retryCfg, "max_attempts")
+		if !ok || attempts < 1 {
+			return policy, fmt.Errorf("retry.max_attempts must be a positive integer")
+		}
+		policy.MaxAttempts = attempts
+	}
+
+	if _, ok := retryCfg["backoff"]; ok {
+		backoff, ok := configDuration(retryCfg, "backoff")
+		if !ok || backoff < 0 {
+			return policy, fmt.Errorf("retry.backoff must be a non-negative duration")
+		}
+		policy.InitialBackoff = backoff
+	}
+
+	if _, ok := retryCfg["max_backoff"]; ok {
+		maxBackoff, ok := configDuration(retryCfg, "max_backoff")
+		if !ok || maxBackoff < 0 {
+			return policy, fmt.Errorf("retry.max_backoff must be a non-negative duration")
+		}
+		policy.MaxBackoff = maxBackoff
+	}
+
+	if _, ok := retryCfg["multiplier"]; ok {
+		multiplier, ok := configFloat(retryCfg, "multiplier")
+		if !ok || multiplier < 1 {
+			return policy, fmt.Errorf("retry.multiplier must be at least 1")
+		}
+		policy.Multiplier = multiplier
+	}
+
+	if _, ok := retryCfg["jitter"]; ok {
+		jitter, ok := configFloat(retryCfg, "jitter")
+		if !ok || jitter < 0 || jitter > 1 {
+			return policy, fmt.Errorf("retry.jitter must be between 0 and 1")
+		}
+		policy.Jitter = jitter
+	}
+
+	if raw, ok := retryCfg["retryable_errors"]; ok {
+		classes, ok := toStringSlice(raw)
+		if !ok {
+			return policy, fmt.Errorf("retry.retryable_errors must be a list of strings")
+		}
+		policy.RetryableErrors = classes
+	}
+
+	return policy, nil
+}
+
+// Backoff returns the delay before the given attempt (2 for the first retry).
+// Jitter is applied before the delay is capped at MaxBackoff, so no delay
+// exceeds it; a MaxBackoff of 0 leaves delays uncapped.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-2))
+	if p.Jitter > 0 {
+		delay *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+
+	// Later attempts grow past any duration, up to +Inf, and a zero initial
+	// backoff times +Inf is NaN
+	limit := time.Duration(math.MaxInt64)
+	if p.MaxBackoff > 0 {
+		limit = p.MaxBackoff
+	}
+	switch {
+	case math.IsNaN(delay) || delay <= 0:
+		return 0
+	case delay >= float64(limit):
+		return limit
+	}
+	return time.Duration(delay)
+}
+
+// Retryable reports whether an error of the given class and message may be retried
+func (p RetryPolicy) Retryable(errorMsg, errorClass string) bool {
+	if len(p.RetryableErrors) == 0 {
+		return true
+	}
+
+	msg := strings.ToLower(errorMsg)
+	for _, class := range p.RetryableErrors {
+		class = strings.ToLower(class)
+		if strings.EqualFold(errorClass, class) || strings.Contains(msg, class) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeAttempt returns the current attempt number of a node (1-based)
+func nodeAttempt(nodeState *domain.NodeState) int {
+	if attempt, ok := configInt(nodeState.Metadata, MetadataAttempt); ok && attempt > 0 {
+		return attempt
+	}
+	return 1
+}
+
+// recordAttemptError appends a failed attempt to the node's history
+func recordAttemptError(nodeState *domain.NodeState, errorMsg, errorClass string) {
+	attemptErrors, _ := nodeState.Metadata[MetadataAttemptErrors].([]interface{})
+	entry := map[string]interface{}{
+		"attempt":   nodeAttempt(nodeState),
+		"error":     errorMsg,
+		"failed_at": time.Now(),
+	}
+	if errorClass != "" {
+		entry["error_class"] = errorClass
+	}
+	setNodeMetadata(nodeState, MetadataAttemptErrors, append(attemptErrors, entry))
+}
+
+// scheduleRetry arranges another attempt for a failed node when its retry
+// policy allows it. Callers must hold the execution lock.
+func (m *Manager) scheduleRetry(ctx context.Context, graphID string, state *executionState, nodeID, errorMsg, errorClass string) bool {
+	node := state.Graph.GetNode(nodeID)
+	if node == nil {
+		return false
+	}
+
+	policy, err := parseRetryPolicy(nodeConfig(node))
+	if err != nil {
+		return false
+	}
+
+	attempt := nodeAttempt(state.NodeStates[nodeID])
+	if attempt >= policy.MaxAttempts || !policy.Retryable(errorMsg, errorClass) {
+		return false
+	}
+
+	// The node stays running while it waits for its next attempt
+	nextAttempt := attempt + 1
+	delay := policy.Backoff(nextAttempt)
+	retryAt := time.Now().Add(delay)
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[nodeID]
+		recordAttemptError(nodeState, errorMsg, errorClass)
+		nodeState.Status = domain.ExecutionStatusRunning
+		nodeState.Error = errorMsg
+		setNodeMetadata(nodeState, MetadataAttempt, nextAttempt)
+		setNodeMetadata(nodeState, MetadataRetryAt, retryAt)
+	})
+	if err != nil {
+		m.logger.Error("failed to save state before retry",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+	}
+
+	m.logger.Info("scheduling node retry",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID),
+		zap.Int("attempt", nextAttempt),
+		zap.Int("max_attempts", policy.MaxAttempts),
+		zap.Duration("backoff", delay))
+
+	// Publish retry event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeRetrying, map[string]interface{}{
+		"node_id":  nodeID,
+		"attempt":  nextAttempt,
+		"error":    errorMsg,
+		"retry_at": retryAt,
+	})
+
+	m.armNodeTimer(nodeTimer{Kind: timerNodeRetry, GraphID: graphID, NodeID: nodeID, Attempt: nextAttempt}, delay)
+
+	return true
+}
+
+// retryNode re-publishes work for a node once its backoff has elapsed
+func (m *Manager) retryNode(graphID, nodeID string, attempt int) {
+	ctx := context.Background()
+
+	unlock := m.lockExecution(graphID)
+	defer unlock()
+
+	state, err := m.loadState(ctx, graphID)
+	if err != nil {
+		m.logger.Error("failed to get state for node retry",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+		return
+	}
+
+	if !executionActive(state.Status) {
+		return
+	}
+
+	// Timers kept by the state storage may fire after the retry was
+	// dispatched already
+	nodeState := state.NodeStates[nodeID]
+	if nodeState == nil || nodeState.Status != domain.ExecutionStatusRunning || nodeAttempt(nodeState) != attempt {
+		return
+	}
+	if _, waiting := nodeState.Metadata[MetadataRetryAt]; !waiting {
+		return
+	}
+
+	// Reset to pending so the node is dispatched like a fresh one
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[nodeID]
+		nodeState.Status = domain.ExecutionStatusPending
+		delete(nodeState.Metadata, MetadataRetryAt)
+	})
+	if err != nil {
+		m.logger.Error("failed to save state before node retry",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+	}
+
+	m.dispatchNodes(ctx, graphID, state, []string{nodeID})
+}
+
+// toStringSlice converts a configured list to strings
+func toStringSlice(raw interface{}) ([]string, bool) {
+	switch v := raw.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, s)
+		}
+		return out, true
+	}
+	return nil, false
+}
@@ -0,0 +1,139 @@
+package orchestrator
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{
+			name:    "first retry",
+			policy:  RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute, Multiplier: 2},
+			attempt: 2,
+			min:     time.Second,
+			max:     time.Second,
+		},
+		{
+			name:    "grows by the multiplier",
+			policy:  RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute, Multiplier: 2},
+			attempt: 4,
+			min:     4 * time.Second,
+			max:     4 * time.Second,
+		},
+		{
+			name:    "capped",
+			policy:  RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Multiplier: 2},
+			attempt: 10,
+			min:     10 * time.Second,
+			max:     10 * time.Second,
+		},
+		{
+			name:    "jitter stays under the cap",
+			policy:  RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Multiplier: 2, Jitter: 1},
+			attempt: 10,
+			min:     0,
+			max:     10 * time.Second,
+		},
+		{
+			name:    "uncapped overflow saturates",
+			policy:  RetryPolicy{InitialBackoff: time.Second, Multiplier: 10},
+			attempt: 1000,
+			min:     time.Duration(math.MaxInt64),
+			max:     time.Duration(math.MaxInt64),
+		},
+		{
+			name:    "zero backoff with infinite growth",
+			policy:  RetryPolicy{Multiplier: 10},
+			attempt: 1000,
+			min:     0,
+			max:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				got := tt.policy.Backoff(tt.attempt)
+				if got < tt.min || got > tt.max {
+					t.Fatalf("Backoff(%d) = %v, want between %v and %v", tt.attempt, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryPolicy(t *testing.T) {
+	policy, err := parseRetryPolicy(map[string]interface{}{
+		ConfigRetry: map[string]interface{}{
+			"max_attempts":     3.0,
+			"backoff":          "2s",
+			"max_backoff":      "0s",
+			"multiplier":       3.0,
+			"jitter":           0.5,
+			"retryable_errors": []interface{}{"timeout"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("parseRetryPolicy() error = %v", err)
+	}
+	if policy.MaxAttempts != 3 || policy.InitialBackoff != 2*time.Second || policy.MaxBackoff != 0 ||
+		policy.Multiplier != 3 || policy.Jitter != 0.5 {
+		t.Errorf("parseRetryPolicy() = %+v", policy)
+	}
+	if !policy.Retryable("worker timeout exceeded", "") || policy.Retryable("bad input", "validation") {
+		t.Error("Retryable() does not follow retryable_errors")
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{"max_attempts": 0.0},
+		{"backoff": "-1s"},
+		{"multiplier": 0.5},
+		{"jitter": 2.0},
+		{"retryable_errors": "timeout"},
+	} {
+		if _, err := parseRetryPolicy(map[string]interface{}{ConfigRetry: bad}); err == nil {
+			t.Errorf("parseRetryPolicy(%v) accepted an invalid policy", bad)
+		}
+	}
+}
+
+func TestFailedNodeIsRetried(t *testing.T) {
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		if attempt, _ := configInt(data, "attempt"); attempt < 2 {
+			return map[string]interface{}{"error": "flaky"}
+		}
+		return map[string]interface{}{"output": "done"}
+	})
+	g := newGraph("s",
+		[]graph.Node{
+			startNode("s"),
+			executorNode("a", map[string]interface{}{
+				ConfigRetry: map[string]interface{}{"max_attempts": 2.0, "backoff": "1ms"},
+			}),
+			endNode("e"),
+		},
+		edge("s", "a"),
+		edge("a", "e"),
+	)
+
+	state := h.waitDone(t, h.submit(t, g, nil))
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	if got := len(h.workFor("a")); got != 2 {
+		t.Errorf("node dispatched %d times, want 2", got)
+	}
+	if got := len(h.eventsOf(EventTypeNodeRetrying)); got != 1 {
+		t.Errorf("published %d node.retrying events, want 1", got)
+	}
+}
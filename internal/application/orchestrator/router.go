@@ -0,0 +1,137 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"go.uber.org/zap"
+)
+
+// ConfigOnInvalidChoice selects what happens when a router chooses a node
+// it has no edge to
+const ConfigOnInvalidChoice = "on_invalid_choice"
+
+// EventTypeRouterInvalidChoice is published when a router chooses a node it
+// has no edge to
+const EventTypeRouterInvalidChoice domain.EventType = "router.invalid_choice"
+
+// InvalidChoicePolicy controls how an invalid router choice is handled
+type InvalidChoicePolicy string
+
+const (
+	// InvalidChoiceFallback follows the router's fallback edge, or fails the
+	// router when it has none (default)
+	InvalidChoiceFallback InvalidChoicePolicy = "fallback"
+
+	// InvalidChoiceFail fails the router node
+	InvalidChoiceFail InvalidChoicePolicy = "fail"
+)
+
+// parseInvalidChoicePolicy reads the invalid choice policy from a node configuration
+func parseInvalidChoicePolicy(cfg map[string]interface{}) (InvalidChoicePolicy, error) {
+	policy := InvalidChoicePolicy(configString(cfg, ConfigOnInvalidChoice))
+	switch policy {
+	case "":
+		return InvalidChoiceFallback, nil
+	case InvalidChoiceFallback, InvalidChoiceFail:
+		return policy, nil
+	}
+	return policy, fmt.Errorf("unknown %s policy: %s", ConfigOnInvalidChoice, policy)
+}
+
+// routerChoices returns the nodes a router may hand over to: the targets of
+// its outgoing edges other than on_error edges and of its declared routes
+func routerChoices(g *domain.Graph, nodeID string) []string {
+	var choices []string
+	seen := make(map[string]bool)
+	add := func(target string) {
+		if _, exists := g.Nodes[target]; exists && !seen[target] {
+			seen[target] = true
+			choices = append(choices, target)
+		}
+	}
+
+	for _, edge := range g.GetOutgoingEdges(nodeID) {
+		if !isErrorEdge(edge) {
+			add(edge.To)
+		}
+	}
+	if router, ok := g.GetNode(nodeID).(*graph.RouterNode); ok {
+		for _, route := range router.Routes {
+			add(route.Target)
+		}
+		if router.DefaultRoute != "" {
+			add(router.DefaultRoute)
+		}
+	}
+
+	sort.Strings(choices)
+	return choices
+}
+
+// routerFallback returns the target a router falls back to: its default
+// edge, otherwise its default route
+func routerFallback(g *domain.Graph, nodeID string) string {
+	for _, edge := range g.GetOutgoingEdges(nodeID) {
+		if isDefaultEdge(edge) {
+			return edge.To
+		}
+	}
+	if router, ok := g.GetNode(nodeID).(*graph.RouterNode); ok && router.DefaultRoute != "" {
+		if _, exists := g.Nodes[router.DefaultRoute]; exists {
+			return router.DefaultRoute
+		}
+	}
+	return ""
+}
+
+// validChoice reports whether a router may hand over to the chosen node
+func validChoice(g *domain.Graph, nodeID, choice string) bool {
+	for _, target := range routerChoices(g, nodeID) {
+		if target == choice {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveInvalidChoice applies a router's invalid choice policy, returning
+// the node to follow instead or an error when the router must fail
+func (m *Manager) resolveInvalidChoice(ctx context.Context, graphID string, g *domain.Graph, nodeID, choice string) (string, error) {
+	policy, err := parseInvalidChoicePolicy(nodeConfig(g.GetNode(nodeID)))
+	if err != nil {
+		// Rejected by the validator; fail rather than guess
+		policy = InvalidChoiceFail
+	}
+
+	fallback := ""
+	if policy == InvalidChoiceFallback {
+		fallback = routerFallback(g, nodeID)
+	}
+
+	m.logger.Warn("router chose a node it has no edge to",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID),
+		zap.String("next_node", choice),
+		zap.String("fallback", fallback))
+
+	// Publish invalid choice event (ignore error as it's non-critical)
+	data := map[string]interface{}{
+		"node_id":   nodeID,
+		"next_node": choice,
+		"choices":   routerChoices(g, nodeID),
+		"policy":    string(policy),
+	}
+	if fallback != "" {
+		data["fallback"] = fallback
+	}
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeRouterInvalidChoice, data)
+
+	if fallback == "" {
+		return "", fmt.Errorf("router %s chose %q, which is not one of its edges", nodeID, choice)
+	}
+	return fallback, nil
+}
@@ -0,0 +1,91 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/nodes"
+)
+
+// routerGraph has router r choose between left and right, where right is
+// r's default edge when withDefault is set
+func routerGraph(routerCfg map[string]interface{}, withDefault bool) *domain.Graph {
+	toRight := edge("r", "right")
+	if withDefault {
+		toRight.Metadata = map[string]interface{}{EdgeDefault: true}
+	}
+	return newGraph("start",
+		[]graph.Node{
+			startNode("start"),
+			nodes.New("r", graph.NodeTypeRouter, routerCfg),
+			executorNode("left", nil),
+			executorNode("right", nil),
+			endNode("end"),
+		},
+		edge("start", "r"), edge("r", "left"), toRight,
+		edge("left", "end"), edge("right", "end"))
+}
+
+// chooses answers router r with a next_node and every other node with an output
+func chooses(next string) workerFunc {
+	return func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] == "r" {
+			return map[string]interface{}{"next_node": next}
+		}
+		return map[string]interface{}{"output": map[string]interface{}{}}
+	}
+}
+
+func TestRouterInvalidChoice(t *testing.T) {
+	tests := []struct {
+		name        string
+		routerCfg   map[string]interface{}
+		withDefault bool
+		wantStatus  domain.ExecutionStatus
+	}{
+		{"falls back to the default edge", nil, true, domain.ExecutionStatusCompleted},
+		{"fails without a default edge", nil, false, domain.ExecutionStatusFailed},
+		{"fails under the fail policy", map[string]interface{}{ConfigOnInvalidChoice: "fail"}, true, domain.ExecutionStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, chooses("nowhere"))
+			state := h.waitDone(t, h.submit(t, routerGraph(tt.routerCfg, tt.withDefault), nil))
+
+			if state.Status != tt.wantStatus {
+				t.Fatalf("status = %s (%s), want %s", state.Status, state.Error, tt.wantStatus)
+			}
+			if got := len(h.workFor("left")); got != 0 {
+				t.Errorf("left dispatched %d times, want 0", got)
+			}
+			if events := h.eventsOf(EventTypeRouterInvalidChoice); len(events) != 1 || events[0].Data["next_node"] != "nowhere" {
+				t.Errorf("router.invalid_choice events = %v, want one for nowhere", events)
+			}
+
+			if tt.wantStatus == domain.ExecutionStatusCompleted {
+				assertNodeStatus(t, state, "right", domain.ExecutionStatusCompleted)
+				return
+			}
+			assertNodeStatus(t, state, "r", domain.ExecutionStatusFailed)
+			if err := state.NodeStates["r"].Error; !strings.Contains(err, `chose "nowhere"`) {
+				t.Errorf("router error = %q, want it to name the choice", err)
+			}
+		})
+	}
+}
+
+func TestRouterValidChoice(t *testing.T) {
+	h := newTestHarness(t, chooses("left"))
+	state := h.waitDone(t, h.submit(t, routerGraph(nil, true), nil))
+
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	assertNodeStatus(t, state, "left", domain.ExecutionStatusCompleted)
+	if got := len(h.eventsOf(EventTypeRouterInvalidChoice)); got != 0 {
+		t.Errorf("published %d router.invalid_choice events, want 0", got)
+	}
+}
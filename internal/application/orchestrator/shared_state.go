@@ -0,0 +1,294 @@
+package orchestrator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/internal/expression"
+)
+
+// GraphReducers is the graph metadata key holding the reducer of each
+// shared state key
+const GraphReducers = "reducers"
+
+// Node configuration keys for state mappings of nodes other than executors,
+// which use their InputMapping and OutputMapping fields
+const (
+	ConfigInputMapping  = "input_mapping"
+	ConfigOutputMapping = "output_mapping"
+)
+
+// MetadataStateSeq orders a node's contribution to the shared state
+const MetadataStateSeq = "state_seq"
+
+// Reducer controls how a node's update of a state key is combined with the
+// current value
+type Reducer string
+
+const (
+	// ReducerOverwrite replaces the current value (default)
+	ReducerOverwrite Reducer = "overwrite"
+
+	// ReducerAppend appends to a list; list updates are concatenated
+	ReducerAppend Reducer = "append"
+
+	// ReducerMerge merges object updates into the current object
+	ReducerMerge Reducer = "merge"
+)
+
+// stateContribution is one completed node run folded into the shared state
+type stateContribution struct {
+	seq    int
+	nodeID string
+	output interface{}
+}
+
+// parseReducers reads the reducers declared in the graph metadata
+func parseReducers(g *domain.Graph) (map[string]Reducer, error) {
+	raw, ok := g.Metadata[GraphReducers]
+	if !ok {
+		return nil, nil
+	}
+	cfg, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", GraphReducers)
+	}
+
+	reducers := make(map[string]Reducer, len(cfg))
+	for key := range cfg {
+		reducer := Reducer(configString(cfg, key))
+		switch reducer {
+		case ReducerOverwrite, ReducerAppend, ReducerMerge:
+			reducers[key] = reducer
+		default:
+			return nil, fmt.Errorf("unknown reducer for state key %s: %v", key, cfg[key])
+		}
+	}
+	return reducers, nil
+}
+
+// nodeMappings returns a node's input and output mappings. Keys are the
+// destination (work event input or state key), values are expressions over
+// the source (shared state or node output).
+func nodeMappings(node graph.Node) (inputs, outputs map[string]string) {
+	if executor, ok := node.(*graph.ExecutorNode); ok {
+		return executor.InputMapping, executor.OutputMapping
+	}
+
+	cfg := nodeConfig(node)
+	inputs, _ = toStringMap(cfg[ConfigInputMapping])
+	outputs, _ = toStringMap(cfg[ConfigOutputMapping])
+	return inputs, outputs
+}
+
+// validateMappings checks that a node's mappings are well-formed expressions
+func validateMappings(node graph.Node) error {
+	cfg := nodeConfig(node)
+	for _, key := range []string{ConfigInputMapping, ConfigOutputMapping} {
+		if raw, ok := cfg[key]; ok {
+			if _, ok := toStringMap(raw); !ok {
+				return fmt.Errorf("%s must map names to expressions", key)
+			}
+		}
+	}
+
+	inputs, outputs := nodeMappings(node)
+	for name, source := range inputs {
+		if _, err := expression.Compile(source); err != nil {
+			return fmt.Errorf("input mapping %s: %w", name, err)
+		}
+	}
+	for key, source := range outputs {
+		if _, err := expression.Compile(source); err != nil {
+			return fmt.Errorf("output mapping %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// SharedState folds the outputs of every completed node run, in completion
+// order, over the execution inputs using the graph's reducers
+func SharedState(state *domain.GraphState) map[string]interface{} {
+	shared := make(map[string]interface{}, len(state.Inputs))
+	for key, value := range state.Inputs {
+		shared[key] = value
+	}
+
+	reducers, _ := parseReducers(state.Graph)
+	for _, contribution := range stateContributions(state) {
+		node := state.Graph.GetNode(contribution.nodeID)
+		if node == nil {
+			continue
+		}
+		for key, value := range stateUpdates(node, contribution.output) {
+			shared[key] = reduce(reducers[key], shared[key], value)
+		}
+	}
+	return shared
+}
+
+// stateContributions collects the completed runs of every node, including
+// earlier loop iterations, ordered by their state sequence number
+func stateContributions(state *domain.GraphState) []stateContribution {
+	var contributions []stateContribution
+	for nodeID, nodeState := range state.NodeStates {
+		history, _ := nodeState.Metadata[MetadataOutputHistory].([]interface{})
+		for _, raw := range history {
+			entry, _ := raw.(map[string]interface{})
+			if seq, ok := configInt(entry, MetadataStateSeq); ok {
+				contributions = append(contributions, stateContribution{seq: seq, nodeID: nodeID, output: entry["output"]})
+			}
+		}
+
+		if nodeState.Status != domain.ExecutionStatusCompleted {
+			continue
+		}
+		if seq, ok := configInt(nodeState.Metadata, MetadataStateSeq); ok {
+			contributions = append(contributions, stateContribution{seq: seq, nodeID: nodeID, output: nodeState.Output})
+		}
+	}
+
+	sort.Slice(contributions, func(i, j int) bool {
+		return contributions[i].seq < contributions[j].seq
+	})
+	return contributions
+}
+
+// nextStateSeq returns the sequence number for the next completed node run
+func nextStateSeq(state *domain.GraphState) int {
+	last := 0
+	for _, contribution := range stateContributions(state) {
+		if contribution.seq > last {
+			last = contribution.seq
+		}
+	}
+	return last + 1
+}
+
+// stateUpdates derives the shared state updates of a node's output. Without
+// an output mapping the fields of an object output are used as they are,
+// and any other output is stored under the node's ID.
+func stateUpdates(node graph.Node, output interface{}) map[string]interface{} {
+	if output == nil {
+		return nil
+	}
+
+	_, outputMapping := nodeMappings(node)
+	if len(outputMapping) == 0 {
+		if fields, ok := output.(map[string]interface{}); ok {
+			return fields
+		}
+		return map[string]interface{}{node.GetID(): output}
+	}
+
+	env := mappingEnv(output, "output")
+	updates := make(map[string]interface{}, len(outputMapping))
+	for key, source := range outputMapping {
+		program, err := expression.Compile(source)
+		if err != nil {
+			continue // rejected by the validator
+		}
+		if value, err := program.Eval(env); err == nil && value != nil {
+			updates[key] = value
+		}
+	}
+	return updates
+}
+
+// nodeInputs selects the state a node's work event receives: the values
+// named by its input mapping, or the whole shared state without one
+func nodeInputs(node graph.Node, shared map[string]interface{}) (map[string]interface{}, error) {
+	inputMapping, _ := nodeMappings(node)
+	if len(inputMapping) == 0 {
+		return shared, nil
+	}
+
+	env := mappingEnv(shared, "state")
+	inputs := make(map[string]interface{}, len(inputMapping))
+	for name, source := range inputMapping {
+		program, err := expression.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("input mapping %s: %w", name, err)
+		}
+		value, err := program.Eval(env)
+		if err != nil {
+			return nil, fmt.Errorf("input mapping %s: %w", name, err)
+		}
+		inputs[name] = value
+	}
+	return inputs, nil
+}
+
+// mappingEnv exposes the fields of an object as top-level names, and the
+// whole value under alias unless a field of that name exists
+func mappingEnv(value interface{}, alias string) map[string]interface{} {
+	env := make(map[string]interface{})
+	if fields, ok := value.(map[string]interface{}); ok {
+		for key, field := range fields {
+			env[key] = field
+		}
+	}
+	if _, shadowed := env[alias]; !shadowed {
+		env[alias] = value
+	}
+	return env
+}
+
+// reduce combines the current value of a state key with an update
+func reduce(reducer Reducer, current, update interface{}) interface{} {
+	switch reducer {
+	case ReducerAppend:
+		var list []interface{}
+		switch c := current.(type) {
+		case nil:
+		case []interface{}:
+			list = append(list, c...)
+		default:
+			list = append(list, c)
+		}
+		if items, ok := update.([]interface{}); ok {
+			return append(list, items...)
+		}
+		return append(list, update)
+
+	case ReducerMerge:
+		currentFields, ok1 := current.(map[string]interface{})
+		updateFields, ok2 := update.(map[string]interface{})
+		if !ok2 {
+			return update
+		}
+		merged := make(map[string]interface{}, len(currentFields)+len(updateFields))
+		if ok1 {
+			for key, value := range currentFields {
+				merged[key] = value
+			}
+		}
+		for key, value := range updateFields {
+			merged[key] = value
+		}
+		return merged
+	}
+
+	return update
+}
+
+// toStringMap converts a configured object of strings
+func toStringMap(raw interface{}) (map[string]string, bool) {
+	switch v := raw.(type) {
+	case map[string]string:
+		return v, true
+	case map[string]interface{}:
+		out := make(map[string]string, len(v))
+		for key, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			out[key] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
@@ -0,0 +1,89 @@
+package orchestrator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+func TestNodeOutputsFlowThroughSharedState(t *testing.T) {
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		nodeID := data["node_id"]
+		return map[string]interface{}{"output": map[string]interface{}{
+			"messages": []interface{}{nodeID},
+			"last":     nodeID,
+		}}
+	})
+
+	b := &graph.ExecutorNode{
+		BaseNode:     graph.BaseNode{ID: "b", Type: graph.NodeTypeExecutor},
+		ExecutorType: "llm",
+		InputMapping: map[string]string{"history": "messages", "topic": "state.topic"},
+	}
+	c := &graph.ExecutorNode{
+		BaseNode:      graph.BaseNode{ID: "c", Type: graph.NodeTypeExecutor},
+		ExecutorType:  "llm",
+		OutputMapping: map[string]string{"summary": "output.last"},
+	}
+
+	g := newGraph("start",
+		[]graph.Node{startNode("start"), executorNode("a", nil), b, c, endNode("end")},
+		edge("start", "a"), edge("a", "b"), edge("b", "c"), edge("c", "end"))
+	g.Metadata = map[string]interface{}{
+		GraphReducers: map[string]interface{}{"messages": string(ReducerAppend)},
+	}
+	graphID := h.submit(t, g, map[string]interface{}{
+		"topic":    "billing",
+		"messages": []interface{}{"hello"},
+	})
+
+	state := h.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+
+	// b sees a's update and only what its input mapping selects
+	work := h.workFor("b")
+	if len(work) != 1 {
+		t.Fatalf("b dispatched %d times, want 1", len(work))
+	}
+	wantInputs := map[string]interface{}{
+		"history": []interface{}{"hello", "a"},
+		"topic":   "billing",
+	}
+	if got := work[0].Data["state"]; !reflect.DeepEqual(got, wantInputs) {
+		t.Errorf("b inputs = %v, want %v", got, wantInputs)
+	}
+
+	// Appended messages, overwritten last, and c's mapped summary only
+	wantState := map[string]interface{}{
+		"topic":    "billing",
+		"messages": []interface{}{"hello", "a", "b"},
+		"last":     "b",
+		"summary":  "c",
+	}
+	if got := SharedState(state); !reflect.DeepEqual(got, wantState) {
+		t.Errorf("shared state = %v, want %v", got, wantState)
+	}
+}
+
+func TestParseReducers(t *testing.T) {
+	g := &domain.Graph{Metadata: map[string]interface{}{
+		GraphReducers: map[string]interface{}{"log": "append", "doc": "merge", "last": "overwrite"},
+	}}
+	reducers, err := parseReducers(g)
+	if err != nil {
+		t.Fatalf("parseReducers() error = %v", err)
+	}
+	want := map[string]Reducer{"log": ReducerAppend, "doc": ReducerMerge, "last": ReducerOverwrite}
+	if !reflect.DeepEqual(reducers, want) {
+		t.Errorf("parseReducers() = %v, want %v", reducers, want)
+	}
+
+	g.Metadata[GraphReducers] = map[string]interface{}{"log": "sum"}
+	if _, err := parseReducers(g); err == nil {
+		t.Error("parseReducers() accepted an unknown reducer")
+	}
+}
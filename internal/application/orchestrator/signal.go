@@ -0,0 +1,216 @@
+package orchestrator
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// NodeTypeWaitForSignal is the type of nodes that wait for an outside system
+// to post a signal to their callback token
+const NodeTypeWaitForSignal graph.NodeType = "wait_for_signal"
+
+// ExecutionStatusAwaitingSignal is the status of an execution while one of
+// its signal nodes waits for its callback, and none of its human nodes waits
+// for a response
+const ExecutionStatusAwaitingSignal domain.ExecutionStatus = "awaiting_signal"
+
+// Node state metadata keys of signal nodes
+const (
+	// MetadataAwaitingSignal marks a signal node waiting for its callback
+	MetadataAwaitingSignal = "awaiting_signal"
+
+	// MetadataSignalToken holds the one-time callback token of a waiting node
+	MetadataSignalToken = "signal_token"
+
+	// MetadataSignaledAt records when a node received its signal
+	MetadataSignaledAt = "signaled_at"
+)
+
+// Graph events of signal nodes
+const (
+	EventTypeNodeWaitingSignal domain.EventType = "node.waiting_signal"
+	EventTypeNodeSignaled      domain.EventType = "node.signaled"
+)
+
+// ErrSignalNotFound is returned for tokens that no waiting node holds,
+// including tokens that were already used
+var ErrSignalNotFound = errors.New("signal not found")
+
+// newSignalToken generates a callback token. Tokens lead with the execution
+// ID, so that they are resolved from the execution state alone and remain
+// valid across restarts.
+func newSignalToken(graphID string) string {
+	return graphID + "." + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// signalExecution returns the execution a callback token belongs to
+func signalExecution(token string) (string, bool) {
+	i := strings.LastIndex(token, ".")
+	if i <= 0 || i == len(token)-1 {
+		return "", false
+	}
+	return token[:i], true
+}
+
+// awaitSignal parks a signal node until its callback token is used. Callers
+// must hold the execution lock.
+func (m *Manager) awaitSignal(ctx context.Context, graphID, nodeID string, state *executionState) error {
+	node := state.Graph.GetNode(nodeID)
+
+	now := time.Now()
+	dispatchID := uuid.New().String()
+	token := newSignalToken(graphID)
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[nodeID]
+		nodeState.StartedAt = &now
+		nodeState.Status = domain.ExecutionStatusRunning
+		setNodeMetadata(nodeState, MetadataDispatchID, dispatchID)
+		setNodeMetadata(nodeState, MetadataAwaitingSignal, true)
+		setNodeMetadata(nodeState, MetadataSignalToken, token)
+		delete(nodeState.Metadata, MetadataSignaledAt)
+		refreshAwaitingStatus(s)
+	})
+	if err != nil {
+		// A token that was not saved could never be resolved
+		m.failNode(ctx, graphID, state, nodeID, fmt.Sprintf("failed to save signal token: %v", err), "")
+		return nil
+	}
+
+	// Signal nodes only have a deadline when they set a timeout
+	timeout := m.nodeTimeoutFor(node)
+	m.armNodeTimeout(graphID, nodeID, now, timeout)
+
+	m.logger.Info("waiting for signal",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID))
+
+	data := map[string]interface{}{
+		"node_id": nodeID,
+		"token":   token,
+	}
+	if timeout > 0 {
+		data["deadline"] = now.Add(timeout)
+	}
+
+	// Publish waiting event (ignore error as the token is in the node state)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeWaitingSignal, data)
+
+	return nil
+}
+
+// Signal resumes the node waiting for a callback token, completing it with
+// the payload as its output. Each token can be used once.
+func (m *Manager) Signal(ctx context.Context, token string, payload interface{}) error {
+	graphID, ok := signalExecution(token)
+	if !ok {
+		return ErrSignalNotFound
+	}
+
+	// Any replica may take the callback, not only the one looking after the
+	// execution: the token is redeemed in the stored state and the node
+	// completes through the event bus
+	unlock := m.lockExecution(graphID)
+	defer unlock()
+
+	state, err := m.loadState(ctx, graphID)
+	if err != nil {
+		return ErrSignalNotFound
+	}
+	if !executionActive(state.Status) {
+		return ErrSignalNotFound
+	}
+
+	nodeID := signalNode(state.GraphState, token)
+	if nodeID == "" {
+		return ErrSignalNotFound
+	}
+
+	now := time.Now()
+	redeemed := false
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		// Another replica may have redeemed the token concurrently
+		redeemed = signalNode(s, token) == nodeID
+		if !redeemed {
+			return
+		}
+		nodeState := s.NodeStates[nodeID]
+		delete(nodeState.Metadata, MetadataAwaitingSignal)
+		delete(nodeState.Metadata, MetadataSignalToken)
+		setNodeMetadata(nodeState, MetadataSignaledAt, now)
+		refreshAwaitingStatus(s)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if !redeemed {
+		return ErrSignalNotFound
+	}
+
+	m.stopNodeTimeout(graphID, nodeID)
+
+	m.logger.Info("node signaled",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID))
+
+	// Publish signaled event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeSignaled, map[string]interface{}{
+		"node_id": nodeID,
+	})
+
+	return m.publishCompletion(ctx, graphID, map[string]interface{}{
+		"node_id":     nodeID,
+		"dispatch_id": configString(state.NodeStates[nodeID].Metadata, MetadataDispatchID),
+		"output":      payload,
+	})
+}
+
+// signalNode returns the waiting node holding a callback token, or ""
+func signalNode(state *domain.GraphState, token string) string {
+	for nodeID, nodeState := range state.NodeStates {
+		held := configString(nodeState.Metadata, MetadataSignalToken)
+		if held == "" || subtle.ConstantTimeCompare([]byte(held), []byte(token)) != 1 {
+			continue
+		}
+		if !awaitingSignal(nodeState) || abandonedCompletion(state, nodeID) != "" {
+			return ""
+		}
+		return nodeID
+	}
+	return ""
+}
+
+// awaitingSignal reports whether a node waits for its callback
+func awaitingSignal(nodeState *domain.NodeState) bool {
+	waiting, _ := nodeState.Metadata[MetadataAwaitingSignal].(bool)
+	return waiting && nodeState.Status == domain.ExecutionStatusRunning
+}
+
+// revokeSignal stops a signal node from waiting once its deadline expired.
+// Callers must hold the execution lock.
+func (m *Manager) revokeSignal(ctx context.Context, state *executionState, nodeID string) {
+	if configString(state.NodeStates[nodeID].Metadata, MetadataSignalToken) == "" {
+		return
+	}
+
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[nodeID]
+		delete(nodeState.Metadata, MetadataAwaitingSignal)
+		delete(nodeState.Metadata, MetadataSignalToken)
+		refreshAwaitingStatus(s)
+	})
+	if err != nil {
+		m.logger.Error("failed to revoke signal token",
+			zap.String("graph_id", state.GraphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,145 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/nodes"
+)
+
+// signalToken returns the callback token a signal node waits on
+func signalToken(t *testing.T, state *domain.GraphState, nodeID string) string {
+	t.Helper()
+	token := configString(state.NodeStates[nodeID].Metadata, MetadataSignalToken)
+	if token == "" {
+		t.Fatalf("node %s holds no signal token", nodeID)
+	}
+	return token
+}
+
+func TestSignalNodeAwaitsItsCallback(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t, reply(map[string]interface{}{}))
+
+	g := newGraph("start",
+		[]graph.Node{startNode("start"), nodes.New("wait", NodeTypeWaitForSignal, nil), endNode("end")},
+		edge("start", "wait"), edge("wait", "end"))
+	graphID := h.submit(t, g, nil)
+
+	state := h.waitFor(t, graphID, func(state *domain.GraphState) bool {
+		return state.Status == ExecutionStatusAwaitingSignal
+	})
+	metadata := state.NodeStates["wait"].Metadata
+	if metadata[MetadataAwaitingSignal] != true {
+		t.Errorf("node metadata %s = %v, want true", MetadataAwaitingSignal, metadata[MetadataAwaitingSignal])
+	}
+	if _, ok := metadata[MetadataAwaitingInput]; ok {
+		t.Errorf("signal node is marked %s", MetadataAwaitingInput)
+	}
+
+	// Reviewers cannot answer a signal node
+	for _, action := range []HumanAction{HumanApprove, HumanReject, HumanRespond} {
+		err := h.manager.RespondToNode(ctx, graphID, "wait", action, map[string]interface{}{"approved": true})
+		if !errors.Is(err, ErrNotHumanNode) {
+			t.Errorf("RespondToNode(%s) error = %v, want %v", action, err, ErrNotHumanNode)
+		}
+	}
+
+	token := signalToken(t, state, "wait")
+	payload := map[string]interface{}{"build": "passed"}
+	if err := h.manager.Signal(ctx, token, payload); err != nil {
+		t.Fatalf("Signal() error = %v", err)
+	}
+	state = h.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	if got := state.NodeStates["wait"].Output; !reflect.DeepEqual(got, payload) {
+		t.Errorf("signal node output = %v, want %v", got, payload)
+	}
+	if err := h.manager.Signal(ctx, token, nil); !errors.Is(err, ErrSignalNotFound) {
+		t.Errorf("second Signal() error = %v, want %v", err, ErrSignalNotFound)
+	}
+	if err := h.manager.Signal(ctx, "no-such-token", nil); !errors.Is(err, ErrSignalNotFound) {
+		t.Errorf("Signal() with an unknown token error = %v, want %v", err, ErrSignalNotFound)
+	}
+}
+
+func TestAwaitingInputTakesPrecedenceOverSignals(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t, reply(map[string]interface{}{}))
+
+	g := newGraph("start",
+		[]graph.Node{
+			startNode("start"),
+			nodes.New("review", NodeTypeHuman, nil),
+			nodes.New("wait", NodeTypeWaitForSignal, nil),
+			endNode("end"),
+		},
+		edge("start", "review"), edge("start", "wait"),
+		edge("review", "end"), edge("wait", "end"))
+	graphID := h.submit(t, g, nil)
+
+	state := h.waitFor(t, graphID, func(state *domain.GraphState) bool {
+		return awaitingInput(state.NodeStates["review"]) && awaitingSignal(state.NodeStates["wait"])
+	})
+	if state.Status != ExecutionStatusAwaitingInput {
+		t.Errorf("status = %s while both wait, want %s", state.Status, ExecutionStatusAwaitingInput)
+	}
+
+	if err := h.manager.RespondToNode(ctx, graphID, "review", HumanApprove, nil); err != nil {
+		t.Fatalf("RespondToNode() error = %v", err)
+	}
+	state = h.waitFor(t, graphID, func(state *domain.GraphState) bool {
+		return state.NodeStates["review"].Status == domain.ExecutionStatusCompleted
+	})
+	if state.Status != ExecutionStatusAwaitingSignal {
+		t.Errorf("status = %s once only the signal waits, want %s", state.Status, ExecutionStatusAwaitingSignal)
+	}
+
+	if err := h.manager.Signal(ctx, signalToken(t, state, "wait"), nil); err != nil {
+		t.Fatalf("Signal() error = %v", err)
+	}
+	if state := h.waitDone(t, graphID); state.Status != domain.ExecutionStatusCompleted {
+		t.Errorf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+}
+
+func TestSignalReachingAnotherReplica(t *testing.T) {
+	ctx := context.Background()
+	owner := newTestHarness(t, reply(map[string]interface{}{}))
+	replica := newTestHarnessWithStore(t, owner.store, reply(map[string]interface{}{}))
+
+	g := newGraph("start",
+		[]graph.Node{
+			startNode("start"),
+			nodes.New("wait", NodeTypeWaitForSignal, map[string]interface{}{ConfigTimeout: "5s"}),
+			endNode("end"),
+		},
+		edge("start", "wait"), edge("wait", "end"))
+	graphID := owner.submit(t, g, nil)
+	state := owner.waitFor(t, graphID, func(state *domain.GraphState) bool {
+		return state.Status == ExecutionStatusAwaitingSignal
+	})
+
+	// The callback lands on a replica that does not look after the execution
+	token := signalToken(t, state, "wait")
+	payload := map[string]interface{}{"build": "passed"}
+	if err := replica.manager.Signal(ctx, token, payload); err != nil {
+		t.Fatalf("Signal() on another replica error = %v", err)
+	}
+	state = owner.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	if got := state.NodeStates["wait"].Output; !reflect.DeepEqual(got, payload) {
+		t.Errorf("signal node output = %v, want %v", got, payload)
+	}
+	if err := owner.manager.Signal(ctx, token, nil); !errors.Is(err, ErrSignalNotFound) {
+		t.Errorf("Signal() with a redeemed token error = %v, want %v", err, ErrSignalNotFound)
+	}
+}
@@ -0,0 +1,187 @@
+package orchestrator
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"go.uber.org/zap"
+)
+
+// ExecutionStatusSkipped is the status of a node no branch of the execution
+// leads to any more: every edge into it was left untaken
+const ExecutionStatusSkipped domain.ExecutionStatus = "skipped"
+
+// EventTypeNodeSkipped is published for each node a branch that was not
+// taken leaves behind
+const EventTypeNodeSkipped domain.EventType = "node.skipped"
+
+// edgeOutcome is what became of the edges from one node to another
+type edgeOutcome int
+
+const (
+	// edgeUndecided edges come from a node that has not resolved yet
+	edgeUndecided edgeOutcome = iota
+
+	// edgeTaken edges were followed by their source
+	edgeTaken
+
+	// edgeDead edges were left untaken, or their source never ran
+	edgeDead
+)
+
+// edgeBetween reports what became of the edges from one node to another,
+// loop back-edges aside
+func (m *Manager) edgeBetween(state *domain.GraphState, from, to string) edgeOutcome {
+	fromState := state.NodeStates[from]
+	if fromState == nil {
+		return edgeDead
+	}
+	switch fromState.Status {
+	case domain.ExecutionStatusPending, domain.ExecutionStatusRunning:
+		return edgeUndecided
+	case domain.ExecutionStatusCompleted:
+	case domain.ExecutionStatusFailed:
+		if !errorRouted(fromState) {
+			return edgeDead
+		}
+	default:
+		return edgeDead
+	}
+
+	for _, next := range m.successors(state, from) {
+		if next == to {
+			return edgeTaken
+		}
+	}
+	return edgeDead
+}
+
+// incomingSources returns the distinct sources of a node's incoming edges,
+// on_error edges included and loop back-edges excluded
+func incomingSources(g *domain.Graph, nodeID string) []string {
+	var sources []string
+	seen := make(map[string]bool)
+	for _, edge := range g.GetIncomingEdges(nodeID) {
+		if seen[edge.From] || isLoopEdge(edge) {
+			continue
+		}
+		seen[edge.From] = true
+		sources = append(sources, edge.From)
+	}
+	return sources
+}
+
+// skipUntaken follows the edges a resolved node left untaken and marks the
+// pending nodes every way into which is now dead as skipped, then the
+// nodes behind them in turn. Joins that were only waiting for the skipped
+// branches are returned, as they are ready to run.
+func (m *Manager) skipUntaken(state *domain.GraphState, nodeID string) (skipped, released []string) {
+	now := time.Now()
+	queue := []string{nodeID}
+	seen := make(map[string]bool)
+
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+
+		for _, edge := range state.Graph.GetOutgoingEdges(from) {
+			target := edge.To
+			targetState := state.NodeStates[target]
+			if isLoopEdge(edge) || targetState == nil || targetState.Status != domain.ExecutionStatusPending {
+				continue
+			}
+
+			taken, undecided := false, false
+			for _, source := range incomingSources(state.Graph, target) {
+				switch m.edgeBetween(state, source, target) {
+				case edgeTaken:
+					taken = true
+				case edgeUndecided:
+					undecided = true
+				}
+			}
+
+			switch {
+			case !taken && !undecided:
+				targetState.Status = ExecutionStatusSkipped
+				targetState.CompletedAt = &now
+				skipped = append(skipped, target)
+				queue = append(queue, target)
+			case taken && !seen[target] && len(predecessors(state.Graph, target)) > 1 && m.joinReady(state, target):
+				seen[target] = true
+				released = append(released, target)
+			}
+		}
+	}
+
+	sort.Strings(skipped)
+	return skipped, released
+}
+
+// skipBranches skips the branches a resolved node did not take and returns
+// the joins that no longer wait for them. Callers must hold the execution
+// lock.
+func (m *Manager) skipBranches(ctx context.Context, graphID string, state *executionState, nodeID string) []string {
+	var skipped, released []string
+	err := m.updateState(ctx, state, func(s *domain.GraphState) {
+		skipped, released = m.skipUntaken(s, nodeID)
+	})
+	if err != nil {
+		m.logger.Error("failed to save skipped nodes",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+		return nil
+	}
+	if len(skipped) == 0 {
+		return released
+	}
+
+	m.logger.Info("skipped nodes of untaken branches",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID),
+		zap.Strings("skipped", skipped))
+
+	for _, skippedID := range skipped {
+		// Publish skipped event (ignore error as it's non-critical)
+		_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeSkipped, map[string]interface{}{
+			"node_id":    skippedID,
+			"skipped_by": nodeID,
+		})
+	}
+	return released
+}
+
+// nextNodes returns the successors of a resolved node to dispatch, once the
+// branches it did not take are skipped
+func (m *Manager) nextNodes(ctx context.Context, graphID string, state *executionState, nodeID string) []string {
+	next := m.successors(state.GraphState, nodeID)
+	for _, joinID := range m.skipBranches(ctx, graphID, state, nodeID) {
+		if !slices.Contains(next, joinID) {
+			next = append(next, joinID)
+		}
+	}
+	return next
+}
+
+// stalledNodes returns the pending nodes that a branch was taken to but
+// that cannot run, such as joins whose policy can no longer be met
+func (m *Manager) stalledNodes(state *domain.GraphState) []string {
+	var stalled []string
+	for nodeID, nodeState := range state.NodeStates {
+		if nodeState.Status != domain.ExecutionStatusPending {
+			continue
+		}
+		for _, source := range incomingSources(state.Graph, nodeID) {
+			if m.edgeBetween(state, source, nodeID) == edgeTaken {
+				stalled = append(stalled, nodeID)
+				break
+			}
+		}
+	}
+	sort.Strings(stalled)
+	return stalled
+}
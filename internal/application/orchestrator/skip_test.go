@@ -0,0 +1,139 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/nodes"
+)
+
+// branchGraph routes a to hi or lo by condition; both branches join at t
+func branchGraph(join string) *domain.Graph {
+	return newGraph("s",
+		[]graph.Node{
+			startNode("s"),
+			executorNode("a", nil),
+			executorNode("hi", nil),
+			executorNode("lo", nil),
+			executorNode("t", map[string]interface{}{ConfigJoin: join}),
+			executorNode("z", nil),
+			endNode("e"),
+		},
+		edge("s", "a"),
+		&graph.Edge{From: "a", To: "hi", Condition: "output.score > 0.8"},
+		&graph.Edge{From: "a", To: "lo", Metadata: map[string]interface{}{EdgeDefault: true}},
+		edge("hi", "t"),
+		edge("lo", "t"),
+		edge("t", "z"),
+		edge("z", "e"),
+	)
+}
+
+func TestUntakenBranchIsSkipped(t *testing.T) {
+	for _, join := range []string{"", string(JoinAll), string(JoinAny)} {
+		t.Run("join="+join, func(t *testing.T) {
+			h := newTestHarness(t, reply(map[string]interface{}{"score": 0.5}))
+			graphID := h.submit(t, branchGraph(join), nil)
+
+			state := h.waitDone(t, graphID)
+			if state.Status != domain.ExecutionStatusCompleted {
+				t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+			}
+			assertNodeStatus(t, state, "hi", ExecutionStatusSkipped)
+			assertNodeStatus(t, state, "lo", domain.ExecutionStatusCompleted)
+			assertNodeStatus(t, state, "t", domain.ExecutionStatusCompleted)
+			assertNodeStatus(t, state, "z", domain.ExecutionStatusCompleted)
+
+			if got := len(h.workFor("t")); got != 1 {
+				t.Errorf("join dispatched %d times, want 1", got)
+			}
+			if got := len(h.eventsOf(EventTypeNodeSkipped)); got != 1 {
+				t.Errorf("published %d node.skipped events, want 1", got)
+			}
+		})
+	}
+}
+
+func TestSkipPropagatesDownUntakenPath(t *testing.T) {
+	// a -> hi -> mid -> e2 is never taken; a -> lo -> e runs
+	g := newGraph("s",
+		[]graph.Node{
+			startNode("s"),
+			executorNode("a", nil),
+			executorNode("hi", nil),
+			executorNode("mid", nil),
+			executorNode("lo", nil),
+			endNode("e"),
+			endNode("e2"),
+		},
+		edge("s", "a"),
+		&graph.Edge{From: "a", To: "hi", Condition: "output.score > 0.8"},
+		&graph.Edge{From: "a", To: "lo", Metadata: map[string]interface{}{EdgeDefault: true}},
+		edge("hi", "mid"),
+		edge("mid", "e2"),
+		edge("lo", "e"),
+	)
+
+	h := newTestHarness(t, reply(map[string]interface{}{"score": 0.1}))
+	state := h.waitDone(t, h.submit(t, g, nil))
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	for _, nodeID := range []string{"hi", "mid", "e2"} {
+		assertNodeStatus(t, state, nodeID, ExecutionStatusSkipped)
+	}
+	assertNodeStatus(t, state, "e", domain.ExecutionStatusCompleted)
+}
+
+func TestRouterChoiceSkipsOtherTargets(t *testing.T) {
+	g := newGraph("s",
+		[]graph.Node{
+			startNode("s"),
+			nodes.New("r", graph.NodeTypeRouter, nil),
+			executorNode("left", nil),
+			executorNode("right", nil),
+			executorNode("t", nil),
+			endNode("e"),
+		},
+		edge("s", "r"),
+		edge("r", "left"),
+		edge("r", "right"),
+		edge("left", "t"),
+		edge("right", "t"),
+		edge("t", "e"),
+	)
+
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] == "r" {
+			return map[string]interface{}{"next_node": "right"}
+		}
+		return map[string]interface{}{"output": "done"}
+	})
+	state := h.waitDone(t, h.submit(t, g, nil))
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	assertNodeStatus(t, state, "left", ExecutionStatusSkipped)
+	assertNodeStatus(t, state, "t", domain.ExecutionStatusCompleted)
+}
+
+func TestJoinReadyIgnoresDeadEdges(t *testing.T) {
+	m := &Manager{}
+	state := &domain.GraphState{
+		Graph: branchGraph(""),
+		NodeStates: map[string]*domain.NodeState{
+			"hi": {NodeID: "hi", Status: domain.ExecutionStatusPending},
+			"lo": {NodeID: "lo", Status: domain.ExecutionStatusCompleted},
+			"t":  {NodeID: "t", Status: domain.ExecutionStatusPending},
+		},
+	}
+	if m.joinReady(state, "t") {
+		t.Fatal("joinReady() = true while a predecessor is still pending")
+	}
+
+	state.NodeStates["hi"].Status = "skipped"
+	if !m.joinReady(state, "t") {
+		t.Fatal("joinReady() = false once the pending predecessor is skipped")
+	}
+}
@@ -0,0 +1,100 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago/pkg/adapters/storage"
+	"go.uber.org/zap"
+)
+
+// maxStateUpdateAttempts bounds how often a conflicting state update is re-applied
+const maxStateUpdateAttempts = 5
+
+// executionState is a graph state together with the storage version it was
+// read at, so that saving it fails if someone else wrote in between
+type executionState struct {
+	*domain.GraphState
+	version int64
+}
+
+// executionActive reports whether an execution still processes node events
+func executionActive(status domain.ExecutionStatus) bool {
+	switch status {
+	case domain.ExecutionStatusRunning, ExecutionStatusAwaitingInput, ExecutionStatusAwaitingSignal, ExecutionStatusPaused, ExecutionStatusCompensating:
+		return true
+	}
+	return false
+}
+
+// loadState reads the graph state of an execution from storage
+func (m *Manager) loadState(ctx context.Context, graphID string) (*executionState, error) {
+	var (
+		stateInterface interface{}
+		version        int64
+		err            error
+	)
+	if versioned, ok := m.storage.(storage.VersionedStateStorage); ok {
+		stateInterface, version, err = versioned.GetStateVersion(ctx, graphID)
+	} else {
+		stateInterface, err = m.storage.GetState(ctx, graphID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state: %w", err)
+	}
+
+	state, ok := stateInterface.(*domain.GraphState)
+	if !ok {
+		return nil, fmt.Errorf("invalid state type")
+	}
+
+	return &executionState{GraphState: state, version: version}, nil
+}
+
+// saveState writes a graph state back, failing with storage.ErrVersionConflict
+// when the stored state is no longer the version that was loaded
+func (m *Manager) saveState(ctx context.Context, state *executionState) error {
+	if versioned, ok := m.storage.(storage.VersionedStateStorage); ok {
+		version, err := versioned.SaveStateVersion(ctx, state.GraphState, state.version)
+		if err != nil {
+			return err
+		}
+		state.version = version
+	} else if err := m.storage.SaveState(ctx, state.GraphState); err != nil {
+		return err
+	}
+
+	// Sweeps only look at indexed executions; finished ones leave the index
+	if !executionActive(state.Status) {
+		m.unindexExecution(ctx, state.GraphID)
+	}
+	return nil
+}
+
+// updateState applies mutate to a graph state and saves it. When another
+// writer, such as a second orchestrator replica, changed the stored state in
+// the meantime, the state is reloaded and mutate is applied again to the
+// fresh copy, which then replaces the caller's state.
+func (m *Manager) updateState(ctx context.Context, state *executionState, mutate func(*domain.GraphState)) error {
+	mutate(state.GraphState)
+
+	for attempt := 1; ; attempt++ {
+		err := m.saveState(ctx, state)
+		if !errors.Is(err, storage.ErrVersionConflict) || attempt >= maxStateUpdateAttempts {
+			return err
+		}
+
+		m.logger.Debug("state version conflict, re-applying update",
+			zap.String("graph_id", state.GraphID),
+			zap.Int("attempt", attempt))
+
+		fresh, err := m.loadState(ctx, state.GraphID)
+		if err != nil {
+			return err
+		}
+		mutate(fresh.GraphState)
+		*state = *fresh
+	}
+}
@@ -0,0 +1,67 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	stmem "github.com/aescanero/dago/pkg/adapters/storage/memory"
+	"go.uber.org/zap"
+)
+
+func TestUpdateStateReappliesConflictingUpdates(t *testing.T) {
+	ctx := context.Background()
+	store := stmem.NewInMemoryStateStorage()
+	m := &Manager{storage: store, logger: zap.NewNop()}
+
+	initial := &domain.GraphState{
+		GraphID: "exec-1",
+		Status:  domain.ExecutionStatusRunning,
+		NodeStates: map[string]*domain.NodeState{
+			"a": {NodeID: "a", Status: domain.ExecutionStatusRunning},
+			"b": {NodeID: "b", Status: domain.ExecutionStatusRunning},
+		},
+	}
+	if err := m.saveState(ctx, &executionState{GraphState: initial}); err != nil {
+		t.Fatalf("saveState() error = %v", err)
+	}
+
+	// Two writers read the same version
+	mine, err := m.loadState(ctx, "exec-1")
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+	theirs, err := m.loadState(ctx, "exec-1")
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+	err = m.updateState(ctx, theirs, func(s *domain.GraphState) {
+		s.NodeStates["b"].Status = domain.ExecutionStatusCompleted
+	})
+	if err != nil {
+		t.Fatalf("updateState() error = %v", err)
+	}
+
+	// The later update is applied again on top of the other writer's
+	err = m.updateState(ctx, mine, func(s *domain.GraphState) {
+		s.NodeStates["a"].Status = domain.ExecutionStatusCompleted
+	})
+	if err != nil {
+		t.Fatalf("conflicting updateState() error = %v", err)
+	}
+	stored, err := m.loadState(ctx, "exec-1")
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+	for _, nodeID := range []string{"a", "b"} {
+		if got := stored.NodeStates[nodeID].Status; got != domain.ExecutionStatusCompleted {
+			t.Errorf("stored %s status = %s, want completed", nodeID, got)
+		}
+		if got := mine.NodeStates[nodeID].Status; got != domain.ExecutionStatusCompleted {
+			t.Errorf("caller's %s status = %s, want the fresh state", nodeID, got)
+		}
+	}
+	if mine.version != stored.version {
+		t.Errorf("caller's version = %d, want %d", mine.version, stored.version)
+	}
+}
@@ -0,0 +1,363 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/aescanero/dago/pkg/adapters/storage"
+	"github.com/aescanero/dago/pkg/nodes"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// NodeTypeSubgraph is the type of nodes that run another graph as a child
+// execution
+const NodeTypeSubgraph graph.NodeType = "subgraph"
+
+// Subgraph node settings: the child graph is given inline or as the
+// reference it was registered under
+const (
+	ConfigSubgraph    = "graph"
+	ConfigSubgraphRef = "graph_ref"
+)
+
+// GraphParent is the metadata key linking a child execution's graph to the
+// subgraph node that started it
+const GraphParent = "parent_execution"
+
+// MetadataChildExecution records the child execution started by a subgraph node
+const MetadataChildExecution = "child_execution"
+
+// ErrorClassSubgraph is the error class of a subgraph node whose child
+// execution did not complete
+const ErrorClassSubgraph = "subgraph"
+
+// maxSubgraphDepth bounds the nesting of child executions, so that graphs
+// referencing themselves cannot start executions without end
+const maxSubgraphDepth = 16
+
+// ExecutionLink connects a subgraph node to the child execution it started
+type ExecutionLink struct {
+	GraphID string `json:"graph_id"`
+	NodeID  string `json:"node_id"`
+}
+
+// RegisterGraph validates a graph definition and registers it under a
+// reference that subgraph nodes can name in graph_ref. Registering a
+// reference again replaces its definition. Definitions are kept in the state
+// storage, so that every replica resolves them.
+func (m *Manager) RegisterGraph(ctx context.Context, ref string, g *domain.Graph) error {
+	if ref == "" {
+		return fmt.Errorf("graph reference is required")
+	}
+	definitions, ok := m.storage.(storage.DefinitionStorage)
+	if !ok {
+		return fmt.Errorf("state storage does not keep graph definitions")
+	}
+	if err := m.validator.Validate(g); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	// Stored encoded so that every child execution gets its own copy
+	data, err := json.Marshal(g)
+	if err != nil {
+		return fmt.Errorf("failed to encode graph: %w", err)
+	}
+	if err := definitions.SaveDefinition(ctx, ref, data); err != nil {
+		return fmt.Errorf("failed to save graph definition: %w", err)
+	}
+
+	m.logger.Info("graph registered",
+		zap.String("graph_ref", ref),
+		zap.String("original_graph_id", g.ID))
+	return nil
+}
+
+// inlineSubgraph decodes the graph declared inline on a subgraph node
+func inlineSubgraph(raw interface{}) (*domain.Graph, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode subgraph: %w", err)
+	}
+	g, err := nodes.DecodeGraph(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode subgraph: %w", err)
+	}
+	return g, nil
+}
+
+// validateSubgraph checks that a subgraph node names exactly one child graph
+// and that an inline child graph is valid
+func (v *Validator) validateSubgraph(node graph.Node) error {
+	cfg := nodeConfig(node)
+	raw, inline := cfg[ConfigSubgraph]
+	_, byRef := cfg[ConfigSubgraphRef]
+
+	switch {
+	case inline == byRef:
+		return fmt.Errorf("subgraph nodes need exactly one of %s and %s", ConfigSubgraph, ConfigSubgraphRef)
+	case byRef:
+		// References are resolved when the node runs
+		if configString(cfg, ConfigSubgraphRef) == "" {
+			return fmt.Errorf("%s must name a registered graph", ConfigSubgraphRef)
+		}
+		return nil
+	}
+
+	child, err := inlineSubgraph(raw)
+	if err != nil {
+		return err
+	}
+	if err := v.Validate(child); err != nil {
+		return fmt.Errorf("invalid subgraph: %w", err)
+	}
+	return nil
+}
+
+// resolveSubgraph returns a fresh copy of the child graph of a subgraph node
+func (m *Manager) resolveSubgraph(ctx context.Context, node graph.Node) (*domain.Graph, error) {
+	cfg := nodeConfig(node)
+	if raw, ok := cfg[ConfigSubgraph]; ok {
+		return inlineSubgraph(raw)
+	}
+
+	ref := configString(cfg, ConfigSubgraphRef)
+	definitions, ok := m.storage.(storage.DefinitionStorage)
+	if !ok {
+		return nil, fmt.Errorf("graph %q is not registered", ref)
+	}
+	data, err := definitions.GetDefinition(ctx, ref)
+	if errors.Is(err, storage.ErrDefinitionNotFound) {
+		return nil, fmt.Errorf("graph %q is not registered", ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registered graph %q: %w", ref, err)
+	}
+	g, err := nodes.DecodeGraph(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode registered graph %q: %w", ref, err)
+	}
+	return g, nil
+}
+
+// parentLink reads the link of a child execution's graph to its subgraph node
+func parentLink(g *domain.Graph) (link map[string]interface{}, ok bool) {
+	if g == nil {
+		return nil, false
+	}
+	link, ok = g.Metadata[GraphParent].(map[string]interface{})
+	return link, ok && configString(link, "graph_id") != ""
+}
+
+// subgraphDepth returns how deeply an execution is nested in subgraph nodes
+func subgraphDepth(g *domain.Graph) int {
+	link, ok := parentLink(g)
+	if !ok {
+		return 0
+	}
+	depth, _ := configInt(link, "depth")
+	return depth
+}
+
+// ParentExecution returns the subgraph node that started an execution, or
+// nil for executions submitted directly
+func ParentExecution(state *domain.GraphState) *ExecutionLink {
+	link, ok := parentLink(state.Graph)
+	if !ok {
+		return nil
+	}
+	return &ExecutionLink{
+		GraphID: configString(link, "graph_id"),
+		NodeID:  configString(link, "node_id"),
+	}
+}
+
+// ChildExecutions returns the child executions started by the subgraph
+// nodes of an execution, keyed by the subgraph node in NodeID
+func ChildExecutions(state *domain.GraphState) []ExecutionLink {
+	var children []ExecutionLink
+	for nodeID, nodeState := range state.NodeStates {
+		if childID := configString(nodeState.Metadata, MetadataChildExecution); childID != "" {
+			children = append(children, ExecutionLink{GraphID: childID, NodeID: nodeID})
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].NodeID < children[j].NodeID
+	})
+	return children
+}
+
+// startSubgraph submits the child execution of a subgraph node, which stays
+// running until the child finishes. Callers must hold the execution lock.
+func (m *Manager) startSubgraph(ctx context.Context, graphID, nodeID string, state *executionState) error {
+	node := state.Graph.GetNode(nodeID)
+
+	// The child execution starts from the parent's state
+	inputs, err := nodeInputs(node, SharedState(state.GraphState))
+	if err != nil {
+		m.failNode(ctx, graphID, state, nodeID, err.Error(), "")
+		return nil
+	}
+
+	child, err := m.resolveSubgraph(ctx, node)
+	if err == nil && subgraphDepth(state.Graph) >= maxSubgraphDepth {
+		err = fmt.Errorf("subgraph nesting exceeds %d levels", maxSubgraphDepth)
+	}
+	if err != nil {
+		m.failNode(ctx, graphID, state, nodeID, err.Error(), ErrorClassSubgraph)
+		return nil
+	}
+
+	now := time.Now()
+	dispatchID := uuid.New().String()
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[nodeID]
+		nodeState.StartedAt = &now
+		nodeState.Status = domain.ExecutionStatusRunning
+		setNodeMetadata(nodeState, MetadataDispatchID, dispatchID)
+		delete(nodeState.Metadata, MetadataChildExecution)
+	})
+	if err != nil {
+		m.logger.Error("failed to save state before subgraph",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+	}
+
+	// The child reports back to this dispatch of the node
+	metadata := make(map[string]interface{}, len(child.Metadata)+1)
+	for key, value := range child.Metadata {
+		metadata[key] = value
+	}
+	metadata[GraphParent] = map[string]interface{}{
+		"graph_id":    graphID,
+		"node_id":     nodeID,
+		"dispatch_id": dispatchID,
+		"depth":       subgraphDepth(state.Graph) + 1,
+	}
+	child.Metadata = metadata
+
+	childID, err := m.SubmitGraph(ctx, child, inputs)
+	if err != nil {
+		m.failNode(ctx, graphID, state, nodeID, fmt.Sprintf("failed to start subgraph: %v", err), ErrorClassSubgraph)
+		return nil
+	}
+
+	err = m.updateState(ctx, state, func(s *domain.GraphState) {
+		setNodeMetadata(s.NodeStates[nodeID], MetadataChildExecution, childID)
+	})
+	if err != nil {
+		m.logger.Error("failed to save child execution",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.String("child_graph_id", childID),
+			zap.Error(err))
+	}
+
+	m.logger.Info("started subgraph",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID),
+		zap.String("child_graph_id", childID))
+
+	// Subgraph nodes only time out when they set a timeout of their own
+	m.armNodeTimeout(graphID, nodeID, now, m.nodeTimeoutFor(node))
+
+	// Publish node started event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, domain.EventTypeNodeStarted, map[string]interface{}{
+		"node_id":         nodeID,
+		"child_execution": childID,
+	})
+
+	return nil
+}
+
+// notifyParent completes the subgraph node that started a finished child
+// execution, the way a worker reports a node back
+func (m *Manager) notifyParent(ctx context.Context, state *domain.GraphState, status domain.ExecutionStatus, errorMsg string) {
+	link, ok := parentLink(state.Graph)
+	if !ok {
+		return
+	}
+	parentID := configString(link, "graph_id")
+
+	data := map[string]interface{}{
+		"node_id":         configString(link, "node_id"),
+		"dispatch_id":     configString(link, "dispatch_id"),
+		"child_execution": state.GraphID,
+	}
+	if status == domain.ExecutionStatusCompleted {
+		data["output"] = SharedState(state)
+	} else {
+		message := fmt.Sprintf("subgraph execution %s %s", state.GraphID, status)
+		if errorMsg != "" {
+			message += ": " + errorMsg
+		}
+		data["error"] = message
+		data["error_class"] = ErrorClassSubgraph
+	}
+
+	event := ports.Event{
+		ID:          uuid.New().String(),
+		Type:        ports.EventType(domain.EventTypeNodeCompleted),
+		Timestamp:   time.Now(),
+		ExecutionID: parentID,
+		Data:        data,
+	}
+	if err := m.eventBus.Publish(ctx, TopicNodeCompleted, event); err != nil {
+		m.logger.Error("failed to notify parent execution",
+			zap.String("graph_id", state.GraphID),
+			zap.String("parent_graph_id", parentID),
+			zap.Error(err))
+	}
+}
+
+// cancelChild cancels the child execution of a subgraph node that is still running
+func (m *Manager) cancelChild(ctx context.Context, state *domain.GraphState, nodeID string) {
+	nodeState := state.NodeStates[nodeID]
+	if nodeState == nil || nodeState.Status != domain.ExecutionStatusRunning {
+		return
+	}
+	childID := configString(nodeState.Metadata, MetadataChildExecution)
+	if childID == "" {
+		return
+	}
+	if _, ok := m.executions.Load(childID); !ok {
+		return
+	}
+
+	if err := m.CancelExecution(ctx, childID); err != nil {
+		m.logger.Warn("failed to cancel child execution",
+			zap.String("graph_id", state.GraphID),
+			zap.String("node_id", nodeID),
+			zap.String("child_graph_id", childID),
+			zap.Error(err))
+	}
+}
+
+// cancelChildren cancels the running child executions of a finished execution
+func (m *Manager) cancelChildren(ctx context.Context, state *domain.GraphState) {
+	for _, child := range ChildExecutions(state) {
+		m.cancelChild(ctx, state, child.NodeID)
+	}
+}
+
+// reconcileChild reports a child execution back to its subgraph node when
+// the child finished while the parent was not listening
+func (m *Manager) reconcileChild(ctx context.Context, nodeState *domain.NodeState) {
+	childID := configString(nodeState.Metadata, MetadataChildExecution)
+	if childID == "" {
+		return
+	}
+	child, err := m.loadState(ctx, childID)
+	if err != nil || executionActive(child.Status) {
+		return
+	}
+	m.notifyParent(ctx, child.GraphState, child.Status, child.Error)
+}
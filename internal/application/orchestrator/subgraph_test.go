@@ -0,0 +1,135 @@
+package orchestrator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/nodes"
+)
+
+// childGraph runs one executor, work. Inline child graphs are decoded like
+// submitted ones, so the executor declares its type.
+func childGraph(work string) *domain.Graph {
+	executor := &graph.ExecutorNode{
+		BaseNode:     graph.BaseNode{ID: work, Type: graph.NodeTypeExecutor},
+		ExecutorType: "llm",
+	}
+	return newGraph("child_start",
+		[]graph.Node{startNode("child_start"), executor, endNode("child_end")},
+		edge("child_start", work), edge(work, "child_end"))
+}
+
+// parentGraph runs a subgraph node, sub, between start and end
+func parentGraph(subCfg map[string]interface{}) *domain.Graph {
+	return newGraph("start",
+		[]graph.Node{startNode("start"), nodes.New("sub", NodeTypeSubgraph, subCfg), endNode("end")},
+		edge("start", "sub"), edge("sub", "end"))
+}
+
+// tagInput answers each node with the topic it was given
+func tagInput(data map[string]interface{}) map[string]interface{} {
+	if data["node_id"] == "broken" {
+		return map[string]interface{}{"error": "child failed"}
+	}
+	state, _ := data["state"].(map[string]interface{})
+	return map[string]interface{}{"output": map[string]interface{}{"tagged": state["topic"]}}
+}
+
+func TestSubgraphRunsChildExecution(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t, tagInput)
+
+	graphID := h.submit(t, parentGraph(map[string]interface{}{ConfigSubgraph: childGraph("tag")}),
+		map[string]interface{}{"topic": "billing"})
+	state := h.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+
+	// The child's shared state is the subgraph node's output
+	want := map[string]interface{}{"topic": "billing", "tagged": "billing"}
+	if got := state.NodeStates["sub"].Output; !reflect.DeepEqual(got, want) {
+		t.Errorf("subgraph output = %v, want %v", got, want)
+	}
+
+	children := ChildExecutions(state)
+	if len(children) != 1 || children[0].NodeID != "sub" {
+		t.Fatalf("children = %v, want one started by sub", children)
+	}
+	child, err := h.manager.GetStatus(ctx, children[0].GraphID)
+	if err != nil {
+		t.Fatalf("GetStatus(child) error = %v", err)
+	}
+	if child.Status != domain.ExecutionStatusCompleted {
+		t.Errorf("child status = %s, want completed", child.Status)
+	}
+	if parent := ParentExecution(child); parent == nil || *parent != (ExecutionLink{GraphID: graphID, NodeID: "sub"}) {
+		t.Errorf("child parent = %v, want %s/sub", parent, graphID)
+	}
+}
+
+func TestSubgraphFailsWithItsChild(t *testing.T) {
+	h := newTestHarness(t, tagInput)
+	if err := h.manager.RegisterGraph(context.Background(), "broken-child", childGraph("broken")); err != nil {
+		t.Fatalf("RegisterGraph() error = %v", err)
+	}
+
+	state := h.waitDone(t, h.submit(t, parentGraph(map[string]interface{}{ConfigSubgraphRef: "broken-child"}), nil))
+	if state.Status != domain.ExecutionStatusFailed {
+		t.Fatalf("status = %s, want failed", state.Status)
+	}
+	assertNodeStatus(t, state, "sub", domain.ExecutionStatusFailed)
+}
+
+func TestSubgraphResolvesReferenceRegisteredOnAnotherReplica(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t, tagInput)
+	other := newTestHarnessWithStore(t, h.store, tagInput)
+	if err := other.manager.RegisterGraph(ctx, "tag-child", childGraph("tag")); err != nil {
+		t.Fatalf("RegisterGraph() error = %v", err)
+	}
+
+	state := h.waitDone(t, h.submit(t, parentGraph(map[string]interface{}{ConfigSubgraphRef: "tag-child"}),
+		map[string]interface{}{"topic": "billing"}))
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	if got := state.NodeStates["sub"].Output; !reflect.DeepEqual(got, map[string]interface{}{"topic": "billing", "tagged": "billing"}) {
+		t.Errorf("subgraph output = %v, want the registered child's", got)
+	}
+}
+
+func TestSubgraphWithUnknownReferenceFails(t *testing.T) {
+	h := newTestHarness(t, tagInput)
+	state := h.waitDone(t, h.submit(t, parentGraph(map[string]interface{}{ConfigSubgraphRef: "missing"}), nil))
+	if state.Status != domain.ExecutionStatusFailed {
+		t.Fatalf("status = %s, want failed", state.Status)
+	}
+	if got := len(ChildExecutions(state)); got != 0 {
+		t.Errorf("started %d child executions, want 0", got)
+	}
+}
+
+func TestCancellingParentCancelsChild(t *testing.T) {
+	ctx := context.Background()
+	// The child's worker never reports back
+	h := newTestHarness(t, func(map[string]interface{}) map[string]interface{} { return nil })
+
+	graphID := h.submit(t, parentGraph(map[string]interface{}{ConfigSubgraph: childGraph("hang")}), nil)
+	state := h.waitFor(t, graphID, func(state *domain.GraphState) bool {
+		return len(ChildExecutions(state)) == 1
+	})
+	childID := ChildExecutions(state)[0].GraphID
+	h.waitNode(t, childID, "hang", domain.ExecutionStatusRunning)
+
+	if err := h.manager.CancelExecution(ctx, graphID); err != nil {
+		t.Fatalf("CancelExecution() error = %v", err)
+	}
+	child := h.waitDone(t, childID)
+	if child.Status != domain.ExecutionStatusCancelled {
+		t.Errorf("child status = %s, want cancelled", child.Status)
+	}
+}
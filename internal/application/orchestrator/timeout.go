@@ -0,0 +1,171 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"go.uber.org/zap"
+)
+
+// ConfigTimeout overrides the manager's node execution timeout for a node
+const ConfigTimeout = "timeout"
+
+// EventTypeNodeTimeout is published when a node misses its execution deadline
+const EventTypeNodeTimeout domain.EventType = "node.timeout"
+
+// nodeTimeoutFor returns the execution deadline of a node, preferring the
+// node's own timeout setting over the manager default
+func (m *Manager) nodeTimeoutFor(node graph.Node) time.Duration {
+	if timeout, ok := configDuration(nodeConfig(node), ConfigTimeout); ok && timeout > 0 {
+		return timeout
+	}
+	// Subgraph nodes wait for a child execution, which has a deadline of its
+	// own, map nodes for items with the deadline of their executor node, and
+	// human, signal and delay nodes for as long as it takes
+	switch node.GetType() {
+	case NodeTypeSubgraph, NodeTypeMap, NodeTypeHuman, NodeTypeWaitForSignal, NodeTypeDelay:
+		return 0
+	}
+	return m.nodeTimeout
+}
+
+// armNodeTimeout starts the execution deadline of a dispatched node.
+// Callers must hold the execution lock.
+func (m *Manager) armNodeTimeout(graphID, nodeID string, startedAt time.Time, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+
+	m.armNodeTimer(nodeTimer{
+		Kind:      timerNodeTimeout,
+		GraphID:   graphID,
+		NodeID:    nodeID,
+		StartedAt: &startedAt,
+		Timeout:   timeout,
+	}, timeout)
+}
+
+// armNodeTimer schedules a node timer, replacing any pending timer of that
+// node. The replica looking after the execution keeps it in process; any
+// other replica, such as one applying a completion delivered to it, keeps
+// it in the state storage so that it fires wherever it is claimed.
+// Callers must hold the execution lock.
+func (m *Manager) armNodeTimer(timer nodeTimer, delay time.Duration) {
+	val, ok := m.executions.Load(timer.GraphID)
+	if !ok {
+		if err := m.scheduleNodeTimer(context.Background(), timer, time.Now().Add(delay)); err != nil {
+			m.logger.Error("failed to schedule node timer",
+				zap.String("graph_id", timer.GraphID),
+				zap.String("node_id", timer.NodeID),
+				zap.String("kind", timer.Kind),
+				zap.Error(err))
+		}
+		return
+	}
+
+	execCtx := val.(*executionContext)
+	key := timer.key()
+	if pending, ok := execCtx.nodeTimers[key]; ok {
+		pending.Stop()
+	}
+	execCtx.nodeTimers[key] = time.AfterFunc(delay, func() {
+		if err := m.fireNodeTimer(context.Background(), timer); err != nil {
+			m.logger.Error("failed to fire node timer",
+				zap.String("graph_id", timer.GraphID),
+				zap.String("node_id", timer.NodeID),
+				zap.String("kind", timer.Kind),
+				zap.Error(err))
+		}
+	})
+}
+
+// stopNodeTimeout disarms the deadline of a node that reported back.
+// Durable timers cannot be disarmed; they fire and find the node moved on.
+// Callers must hold the execution lock.
+func (m *Manager) stopNodeTimeout(graphID, nodeID string) {
+	val, ok := m.executions.Load(graphID)
+	if !ok {
+		return
+	}
+
+	execCtx := val.(*executionContext)
+	if timer, ok := execCtx.nodeTimers[nodeID]; ok {
+		timer.Stop()
+		delete(execCtx.nodeTimers, nodeID)
+	}
+}
+
+// stopNodeTimers disarms every node deadline of an execution
+func stopNodeTimers(execCtx *executionContext) {
+	for nodeID, timer := range execCtx.nodeTimers {
+		timer.Stop()
+		delete(execCtx.nodeTimers, nodeID)
+	}
+}
+
+// handleNodeTimeout fails a node that did not complete within its deadline
+func (m *Manager) handleNodeTimeout(graphID, nodeID string, startedAt time.Time, timeout time.Duration) {
+	ctx := context.Background()
+
+	unlock := m.lockExecution(graphID)
+	defer unlock()
+
+	state, err := m.loadState(ctx, graphID)
+	if err != nil {
+		m.logger.Error("failed to get state during node timeout",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(err))
+		return
+	}
+
+	if !executionActive(state.Status) {
+		return
+	}
+
+	// Ignore timers that fired after the node completed or was re-dispatched
+	nodeState := state.NodeStates[nodeID]
+	if nodeState == nil || nodeState.Status != domain.ExecutionStatusRunning ||
+		nodeState.StartedAt == nil || !nodeState.StartedAt.Equal(startedAt) {
+		return
+	}
+
+	// A signal node redeemed on another replica completes through the event
+	// bus; its deadline no longer applies
+	if signaledAt, ok := metadataTime(nodeState.Metadata, MetadataSignaledAt); ok && !signaledAt.Before(startedAt) {
+		return
+	}
+
+	// A human node answered on another replica completes through the event
+	// bus as well
+	if nodeState.Metadata[MetadataHumanResponse] != nil && !awaitingInput(nodeState) {
+		return
+	}
+
+	// Human nodes take their default action instead of failing
+	if awaitingInput(nodeState) && state.Graph.GetNode(nodeID).GetType() == NodeTypeHuman {
+		m.expireInput(ctx, graphID, state, nodeID, timeout)
+		return
+	}
+
+	m.logger.Warn("node execution timed out",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID),
+		zap.Duration("timeout", timeout))
+
+	// Publish timeout event (ignore error as it's non-critical)
+	_ = m.publishGraphEvent(ctx, graphID, EventTypeNodeTimeout, map[string]interface{}{
+		"node_id": nodeID,
+		"timeout": timeout.String(),
+	})
+
+	// A subgraph node's child execution is abandoned with it
+	m.cancelChild(ctx, state.GraphState, nodeID)
+	// A signal node's token is revoked with it
+	m.revokeSignal(ctx, state, nodeID)
+
+	m.failNode(ctx, graphID, state, nodeID, fmt.Sprintf("node execution timeout after %s", timeout), ErrorClassTimeout)
+}
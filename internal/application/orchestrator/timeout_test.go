@@ -0,0 +1,58 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/nodes"
+)
+
+func TestNodeTimeoutFailsSilentNode(t *testing.T) {
+	// The worker never reports on slow
+	h := newTestHarness(t, func(data map[string]interface{}) map[string]interface{} {
+		if data["node_id"] == "slow" {
+			return nil
+		}
+		return map[string]interface{}{"output": map[string]interface{}{}}
+	})
+
+	g := newGraph("start",
+		[]graph.Node{startNode("start"), executorNode("slow", map[string]interface{}{ConfigTimeout: "50ms"}), endNode("end")},
+		edge("start", "slow"), edge("slow", "end"))
+	graphID := h.submit(t, g, nil)
+
+	state := h.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusFailed {
+		t.Fatalf("status = %s, want failed", state.Status)
+	}
+	assertNodeStatus(t, state, "slow", domain.ExecutionStatusFailed)
+	if err := state.NodeStates["slow"].Error; !strings.Contains(err, "timeout") {
+		t.Errorf("node error = %q, want a timeout", err)
+	}
+	if got := len(h.eventsOf(EventTypeNodeTimeout)); got != 1 {
+		t.Errorf("published %d node.timeout events, want 1", got)
+	}
+}
+
+func TestNodeTimeoutFor(t *testing.T) {
+	m := &Manager{nodeTimeout: time.Minute}
+	tests := []struct {
+		name string
+		node graph.Node
+		want time.Duration
+	}{
+		{"manager default", executorNode("a", nil), time.Minute},
+		{"node setting", executorNode("a", map[string]interface{}{ConfigTimeout: "5s"}), 5 * time.Second},
+		{"zero keeps the default", executorNode("a", map[string]interface{}{ConfigTimeout: "0s"}), time.Minute},
+		{"human nodes wait without one", nodes.New("h", NodeTypeHuman, nil), 0},
+		{"unless they set one", nodes.New("h", NodeTypeHuman, map[string]interface{}{ConfigTimeout: "1h"}), time.Hour},
+	}
+	for _, tt := range tests {
+		if got := m.nodeTimeoutFor(tt.node); got != tt.want {
+			t.Errorf("%s: nodeTimeoutFor() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aescanero/dago/pkg/adapters/storage"
+	"go.uber.org/zap"
+)
+
+// Durable timers are polled rather than pushed, so they fire within
+// timerPollInterval of their due time
+const (
+	timerPollInterval = time.Second
+	timerClaimLimit   = 100
+)
+
+// Kinds of node timers, and of the graph deadline kept as a durable timer.
+// Delay timers have no kind, as durable delay timers were scheduled before
+// the other kinds existed.
+const (
+	timerDelay          = ""
+	timerNodeTimeout    = "node_timeout"
+	timerNodeRetry      = "node_retry"
+	timerMapItemTimeout = "map_item_timeout"
+	timerMapItemRetry   = "map_item_retry"
+	timerGraphTimeout   = "graph_timeout"
+)
+
+// nodeTimer describes what a timer of a node or map item does when it
+// fires, so that it can be kept as a durable timer. Its JSON encoding is
+// the durable timer's ID.
+type nodeTimer struct {
+	Kind       string        `json:"kind,omitempty"`
+	GraphID    string        `json:"graph_id"`
+	NodeID     string        `json:"node_id"`
+	DispatchID string        `json:"dispatch_id,omitempty"`
+	ItemIndex  int           `json:"item_index,omitempty"`
+	Attempt    int           `json:"attempt,omitempty"`
+	StartedAt  *time.Time    `json:"started_at,omitempty"`
+	Timeout    time.Duration `json:"timeout,omitempty"`
+}
+
+// key returns the name an in-process timer is kept under: a node holds one
+// timer at a time, and so does each item of a map node
+func (t nodeTimer) key() string {
+	switch t.Kind {
+	case timerMapItemTimeout, timerMapItemRetry:
+		return mapItemTimer(t.NodeID, t.ItemIndex)
+	}
+	return t.NodeID
+}
+
+// fireNodeTimer runs a timer that fell due. Each handler reloads the state
+// and ignores timers whose node moved on since they were armed.
+func (m *Manager) fireNodeTimer(ctx context.Context, timer nodeTimer) error {
+	switch timer.Kind {
+	case timerNodeTimeout:
+		if timer.StartedAt != nil {
+			m.handleNodeTimeout(timer.GraphID, timer.NodeID, *timer.StartedAt, timer.Timeout)
+		}
+	case timerNodeRetry:
+		m.retryNode(timer.GraphID, timer.NodeID, timer.Attempt)
+	case timerMapItemTimeout:
+		m.handleMapItemTimeout(timer.GraphID, timer.NodeID, timer.ItemIndex, timer.DispatchID, timer.Timeout)
+	case timerMapItemRetry:
+		m.retryMapItem(timer.GraphID, timer.NodeID, timer.ItemIndex, timer.Attempt)
+	case timerGraphTimeout:
+		m.handleTimeout(timer.GraphID)
+	case timerDelay:
+		return m.resumeDelay(ctx, timer)
+	default:
+		return fmt.Errorf("unknown timer kind %q", timer.Kind)
+	}
+	return nil
+}
+
+// scheduleNodeTimer keeps a node timer in the state storage until due
+func (m *Manager) scheduleNodeTimer(ctx context.Context, timer nodeTimer, due time.Time) error {
+	timers, ok := m.storage.(storage.TimerStorage)
+	if !ok {
+		return fmt.Errorf("state storage does not keep durable timers")
+	}
+
+	timerID, err := json.Marshal(timer)
+	if err != nil {
+		return fmt.Errorf("failed to encode timer: %w", err)
+	}
+	return timers.ScheduleTimer(ctx, string(timerID), due)
+}
+
+// pollTimers fires the durable timers that fell due. Claiming a timer
+// removes it, so each fires on one replica only.
+func (m *Manager) pollTimers(timers storage.TimerStorage) {
+	ticker := time.NewTicker(timerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.fireDueTimers(timers)
+		}
+	}
+}
+
+// fireDueTimers claims and fires every timer due by now
+func (m *Manager) fireDueTimers(timers storage.TimerStorage) {
+	for {
+		due, err := timers.ClaimDueTimers(m.ctx, time.Now(), timerClaimLimit)
+		if err != nil {
+			m.logger.Error("failed to claim due timers", zap.Error(err))
+			return
+		}
+
+		for _, timerID := range due {
+			var timer nodeTimer
+			if err := json.Unmarshal([]byte(timerID), &timer); err != nil {
+				m.logger.Error("dropping unreadable timer",
+					zap.String("timer_id", timerID),
+					zap.Error(err))
+				continue
+			}
+
+			if err := m.fireNodeTimer(m.ctx, timer); err != nil {
+				m.logger.Error("failed to fire timer, rescheduling",
+					zap.String("graph_id", timer.GraphID),
+					zap.String("node_id", timer.NodeID),
+					zap.String("kind", timer.Kind),
+					zap.Error(err))
+				// Claimed timers are gone; put it back to fire again
+				_ = timers.ScheduleTimer(m.ctx, timerID, time.Now().Add(timerPollInterval))
+			}
+		}
+
+		if len(due) < timerClaimLimit {
+			return
+		}
+	}
+}
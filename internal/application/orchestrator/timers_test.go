@@ -0,0 +1,113 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/google/uuid"
+)
+
+// deliverCompletion hands a node completion to a harness's manager, as the
+// event bus does when it delivers a worker's completion to that replica
+func deliverCompletion(t *testing.T, h *testHarness, graphID string, data map[string]interface{}) {
+	t.Helper()
+	err := h.bus.Publish(context.Background(), TopicNodeCompleted, ports.Event{
+		ID:          uuid.New().String(),
+		Type:        ports.EventType(domain.EventTypeNodeCompleted),
+		Timestamp:   time.Now(),
+		ExecutionID: graphID,
+		Data:        data,
+	})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+}
+
+func TestReplicaArmsRetryOfCompletionDeliveredToIt(t *testing.T) {
+	// The owner's worker never reports back; the replica's succeeds
+	owner := newTestHarness(t, func(map[string]interface{}) map[string]interface{} { return nil })
+	replica := newTestHarnessWithStore(t, owner.store, reply("done"))
+
+	retry := map[string]interface{}{"retry": map[string]interface{}{"max_attempts": 2, "backoff": "1ms"}}
+	g := newGraph("s",
+		[]graph.Node{startNode("s"), executorNode("a", retry), endNode("e")},
+		edge("s", "a"),
+		edge("a", "e"),
+	)
+	graphID := owner.submit(t, g, nil)
+	running := owner.waitNode(t, graphID, "a", domain.ExecutionStatusRunning)
+
+	// The failure of the first attempt reaches the replica, which does not
+	// look after the execution
+	deliverCompletion(t, replica, graphID, map[string]interface{}{
+		"node_id":     "a",
+		"dispatch_id": running.NodeStates["a"].Metadata[MetadataDispatchID],
+		"error":       "worker overloaded",
+	})
+
+	state := owner.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed by the retry", state.Status, state.Error)
+	}
+	if got := len(owner.workFor("a")) + len(replica.workFor("a")); got != 2 {
+		t.Errorf("a dispatched %d times, want 2", got)
+	}
+}
+
+func TestReplicaArmsDeadlineOfNodeItDispatches(t *testing.T) {
+	// No worker reports b back
+	silent := func(map[string]interface{}) map[string]interface{} { return nil }
+	owner := newTestHarness(t, silent)
+	replica := newTestHarnessWithStore(t, owner.store, silent)
+
+	g := newGraph("s",
+		[]graph.Node{
+			startNode("s"),
+			executorNode("a", nil),
+			executorNode("b", map[string]interface{}{ConfigTimeout: "100ms"}),
+			endNode("e"),
+		},
+		edge("s", "a"),
+		edge("a", "b"),
+		edge("b", "e"),
+	)
+	graphID := owner.submit(t, g, nil)
+	running := owner.waitNode(t, graphID, "a", domain.ExecutionStatusRunning)
+
+	// The replica applies a's completion and dispatches b
+	deliverCompletion(t, replica, graphID, map[string]interface{}{
+		"node_id":     "a",
+		"dispatch_id": running.NodeStates["a"].Metadata[MetadataDispatchID],
+		"output":      "done",
+	})
+
+	state := owner.waitDone(t, graphID)
+	if state.Status != domain.ExecutionStatusFailed {
+		t.Fatalf("status = %s, want failed by b's deadline", state.Status)
+	}
+	assertNodeStatus(t, state, "b", domain.ExecutionStatusFailed)
+	if got := lastErrorClass(state, "b"); got != ErrorClassTimeout {
+		t.Errorf("b error class = %q, want %q", got, ErrorClassTimeout)
+	}
+}
+
+func TestNodeTimerKeys(t *testing.T) {
+	delay, err := json.Marshal(nodeTimer{GraphID: "g", NodeID: "wait", DispatchID: "d1"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	// Durable delay timers keep the ID they had before timers had kinds
+	if want := `{"graph_id":"g","node_id":"wait","dispatch_id":"d1"}`; string(delay) != want {
+		t.Errorf("delay timer ID = %s, want %s", delay, want)
+	}
+
+	item := nodeTimer{Kind: timerMapItemRetry, GraphID: "g", NodeID: "m", ItemIndex: 2}
+	if got := item.key(); got != mapItemTimer("m", 2) {
+		t.Errorf("map item timer key = %q, want %q", got, mapItemTimer("m", 2))
+	}
+}
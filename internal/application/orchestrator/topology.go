@@ -0,0 +1,182 @@
+package orchestrator
+
+import (
+	"sort"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+// adjacency maps each node to the nodes it can hand over to: edge targets
+// plus the targets of router routes
+type adjacency map[string][]string
+
+// buildAdjacency collects the successors of every node. Declared loop edges
+// are left out when includeLoops is false.
+func buildAdjacency(g *domain.Graph, includeLoops bool) adjacency {
+	adj := make(adjacency, len(g.Nodes))
+	add := func(from, to string) {
+		if _, ok := g.Nodes[to]; !ok {
+			return
+		}
+		for _, existing := range adj[from] {
+			if existing == to {
+				return
+			}
+		}
+		adj[from] = append(adj[from], to)
+	}
+
+	for _, edge := range g.Edges {
+		if !includeLoops && isLoopEdge(edge) {
+			continue
+		}
+		add(edge.From, edge.To)
+	}
+
+	for _, nodeID := range sortedNodeIDs(g) {
+		router, ok := g.Nodes[nodeID].(*graph.RouterNode)
+		if !ok {
+			continue
+		}
+		for _, route := range router.Routes {
+			add(nodeID, route.Target)
+		}
+		if router.DefaultRoute != "" {
+			add(nodeID, router.DefaultRoute)
+		}
+	}
+
+	return adj
+}
+
+// findCycle returns a cycle as a path whose first and last node are the
+// same, or nil when the graph is acyclic
+func findCycle(g *domain.Graph, adj adjacency) []string {
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	marks := make(map[string]int, len(g.Nodes))
+	var stack []string
+
+	var visit func(nodeID string) []string
+	visit = func(nodeID string) []string {
+		marks[nodeID] = onStack
+		stack = append(stack, nodeID)
+
+		for _, next := range adj[nodeID] {
+			switch marks[next] {
+			case onStack:
+				// Back edge: the cycle runs from next's stack position to here
+				for i, id := range stack {
+					if id == next {
+						path := append([]string{}, stack[i:]...)
+						return append(path, next)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		marks[nodeID] = done
+		return nil
+	}
+
+	for _, nodeID := range sortedNodeIDs(g) {
+		if marks[nodeID] == unvisited {
+			if cycle := visit(nodeID); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// unreachableNodes returns the nodes that cannot be reached from the entry node
+func unreachableNodes(g *domain.Graph, adj adjacency) []string {
+	reached := map[string]bool{g.EntryNode: true}
+	queue := []string{g.EntryNode}
+	for len(queue) > 0 {
+		nodeID := queue[0]
+		queue = queue[1:]
+		for _, next := range adj[nodeID] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	var unreachable []string
+	for _, nodeID := range sortedNodeIDs(g) {
+		if !reached[nodeID] {
+			unreachable = append(unreachable, nodeID)
+		}
+	}
+	return unreachable
+}
+
+// deadEndNodes returns the nodes from which no end node can be reached
+func deadEndNodes(g *domain.Graph, adj adjacency) []string {
+	reverse := make(adjacency, len(adj))
+	for from, targets := range adj {
+		for _, to := range targets {
+			reverse[to] = append(reverse[to], from)
+		}
+	}
+
+	reaches := make(map[string]bool)
+	var queue []string
+	for nodeID, node := range g.Nodes {
+		if node != nil && node.GetType() == graph.NodeTypeEnd {
+			reaches[nodeID] = true
+			queue = append(queue, nodeID)
+		}
+	}
+	for len(queue) > 0 {
+		nodeID := queue[0]
+		queue = queue[1:]
+		for _, prev := range reverse[nodeID] {
+			if !reaches[prev] {
+				reaches[prev] = true
+				queue = append(queue, prev)
+			}
+		}
+	}
+
+	var deadEnds []string
+	for _, nodeID := range sortedNodeIDs(g) {
+		if !reaches[nodeID] {
+			deadEnds = append(deadEnds, nodeID)
+		}
+	}
+	return deadEnds
+}
+
+// hasEdges reports whether any edge or router route leads to or from a node
+func hasEdges(g *domain.Graph, nodeID string) bool {
+	for from, targets := range buildAdjacency(g, true) {
+		for _, to := range targets {
+			if from == nodeID || to == nodeID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortedNodeIDs returns the node IDs of a graph in a stable order
+func sortedNodeIDs(g *domain.Graph) []string {
+	ids := make([]string, 0, len(g.Nodes))
+	for nodeID := range g.Nodes {
+		ids = append(ids, nodeID)
+	}
+	sort.Strings(ids)
+	return ids
+}
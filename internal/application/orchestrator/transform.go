@@ -0,0 +1,167 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/internal/expression"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// NodeTypeTransform is the type of nodes the orchestrator evaluates itself,
+// reshaping the state they select into their output
+const NodeTypeTransform graph.NodeType = "transform"
+
+// Transform node settings, of which a node sets exactly one
+const (
+	// ConfigTransformExpression is an expression whose value is the output
+	ConfigTransformExpression = "expression"
+
+	// ConfigTransformFields maps the fields of an object output to
+	// expressions
+	ConfigTransformFields = "fields"
+
+	// ConfigTransformTemplate is a text template whose rendering is the
+	// output
+	ConfigTransformTemplate = "template"
+)
+
+// ErrorClassTransform is the error class of a transform that failed
+const ErrorClassTransform = "transform"
+
+// transformSpec holds the compiled settings of a transform node
+type transformSpec struct {
+	expression *expression.Program
+	fields     map[string]*expression.Program
+	template   *expression.Template
+}
+
+// parseTransformSpec reads and compiles the settings of a transform node
+func parseTransformSpec(cfg map[string]interface{}) (transformSpec, error) {
+	var spec transformSpec
+	set := 0
+	for _, key := range []string{ConfigTransformExpression, ConfigTransformFields, ConfigTransformTemplate} {
+		if _, ok := cfg[key]; ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return spec, fmt.Errorf("transform nodes need exactly one of %s, %s and %s",
+			ConfigTransformExpression, ConfigTransformFields, ConfigTransformTemplate)
+	}
+
+	_, hasExpression := cfg[ConfigTransformExpression]
+	_, hasFields := cfg[ConfigTransformFields]
+
+	var err error
+	switch {
+	case hasExpression:
+		source, ok := cfg[ConfigTransformExpression].(string)
+		if !ok {
+			return spec, fmt.Errorf("%s must be a string", ConfigTransformExpression)
+		}
+		spec.expression, err = expression.Compile(source)
+	case hasFields:
+		fields, ok := cfg[ConfigTransformFields].(map[string]interface{})
+		if !ok {
+			return spec, fmt.Errorf("%s must map output fields to expressions", ConfigTransformFields)
+		}
+		spec.fields = make(map[string]*expression.Program, len(fields))
+		for name, raw := range fields {
+			source, ok := raw.(string)
+			if !ok {
+				return spec, fmt.Errorf("%s %s must be an expression string", ConfigTransformFields, name)
+			}
+			if spec.fields[name], err = expression.Compile(source); err != nil {
+				return spec, fmt.Errorf("%s %s: %w", ConfigTransformFields, name, err)
+			}
+		}
+	default:
+		source, ok := cfg[ConfigTransformTemplate].(string)
+		if !ok {
+			return spec, fmt.Errorf("%s must be a string", ConfigTransformTemplate)
+		}
+		spec.template, err = expression.CompileTemplate(source)
+	}
+	return spec, err
+}
+
+// evaluate computes a transform's output over the state the node selects,
+// whose fields are top-level names and which is whole as state
+func (spec transformSpec) evaluate(inputs map[string]interface{}) (interface{}, error) {
+	env := mappingEnv(inputs, "state")
+	switch {
+	case spec.expression != nil:
+		return spec.expression.Eval(env)
+	case spec.template != nil:
+		return spec.template.Render(env)
+	}
+
+	names := make([]string, 0, len(spec.fields))
+	for name := range spec.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	output := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		value, err := spec.fields[name].Eval(env)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		output[name] = value
+	}
+	return output, nil
+}
+
+// runTransform evaluates a transform node and reports it back the way a
+// worker reports a node. Callers must hold the execution lock.
+func (m *Manager) runTransform(ctx context.Context, graphID, nodeID string, state *executionState) error {
+	node := state.Graph.GetNode(nodeID)
+
+	var output interface{}
+	spec, err := parseTransformSpec(nodeConfig(node))
+	if err == nil {
+		var inputs map[string]interface{}
+		if inputs, err = nodeInputs(node, SharedState(state.GraphState)); err == nil {
+			output, err = spec.evaluate(inputs)
+		}
+	}
+
+	now := time.Now()
+	dispatchID := uuid.New().String()
+	saveErr := m.updateState(ctx, state, func(s *domain.GraphState) {
+		nodeState := s.NodeStates[nodeID]
+		nodeState.StartedAt = &now
+		nodeState.Status = domain.ExecutionStatusRunning
+		setNodeMetadata(nodeState, MetadataDispatchID, dispatchID)
+	})
+	if saveErr != nil {
+		m.logger.Error("failed to save state before transform",
+			zap.String("graph_id", graphID),
+			zap.String("node_id", nodeID),
+			zap.Error(saveErr))
+	}
+
+	m.logger.Info("transformed state",
+		zap.String("graph_id", graphID),
+		zap.String("node_id", nodeID),
+		zap.Bool("ok", err == nil))
+
+	data := map[string]interface{}{
+		"node_id":     nodeID,
+		"dispatch_id": dispatchID,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+		data["error_class"] = ErrorClassTransform
+	} else {
+		data["output"] = output
+	}
+	return m.publishCompletion(ctx, graphID, data)
+}
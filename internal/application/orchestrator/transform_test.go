@@ -0,0 +1,109 @@
+package orchestrator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/nodes"
+)
+
+// transformGraph runs a transform node, shape, between start and end
+func transformGraph(shapeCfg map[string]interface{}) *domain.Graph {
+	return newGraph("start",
+		[]graph.Node{startNode("start"), nodes.New("shape", NodeTypeTransform, shapeCfg), endNode("end")},
+		edge("start", "shape"), edge("shape", "end"))
+}
+
+// docInputs is the input of the transform tests
+func docInputs() map[string]interface{} {
+	return map[string]interface{}{
+		"doc": map[string]interface{}{"title": "quarterly report", "pages": 12.0},
+		"results": []interface{}{
+			map[string]interface{}{"id": "r1"},
+			map[string]interface{}{"id": "r2"},
+		},
+	}
+}
+
+func TestTransformForms(t *testing.T) {
+	tests := []struct {
+		name     string
+		shapeCfg map[string]interface{}
+		want     interface{}
+	}{
+		{"expression", map[string]interface{}{ConfigTransformExpression: "doc.pages * 2"}, 24.0},
+		{"fields", map[string]interface{}{ConfigTransformFields: map[string]interface{}{
+			"title": "upper(doc.title)",
+			"ids":   "jsonpath(state, '$.results[*].id')",
+		}}, map[string]interface{}{"title": "QUARTERLY REPORT", "ids": []interface{}{"r1", "r2"}}},
+		{"template", map[string]interface{}{ConfigTransformTemplate: "{{ doc.title }}: {{ doc.pages }} pages"}, "quarterly report: 12 pages"},
+		{"input mapping", map[string]interface{}{
+			ConfigInputMapping:        map[string]interface{}{"title": "doc.title"},
+			ConfigTransformExpression: "state",
+		}, map[string]interface{}{"title": "quarterly report"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, reply(map[string]interface{}{}))
+			state := h.waitDone(t, h.submit(t, transformGraph(tt.shapeCfg), docInputs()))
+			if state.Status != domain.ExecutionStatusCompleted {
+				t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+			}
+			if got := state.NodeStates["shape"].Output; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("shape output = %v, want %v", got, tt.want)
+			}
+			if got := len(h.workFor("shape")); got != 0 {
+				t.Errorf("published %d work events for the transform node, want 0", got)
+			}
+		})
+	}
+}
+
+func TestTransformOutputIsMappedIntoSharedState(t *testing.T) {
+	h := newTestHarness(t, reply(map[string]interface{}{}))
+	state := h.waitDone(t, h.submit(t, transformGraph(map[string]interface{}{
+		ConfigTransformTemplate: "{{ doc.title }}",
+		ConfigOutputMapping:     map[string]interface{}{"heading": "output"},
+	}), docInputs()))
+
+	if state.Status != domain.ExecutionStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", state.Status, state.Error)
+	}
+	if got := SharedState(state)["heading"]; got != "quarterly report" {
+		t.Errorf("shared heading = %v, want the rendered template", got)
+	}
+}
+
+func TestTransformFailsWithErrorClass(t *testing.T) {
+	h := newTestHarness(t, reply(map[string]interface{}{}))
+	state := h.waitDone(t, h.submit(t, transformGraph(map[string]interface{}{
+		ConfigTransformExpression: "doc.title - 1",
+	}), docInputs()))
+
+	if state.Status != domain.ExecutionStatusFailed {
+		t.Fatalf("status = %s, want failed", state.Status)
+	}
+	assertNodeStatus(t, state, "shape", domain.ExecutionStatusFailed)
+	if got := lastErrorClass(state, "shape"); got != ErrorClassTransform {
+		t.Errorf("error class = %q, want %q", got, ErrorClassTransform)
+	}
+}
+
+func TestTransformSettingsAreValidatedOnSubmission(t *testing.T) {
+	h := newTestHarness(t, reply(map[string]interface{}{}))
+	for _, shapeCfg := range []map[string]interface{}{
+		{},
+		{ConfigTransformExpression: "1", ConfigTransformTemplate: "x"},
+		{ConfigTransformExpression: "1 +"},
+		{ConfigTransformFields: map[string]interface{}{"n": 1}},
+		{ConfigTransformTemplate: "{{ unclosed"},
+	} {
+		if _, err := h.manager.SubmitGraph(context.Background(), transformGraph(shapeCfg), nil); err == nil {
+			t.Errorf("SubmitGraph() with %v succeeded", shapeCfg)
+		}
+	}
+}
@@ -2,11 +2,40 @@ package orchestrator
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/aescanero/dago-libs/pkg/domain"
 	"github.com/aescanero/dago-libs/pkg/domain/graph"
 )
 
+// Validation error codes for structural graph problems and invalid inputs
+const (
+	ValidationCodeMissingEntryNode = "MISSING_ENTRY_NODE"
+	ValidationCodeInvalidLoop      = "INVALID_LOOP"
+	ValidationCodeInvalidCondition = "INVALID_CONDITION"
+	ValidationCodeInvalidFailure   = "INVALID_ERROR_HANDLING"
+	ValidationCodeInvalidMap       = "INVALID_MAP"
+	ValidationCodeCycle            = "CYCLE_DETECTED"
+	ValidationCodeUnreachable      = "UNREACHABLE_NODES"
+	ValidationCodeDeadEnd          = "DEAD_END_NODES"
+	ValidationCodeInvalidSchema    = "INVALID_SCHEMA"
+	ValidationCodeInputs           = "VALIDATION_ERROR"
+)
+
+// ValidationError describes a structural problem in a graph. Details carries
+// the offending path or nodes for API clients.
+type ValidationError struct {
+	Code    string
+	Message string
+	Details map[string]interface{}
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
 // Validator validates graph structures
 type Validator struct{}
 
@@ -34,6 +63,14 @@ func (v *Validator) Validate(g *domain.Graph) error {
 		return fmt.Errorf("graph must have at least one node")
 	}
 
+	if _, err := parseReducers(g); err != nil {
+		return err
+	}
+
+	if err := validateSchemas(g); err != nil {
+		return err
+	}
+
 	// Validate nodes
 	nodeIDs := make(map[string]bool)
 	for nodeID, node := range g.Nodes {
@@ -49,13 +86,22 @@ func (v *Validator) Validate(g *domain.Graph) error {
 	}
 
 	// Validate entry node exists
-	if g.EntryNode != "" {
-		if _, exists := g.Nodes[g.EntryNode]; !exists {
-			return fmt.Errorf("entry node %s not found in graph", g.EntryNode)
+	if g.EntryNode == "" {
+		return &ValidationError{
+			Code:    ValidationCodeMissingEntryNode,
+			Message: "graph entry node is required",
+		}
+	}
+	if _, exists := g.Nodes[g.EntryNode]; !exists {
+		return &ValidationError{
+			Code:    ValidationCodeMissingEntryNode,
+			Message: fmt.Sprintf("entry node %s not found in graph", g.EntryNode),
+			Details: map[string]interface{}{"entry_node": g.EntryNode},
 		}
 	}
 
 	// Validate edges
+	defaultEdges := make(map[string]int)
 	for _, edge := range g.Edges {
 		if _, exists := g.Nodes[edge.From]; !exists {
 			return fmt.Errorf("edge references non-existent source node: %s", edge.From)
@@ -63,6 +109,96 @@ func (v *Validator) Validate(g *domain.Graph) error {
 		if _, exists := g.Nodes[edge.To]; !exists {
 			return fmt.Errorf("edge references non-existent target node: %s", edge.To)
 		}
+		if _, _, err := parseLoopSpec(edge); err != nil {
+			return &ValidationError{
+				Code:    ValidationCodeInvalidLoop,
+				Message: fmt.Sprintf("invalid loop edge %s -> %s: %v", edge.From, edge.To, err),
+				Details: map[string]interface{}{"from": edge.From, "to": edge.To},
+			}
+		}
+		if err := validateEdgeCondition(edge); err != nil {
+			return &ValidationError{
+				Code:    ValidationCodeInvalidCondition,
+				Message: fmt.Sprintf("invalid condition on edge %s -> %s: %v", edge.From, edge.To, err),
+				Details: map[string]interface{}{"from": edge.From, "to": edge.To, "condition": edge.Condition},
+			}
+		}
+
+		if err := validateErrorEdge(edge); err != nil {
+			return &ValidationError{
+				Code:    ValidationCodeInvalidFailure,
+				Message: fmt.Sprintf("invalid error edge %s -> %s: %v", edge.From, edge.To, err),
+				Details: map[string]interface{}{"from": edge.From, "to": edge.To},
+			}
+		}
+
+		// A node falls back to at most one default edge
+		if isDefaultEdge(edge) {
+			defaultEdges[edge.From]++
+			if defaultEdges[edge.From] > 1 {
+				return &ValidationError{
+					Code:    ValidationCodeInvalidCondition,
+					Message: fmt.Sprintf("node %s has more than one default edge", edge.From),
+					Details: map[string]interface{}{"node_id": edge.From},
+				}
+			}
+		}
+	}
+
+	if err := validateFinally(g); err != nil {
+		return &ValidationError{
+			Code:    ValidationCodeInvalidFailure,
+			Message: err.Error(),
+			Details: map[string]interface{}{"node_id": finallyNode(g)},
+		}
+	}
+
+	if err := validateCompensations(g); err != nil {
+		return &ValidationError{
+			Code:    ValidationCodeInvalidFailure,
+			Message: err.Error(),
+		}
+	}
+
+	if err := validateMaps(g); err != nil {
+		return &ValidationError{
+			Code:    ValidationCodeInvalidMap,
+			Message: err.Error(),
+		}
+	}
+
+	return v.validateTopology(g)
+}
+
+// validateTopology checks the shape of the graph: every cycle must be a
+// declared loop, every node must be reachable from the entry node, and
+// every node must be able to reach an end node. The finally and compensation
+// nodes and the nodes run by map nodes stand outside the edges and are exempt.
+func (v *Validator) validateTopology(g *domain.Graph) error {
+	if cycle := findCycle(g, buildAdjacency(g, false)); cycle != nil {
+		return &ValidationError{
+			Code:    ValidationCodeCycle,
+			Message: fmt.Sprintf("graph contains a cycle that is not a declared loop: %s", strings.Join(cycle, " -> ")),
+			Details: map[string]interface{}{"path": cycle},
+		}
+	}
+
+	adj := buildAdjacency(g, true)
+	standalone := standaloneNodes(g)
+	if unreachable := withoutNodes(unreachableNodes(g, adj), standalone); len(unreachable) > 0 {
+		return &ValidationError{
+			Code:    ValidationCodeUnreachable,
+			Message: fmt.Sprintf("nodes unreachable from entry node %s: %s", g.EntryNode, strings.Join(unreachable, ", ")),
+			Details: map[string]interface{}{"nodes": unreachable},
+		}
+	}
+
+	if deadEnds := withoutNodes(deadEndNodes(g, adj), standalone); len(deadEnds) > 0 {
+		return &ValidationError{
+			Code:    ValidationCodeDeadEnd,
+			Message: fmt.Sprintf("nodes that never reach an end node: %s", strings.Join(deadEnds, ", ")),
+			Details: map[string]interface{}{"nodes": deadEnds},
+		}
 	}
 
 	return nil
@@ -83,5 +219,93 @@ func (v *Validator) validateNode(nodeID string, node graph.Node) error {
 		return err
 	}
 
+	// Validate orchestration settings
+	cfg := nodeConfig(node)
+	if _, err := parseJoinSpec(cfg); err != nil {
+		return err
+	}
+
+	if _, err := parseRetryPolicy(cfg); err != nil {
+		return err
+	}
+
+	if _, err := nodeOutputSchema(node); err != nil {
+		return err
+	}
+
+	if _, err := parseInvalidChoicePolicy(cfg); err != nil {
+		return err
+	}
+
+	if _, err := parseErrorPolicy(cfg); err != nil {
+		return err
+	}
+
+	if err := validateMappings(node); err != nil {
+		return err
+	}
+
+	switch node.GetType() {
+	case NodeTypeSubgraph:
+		if err := v.validateSubgraph(node); err != nil {
+			return err
+		}
+	case NodeTypeMap:
+		if _, err := parseMapSpec(cfg); err != nil {
+			return err
+		}
+	case NodeTypeHuman:
+		if _, err := parseDefaultAction(cfg); err != nil {
+			return err
+		}
+	case NodeTypeDelay:
+		if _, err := parseDelay(cfg, time.Now()); err != nil {
+			return err
+		}
+	case NodeTypeAggregate:
+		if _, err := parseAggregateSpec(cfg); err != nil {
+			return err
+		}
+	case NodeTypeTransform:
+		if _, err := parseTransformSpec(cfg); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := cfg[ConfigTimeout]; ok {
+		if timeout, ok := configDuration(cfg, ConfigTimeout); !ok || timeout <= 0 {
+			return fmt.Errorf("%s must be a positive duration", ConfigTimeout)
+		}
+	}
+
 	return nil
 }
+
+// standaloneNodes returns the nodes that run outside the graph's edges: the
+// finally node, the compensation nodes and the nodes run by map nodes
+func standaloneNodes(g *domain.Graph) map[string]bool {
+	standalone := make(map[string]bool)
+	if finallyID := finallyNode(g); finallyID != "" {
+		standalone[finallyID] = true
+	}
+	for nodeID := range g.Nodes {
+		if compensationID := compensationNode(g, nodeID); compensationID != "" {
+			standalone[compensationID] = true
+		}
+		if g.Nodes[nodeID].GetType() == NodeTypeMap {
+			standalone[configString(nodeConfig(g.Nodes[nodeID]), ConfigMapNode)] = true
+		}
+	}
+	return standalone
+}
+
+// withoutNodes removes the given node IDs from a list of node IDs
+func withoutNodes(nodeIDs []string, exclude map[string]bool) []string {
+	filtered := nodeIDs[:0]
+	for _, id := range nodeIDs {
+		if !exclude[id] {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,85 @@
+package orchestrator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+func TestValidateTopology(t *testing.T) {
+	loopEdge := func(from, to string) *graph.Edge {
+		return &graph.Edge{From: from, To: to, Metadata: map[string]interface{}{"max_iterations": 3}}
+	}
+	linear := []graph.Node{startNode("start"), executorNode("a", nil), executorNode("b", nil), endNode("end")}
+
+	tests := []struct {
+		name        string
+		graph       *domain.Graph
+		wantCode    string
+		wantDetails map[string]interface{}
+	}{
+		{
+			name:  "valid graph",
+			graph: newGraph("start", linear, edge("start", "a"), edge("a", "b"), edge("b", "end")),
+		},
+		{
+			name:  "declared loop",
+			graph: newGraph("start", linear, edge("start", "a"), edge("a", "b"), loopEdge("b", "a"), edge("b", "end")),
+		},
+		{
+			name:     "missing entry node",
+			graph:    newGraph("", linear, edge("start", "a"), edge("a", "b"), edge("b", "end")),
+			wantCode: ValidationCodeMissingEntryNode,
+		},
+		{
+			name:        "unknown entry node",
+			graph:       newGraph("nope", linear, edge("start", "a"), edge("a", "b"), edge("b", "end")),
+			wantCode:    ValidationCodeMissingEntryNode,
+			wantDetails: map[string]interface{}{"entry_node": "nope"},
+		},
+		{
+			name:        "undeclared cycle",
+			graph:       newGraph("start", linear, edge("start", "a"), edge("a", "b"), edge("b", "a"), edge("b", "end")),
+			wantCode:    ValidationCodeCycle,
+			wantDetails: map[string]interface{}{"path": []string{"a", "b", "a"}},
+		},
+		{
+			name:        "unreachable node",
+			graph:       newGraph("start", linear, edge("start", "a"), edge("a", "end"), edge("b", "end")),
+			wantCode:    ValidationCodeUnreachable,
+			wantDetails: map[string]interface{}{"nodes": []string{"b"}},
+		},
+		{
+			name:        "dead end",
+			graph:       newGraph("start", linear, edge("start", "a"), edge("a", "b"), edge("a", "end")),
+			wantCode:    ValidationCodeDeadEnd,
+			wantDetails: map[string]interface{}{"nodes": []string{"b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewValidator().Validate(tt.graph)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() error = %v, want a ValidationError", err)
+			}
+			if validationErr.Code != tt.wantCode {
+				t.Errorf("code = %s, want %s", validationErr.Code, tt.wantCode)
+			}
+			if tt.wantDetails != nil && !reflect.DeepEqual(validationErr.Details, tt.wantDetails) {
+				t.Errorf("details = %v, want %v", validationErr.Details, tt.wantDetails)
+			}
+		})
+	}
+}
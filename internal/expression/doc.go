@@ -0,0 +1,29 @@
+// Package expression implements the small expression language used in graph
+// definitions, for example edge conditions and loop exit conditions.
+//
+// Expressions are side-effect free and evaluated against an environment of
+// named values (node outputs, inputs and so on):
+//
+//	output.score > 0.8 && !output.needs_review
+//	len(nodes.search.output.results) == 0 || iteration >= 3
+//	"approved" in output.labels ? "ship" : "revise"
+//
+// Supported syntax:
+//   - Literals: numbers, 'single' or "double" quoted strings, true, false, null, [lists]
+//   - Paths: name.field, name["key"], list[0]; missing fields evaluate to null.
+//     Fields named like the word operators are read as name.in or name["in"].
+//   - Operators: || && ! (or the words or, and, not), comparisons
+//     (== != < <= > >=), in, arithmetic (+ - * / %) and a ? b : c
+//   - Built-in functions: len, lower, upper, trim, contains, startsWith,
+//     endsWith, split, join, keys, values, string, number, bool, default,
+//     min, max, abs, round, jsonpath
+//
+// jsonpath(value, "$.items[*].id") selects values with a JSONPath: the root
+// $, .field, ['field'], [index], wildcards and recursive descent (..). Paths
+// with a wildcard or recursive descent select a list of all matches.
+//
+// Expressions are compiled once with Compile, which rejects syntax errors and
+// unknown functions, and can then be evaluated any number of times. Templates
+// compiled with CompileTemplate embed expressions in text as
+// {{ expression }} placeholders.
+package expression
@@ -0,0 +1,464 @@
+package expression
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Program is a compiled expression
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile parses an expression
+func Compile(source string) (*Program, error) {
+	root, err := parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", source, err)
+	}
+	return &Program{source: source, root: root}, nil
+}
+
+// String returns the expression source
+func (p *Program) String() string {
+	return p.source
+}
+
+// Eval evaluates the expression against an environment
+func (p *Program) Eval(env map[string]interface{}) (interface{}, error) {
+	value, err := eval(p.root, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %q: %w", p.source, err)
+	}
+	return value, nil
+}
+
+// EvalBool evaluates the expression and reports whether the result is truthy
+func (p *Program) EvalBool(env map[string]interface{}) (bool, error) {
+	value, err := p.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	return Truthy(value), nil
+}
+
+// Truthy reports whether a value counts as true: false, null, zero, and
+// empty strings, lists and objects are false
+func Truthy(value interface{}) bool {
+	switch v := normalize(value).(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// eval evaluates a syntax tree node
+func eval(n node, env map[string]interface{}) (interface{}, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value, nil
+
+	case *identNode:
+		return normalize(env[n.name]), nil
+
+	case *listNode:
+		list := make([]interface{}, 0, len(n.elems))
+		for _, elem := range n.elems {
+			value, err := eval(elem, env)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+
+	case *memberNode:
+		object, err := eval(n.object, env)
+		if err != nil {
+			return nil, err
+		}
+		key, err := eval(n.key, env)
+		if err != nil {
+			return nil, err
+		}
+		return member(object, key)
+
+	case *callNode:
+		args := make([]interface{}, 0, len(n.args))
+		for _, arg := range n.args {
+			value, err := eval(arg, env)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, value)
+		}
+		value, err := n.fn.call(args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", n.name, err)
+		}
+		return normalize(value), nil
+
+	case *unaryNode:
+		operand, err := eval(n.operand, env)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "!" {
+			return !Truthy(operand), nil
+		}
+		num, ok := operand.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate %s", typeName(operand))
+		}
+		return -num, nil
+
+	case *binaryNode:
+		return evalBinary(n, env)
+
+	case *conditionalNode:
+		cond, err := eval(n.cond, env)
+		if err != nil {
+			return nil, err
+		}
+		if Truthy(cond) {
+			return eval(n.then, env)
+		}
+		return eval(n.otherwise, env)
+	}
+
+	return nil, fmt.Errorf("unknown expression node %T", n)
+}
+
+// evalBinary evaluates an infix operator
+func evalBinary(n *binaryNode, env map[string]interface{}) (interface{}, error) {
+	left, err := eval(n.left, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators short-circuit
+	switch n.op {
+	case "&&":
+		if !Truthy(left) {
+			return false, nil
+		}
+		right, err := eval(n.right, env)
+		if err != nil {
+			return nil, err
+		}
+		return Truthy(right), nil
+	case "||":
+		if Truthy(left) {
+			return true, nil
+		}
+		right, err := eval(n.right, env)
+		if err != nil {
+			return nil, err
+		}
+		return Truthy(right), nil
+	}
+
+	right, err := eval(n.right, env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		return contains(right, left)
+	case "<", "<=", ">", ">=":
+		return compare(n.op, left, right)
+	case "+":
+		switch l := left.(type) {
+		case string:
+			if r, ok := right.(string); ok {
+				return l + r, nil
+			}
+		case []interface{}:
+			if r, ok := right.([]interface{}); ok {
+				return append(append([]interface{}{}, l...), r...), nil
+			}
+		}
+	}
+
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s not supported for %s and %s", n.op, typeName(left), typeName(right))
+	}
+
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(l, r), nil
+	}
+
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+// member reads a field of an object or an element of a list. Missing
+// fields and out-of-range indexes yield null.
+func member(object, key interface{}) (interface{}, error) {
+	switch obj := object.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("object key must be a string, got %s", typeName(key))
+		}
+		return normalize(obj[name]), nil
+	case []interface{}:
+		index, ok := key.(float64)
+		if !ok || index != math.Trunc(index) {
+			return nil, fmt.Errorf("list index must be an integer, got %s", typeName(key))
+		}
+		i := int(index)
+		if i < 0 {
+			i += len(obj)
+		}
+		if i < 0 || i >= len(obj) {
+			return nil, nil
+		}
+		return normalize(obj[i]), nil
+	case string:
+		index, ok := key.(float64)
+		if !ok || index != math.Trunc(index) {
+			return nil, fmt.Errorf("string index must be an integer, got %s", typeName(key))
+		}
+		runes := []rune(obj)
+		i := int(index)
+		if i < 0 {
+			i += len(runes)
+		}
+		if i < 0 || i >= len(runes) {
+			return nil, nil
+		}
+		return string(runes[i]), nil
+	}
+	return nil, fmt.Errorf("cannot access %v of %s", key, typeName(object))
+}
+
+// equal compares two values structurally
+func equal(left, right interface{}) bool {
+	return reflect.DeepEqual(normalizeDeep(left), normalizeDeep(right))
+}
+
+// compare orders two numbers or two strings
+func compare(op string, left, right interface{}) (bool, error) {
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %s with %s", typeName(left), typeName(right))
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %s with %s", typeName(left), typeName(right))
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return false, fmt.Errorf("cannot compare %s with %s", typeName(left), typeName(right))
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+// contains reports whether needle is an element of a list, a key of an
+// object or a substring of a string
+func contains(haystack, needle interface{}) (bool, error) {
+	switch h := haystack.(type) {
+	case nil:
+		return false, nil
+	case []interface{}:
+		for _, elem := range h {
+			if equal(elem, needle) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		key, ok := needle.(string)
+		if !ok {
+			return false, nil
+		}
+		_, exists := h[key]
+		return exists, nil
+	case string:
+		s, ok := needle.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot search %s in a string", typeName(needle))
+		}
+		return strings.Contains(h, s), nil
+	}
+	return false, fmt.Errorf("cannot search in %s", typeName(haystack))
+}
+
+// normalize converts a value to the types the evaluator works with:
+// float64 numbers, strings, bools, nil, []interface{} and
+// map[string]interface{}. Containers are converted lazily, one level at a time.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, float64, string, []interface{}, map[string]interface{}:
+		return v
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		return list
+	case map[string]string:
+		obj := make(map[string]interface{}, len(v))
+		for k, s := range v {
+			obj[k] = s
+		}
+		return obj
+	}
+
+	// Other slices, maps and structs go through their JSON form
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+	default:
+		return fmt.Sprint(value)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return fmt.Sprint(value)
+	}
+	return out
+}
+
+// normalizeDeep normalizes a value and everything it contains
+func normalizeDeep(value interface{}) interface{} {
+	switch v := normalize(value).(type) {
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, elem := range v {
+			list[i] = normalizeDeep(elem)
+		}
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			obj[k] = normalizeDeep(elem)
+		}
+		return obj
+	default:
+		return v
+	}
+}
+
+// typeName names the type of a value in error messages
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// toString formats a value for string conversion and concatenation
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,240 @@
+package expression
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testEnv is the environment the evaluation tests run against
+func testEnv() map[string]interface{} {
+	return map[string]interface{}{
+		"output": map[string]interface{}{
+			"score":  0.9,
+			"labels": []interface{}{"urgent", "billing"},
+			"count":  3,
+			"name":   "Ada",
+			"empty":  "",
+			"nested": map[string]interface{}{"deep": map[string]interface{}{"value": 42.0}},
+		},
+		"state": map[string]interface{}{
+			"in":  "inbox",
+			"and": true,
+			"or":  []interface{}{1.0, 2.0},
+			"not": map[string]interface{}{"x": 1.0},
+		},
+		"iteration": 2,
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		// Precedence and associativity
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"10 - 4 - 3", 3.0},
+		{"8 / 4 / 2", 1.0},
+		{"7 % 4 + 1", 4.0},
+		{"-2 * -3", 6.0},
+		{"1 + 2 == 3", true},
+		{"1 < 2 == 2 < 3", true},
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+		{"!false && false", false},
+		{"!(false && false)", true},
+		{"not true or true", true},
+		{"true and not false", true},
+		{"output.score > 0.8 ? 'high' : 'low'", "high"},
+		{"false ? 1 : true ? 2 : 3", 2.0},
+		{"1 + 1 in [2, 3]", true},
+
+		// Paths
+		{"output.name", "Ada"},
+		{"output['name']", "Ada"},
+		{"output.labels[0]", "urgent"},
+		{"output.labels[-1]", "billing"},
+		{"output.nested.deep.value", 42.0},
+		{"output.count", 3.0},
+		{"output.name[0]", "A"},
+
+		// Fields named like the word operators
+		{"state.in", "inbox"},
+		{"state['in']", "inbox"},
+		{`state["and"]`, true},
+		{"state.or[1]", 2.0},
+		{"state.not.x", 1.0},
+		{"state.in == 'inbox' and state.and", true},
+		{"'inbox' in state.in", true},
+
+		// Null and missing values
+		{"output.missing", nil},
+		{"output.missing.deeper", nil},
+		{"missing", nil},
+		{"output.labels[5]", nil},
+		{"output.missing == null", true},
+		{"output.missing != null", false},
+		{"!output.missing", true},
+		{"!output.empty", true},
+		{"len(output.missing)", 0.0},
+		{"default(output.missing, 'fallback')", "fallback"},
+		{"'x' in output.missing", false},
+		{"output.missing || 'fallback'", true},
+
+		// Operators on other types
+		{"'a' + 'b'", "ab"},
+		{"[1] + [2]", []interface{}{1.0, 2.0}},
+		{"'urgent' in output.labels", true},
+		{"'name' in output", true},
+		{"'bill' in 'billing'", true},
+		{"'abc' < 'abd'", true},
+		{"[1, [2]] == [1, [2]]", true},
+		{"iteration >= 2", true},
+
+		// Functions
+		{"len(output.labels)", 2.0},
+		{"upper(output.name)", "ADA"},
+		{"join(output.labels, ',')", "urgent,billing"},
+		{"max(1, 5, 3)", 5.0},
+		{"number('4') + 1", 5.0},
+		{"string(1.5)", "1.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, err := program.Eval(testEnv())
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Eval() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"output.name - 1", "operator - not supported for string and number"},
+		{"output.name * 2", "operator * not supported"},
+		{"1 + 'a'", "operator + not supported for number and string"},
+		{"1 + null", "operator + not supported for number and null"},
+		{"-output.name", "cannot negate string"},
+		{"output.score > 'a'", "cannot compare number with string"},
+		{"output.missing < 1", "cannot compare null with number"},
+		{"1 / 0", "division by zero"},
+		{"5 % 0", "division by zero"},
+		{"output[1]", "object key must be a string"},
+		{"output.labels['a']", "list index must be an integer"},
+		{"output.labels[0.5]", "list index must be an integer"},
+		{"output.score.x", "cannot access x of number"},
+		{"1 in 'abc'", "cannot search number in a string"},
+		{"'a' in 1", "cannot search in number"},
+		{"len(1)", "cannot take the length of number"},
+		{"number('abc')", "number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			_, err = program.Eval(testEnv())
+			if err == nil {
+				t.Fatal("Eval() succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Eval() error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "unexpected end of expression"},
+		{"1 +", "unexpected end of expression"},
+		{"(1 + 2", `expected ")"`},
+		{"[1, 2", `expected ","`},
+		{"a ? b", `expected ":"`},
+		{"1 2", `unexpected "2"`},
+		{"output.", "expected field name"},
+		{"output.1", "expected field name"},
+		{"'open", "unterminated string"},
+		{"a # b", "unexpected character"},
+		{"nope(1)", `unknown function "nope"`},
+		{"len(1, 2)", "wrong number of arguments for len"},
+		{"output.name(1)", "only built-in functions can be called"},
+		{"1..2", "invalid number"},
+		{strings.Repeat("(", maxDepth+1) + "1" + strings.Repeat(")", maxDepth+1), "nested too deeply"},
+		{strings.Repeat("!", maxDepth+1) + "true", "nested too deeply"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+			if err == nil {
+				t.Fatal("Compile() succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile() error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	// The right operand would fail if it were evaluated
+	for _, expr := range []string{
+		"false && 1 / 0",
+		"true || 1 / 0",
+		"true ? 1 : 1 / 0",
+		"false ? 1 / 0 : 1",
+	} {
+		program, err := Compile(expr)
+		if err != nil {
+			t.Fatalf("Compile(%q) error = %v", expr, err)
+		}
+		if _, err := program.Eval(nil); err != nil {
+			t.Errorf("Eval(%q) error = %v", expr, err)
+		}
+	}
+}
+
+func TestTruthy(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  bool
+	}{
+		{nil, false},
+		{false, false},
+		{0, false},
+		{0.0, false},
+		{"", false},
+		{[]interface{}{}, false},
+		{map[string]interface{}{}, false},
+		{true, true},
+		{-1, true},
+		{"0", true},
+		{[]interface{}{nil}, true},
+		{map[string]interface{}{"a": nil}, true},
+	}
+	for _, tt := range tests {
+		if got := Truthy(tt.value); got != tt.want {
+			t.Errorf("Truthy(%#v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
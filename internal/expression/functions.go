@@ -0,0 +1,213 @@
+package expression
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// function is a built-in function callable from expressions
+type function struct {
+	minArgs int
+	maxArgs int // -1 for variadic
+	call    func(args []interface{}) (interface{}, error)
+}
+
+// functions lists the built-in functions by name
+var functions = map[string]function{
+	"len":        {1, 1, fnLen},
+	"lower":      {1, 1, stringFunc(strings.ToLower)},
+	"upper":      {1, 1, stringFunc(strings.ToUpper)},
+	"trim":       {1, 1, stringFunc(strings.TrimSpace)},
+	"contains":   {2, 2, fnContains},
+	"startsWith": {2, 2, stringPredicate(strings.HasPrefix)},
+	"endsWith":   {2, 2, stringPredicate(strings.HasSuffix)},
+	"split":      {2, 2, fnSplit},
+	"join":       {2, 2, fnJoin},
+	"keys":       {1, 1, fnKeys},
+	"values":     {1, 1, fnValues},
+	"string":     {1, 1, func(args []interface{}) (interface{}, error) { return toString(args[0]), nil }},
+	"number":     {1, 1, fnNumber},
+	"bool":       {1, 1, func(args []interface{}) (interface{}, error) { return Truthy(args[0]), nil }},
+	"default":    {2, 2, fnDefault},
+	"min":        {1, -1, numberFold(math.Min)},
+	"max":        {1, -1, numberFold(math.Max)},
+	"abs":        {1, 1, numberFunc(math.Abs)},
+	"round":      {1, 1, numberFunc(math.Round)},
+	"jsonpath":   {2, 2, fnJSONPath},
+}
+
+// fnLen returns the length of a string, list or object
+func fnLen(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case nil:
+		return 0.0, nil
+	case string:
+		return float64(utf8.RuneCountInString(v)), nil
+	case []interface{}:
+		return float64(len(v)), nil
+	case map[string]interface{}:
+		return float64(len(v)), nil
+	}
+	return nil, fmt.Errorf("cannot take the length of %s", typeName(args[0]))
+}
+
+// fnContains reports whether a list, object or string contains a value
+func fnContains(args []interface{}) (interface{}, error) {
+	return contains(args[0], args[1])
+}
+
+// fnSplit splits a string by a separator
+func fnSplit(args []interface{}) (interface{}, error) {
+	s, ok1 := args[0].(string)
+	sep, ok2 := args[1].(string)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("expects two strings")
+	}
+	parts := strings.Split(s, sep)
+	list := make([]interface{}, len(parts))
+	for i, part := range parts {
+		list[i] = part
+	}
+	return list, nil
+}
+
+// fnJoin joins the elements of a list with a separator
+func fnJoin(args []interface{}) (interface{}, error) {
+	list, ok1 := args[0].([]interface{})
+	sep, ok2 := args[1].(string)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("expects a list and a string")
+	}
+	parts := make([]string, len(list))
+	for i, elem := range list {
+		parts[i] = toString(normalize(elem))
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// fnKeys returns the sorted keys of an object
+func fnKeys(args []interface{}) (interface{}, error) {
+	obj, ok := args[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expects an object, got %s", typeName(args[0]))
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := make([]interface{}, len(keys))
+	for i, key := range keys {
+		list[i] = key
+	}
+	return list, nil
+}
+
+// fnValues returns the values of an object ordered by key
+func fnValues(args []interface{}) (interface{}, error) {
+	keys, err := fnKeys(args)
+	if err != nil {
+		return nil, err
+	}
+	obj := args[0].(map[string]interface{})
+	list := make([]interface{}, 0, len(obj))
+	for _, key := range keys.([]interface{}) {
+		list = append(list, obj[key.(string)])
+	}
+	return list, nil
+}
+
+// fnNumber converts a value to a number
+func fnNumber(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %q to a number", v)
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("cannot convert %s to a number", typeName(args[0]))
+}
+
+// fnDefault returns its first argument unless it is null
+func fnDefault(args []interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+// stringFunc adapts a string transformation
+func stringFunc(fn func(string) string) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("expects a string, got %s", typeName(args[0]))
+		}
+		return fn(s), nil
+	}
+}
+
+// stringPredicate adapts a test on two strings
+func stringPredicate(fn func(string, string) bool) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		s, ok1 := args[0].(string)
+		t, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("expects two strings")
+		}
+		return fn(s, t), nil
+	}
+}
+
+// numberFunc adapts a numeric function of one argument
+func numberFunc(fn func(float64) float64) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		f, ok := args[0].(float64)
+		if !ok {
+			return nil, fmt.Errorf("expects a number, got %s", typeName(args[0]))
+		}
+		return fn(f), nil
+	}
+}
+
+// numberFold reduces numbers, given as arguments or as a single list
+func numberFold(fn func(float64, float64) float64) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if list, ok := args[0].([]interface{}); ok && len(args) == 1 {
+			args = make([]interface{}, len(list))
+			for i, elem := range list {
+				args[i] = normalize(elem)
+			}
+		}
+		if len(args) == 0 {
+			return nil, nil
+		}
+
+		var result float64
+		for i, arg := range args {
+			f, ok := arg.(float64)
+			if !ok {
+				return nil, fmt.Errorf("expects numbers, got %s", typeName(arg))
+			}
+			if i == 0 {
+				result = f
+				continue
+			}
+			result = fn(result, f)
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,188 @@
+package expression
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathStep is one selector of a JSONPath
+type pathStep struct {
+	name      string // object field, when not a wildcard or index
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool // applies to the value and all of its descendants
+}
+
+// fnJSONPath selects values with a JSONPath, such as $.items[*].id
+func fnJSONPath(args []interface{}) (interface{}, error) {
+	path, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("jsonpath expects a path string, got %s", typeName(args[1]))
+	}
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %w", path, err)
+	}
+	return selectPath(args[0], steps), nil
+}
+
+// parsePath parses the supported JSONPath subset: the root $, .field,
+// ['field'], [index], wildcards (.* and [*]) and recursive descent (..)
+func parsePath(path string) ([]pathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("must start with $")
+	}
+
+	var steps []pathStep
+	for i := 1; i < len(path); {
+		var step pathStep
+		switch {
+		case strings.HasPrefix(path[i:], ".."):
+			step.recursive = true
+			i += 2
+			if i < len(path) && path[i] == '[' {
+				break
+			}
+			fallthrough
+		case path[i] == '.':
+			if !step.recursive {
+				i++
+			}
+			start := i
+			for i < len(path) && path[i] != '.' && path[i] != '[' {
+				i++
+			}
+			step.name = path[start:i]
+			if step.name == "" {
+				return nil, fmt.Errorf("empty field name at offset %d", start)
+			}
+			step.wildcard = step.name == "*"
+			steps = append(steps, step)
+			continue
+		case path[i] != '[':
+			return nil, fmt.Errorf("unexpected %q at offset %d", path[i], i)
+		}
+
+		// Bracket selector
+		end := strings.IndexByte(path[i:], ']')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed [ at offset %d", i)
+		}
+		selector := strings.TrimSpace(path[i+1 : i+end])
+		switch {
+		case selector == "*":
+			step.wildcard = true
+		case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+			step.name = selector[1 : len(selector)-1]
+		default:
+			index, err := strconv.Atoi(selector)
+			if err != nil {
+				return nil, fmt.Errorf("invalid selector [%s]", selector)
+			}
+			step.index, step.isIndex = index, true
+		}
+		steps = append(steps, step)
+		i += end + 1
+	}
+	return steps, nil
+}
+
+// selectPath applies a parsed path. Paths without wildcards or recursive
+// descent select a single value, null when missing; others select a list
+// of all matches.
+func selectPath(root interface{}, steps []pathStep) interface{} {
+	matches := []interface{}{normalize(root)}
+	single := true
+	for _, step := range steps {
+		if step.wildcard || step.recursive {
+			single = false
+		}
+
+		var next []interface{}
+		for _, match := range matches {
+			candidates := []interface{}{match}
+			if step.recursive {
+				candidates = descendants(match, candidates)
+			}
+			for _, candidate := range candidates {
+				next = append(next, applyStep(candidate, step)...)
+			}
+		}
+		matches = next
+	}
+
+	if single {
+		if len(matches) == 0 {
+			return nil
+		}
+		return matches[0]
+	}
+	if matches == nil {
+		matches = []interface{}{}
+	}
+	return matches
+}
+
+// applyStep returns the values one selector picks from a value
+func applyStep(value interface{}, step pathStep) []interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if step.wildcard {
+			return children(v)
+		}
+		if field, ok := v[step.name]; ok && !step.isIndex {
+			return []interface{}{normalize(field)}
+		}
+	case []interface{}:
+		if step.wildcard {
+			return children(v)
+		}
+		if step.isIndex {
+			i := step.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				return []interface{}{normalize(v[i])}
+			}
+		}
+	}
+	return nil
+}
+
+// children returns the elements of a list, or the fields of an object in
+// key order
+func children(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = normalize(elem)
+		}
+		return out
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		out := make([]interface{}, len(keys))
+		for i, key := range keys {
+			out[i] = normalize(v[key])
+		}
+		return out
+	}
+	return nil
+}
+
+// descendants appends every value nested in a value, depth first
+func descendants(value interface{}, out []interface{}) []interface{} {
+	for _, child := range children(value) {
+		out = append(out, child)
+		out = descendants(child, out)
+	}
+	return out
+}
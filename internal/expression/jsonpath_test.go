@@ -0,0 +1,111 @@
+package expression
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	doc := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"id": "a", "tags": []interface{}{"x"}},
+			map[string]interface{}{"id": "b", "tags": []interface{}{}},
+		},
+		"meta":       map[string]interface{}{"total": 2.0, "id": "m"},
+		"odd key":    "spaced",
+		"in":         "keyword",
+		"empty_list": []interface{}{},
+	}
+
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"$", doc},
+		{"$.meta.total", 2.0},
+		{"$['odd key']", "spaced"},
+		{`$["in"]`, "keyword"},
+		{"$.in", "keyword"},
+		{"$.items[0].id", "a"},
+		{"$.items[-1].id", "b"},
+		{"$.items[ 1 ].id", "b"},
+
+		// Missing values select null on single paths
+		{"$.missing", nil},
+		{"$.missing.deeper", nil},
+		{"$.items[5].id", nil},
+		{"$.meta[0]", nil},
+		{"$.items.id", nil},
+
+		// Wildcards and recursive descent select lists, empty when nothing matches
+		{"$.items[*].id", []interface{}{"a", "b"}},
+		{"$.items.*.id", []interface{}{"a", "b"}},
+		{"$.meta.*", []interface{}{"m", 2.0}},
+		{"$.empty_list[*]", []interface{}{}},
+		{"$.missing[*]", []interface{}{}},
+		{"$..id", []interface{}{"a", "b", "m"}},
+		{"$..tags[0]", []interface{}{"x"}},
+		{"$..nothing", []interface{}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := fnJSONPath([]interface{}{doc, tt.path})
+			if err != nil {
+				t.Fatalf("jsonpath() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jsonpath() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	tests := []struct {
+		path interface{}
+		want string
+	}{
+		{1.0, "expects a path string"},
+		{"items", "must start with $"},
+		{"$.", "empty field name"},
+		{"$..", "empty field name"},
+		{"$.items[0", "unclosed ["},
+		{"$.items[a]", "invalid selector [a]"},
+		{"$.items['a]", "invalid selector"},
+		{"$x", "unexpected"},
+	}
+
+	for _, tt := range tests {
+		_, err := fnJSONPath([]interface{}{map[string]interface{}{}, tt.path})
+		if err == nil {
+			t.Errorf("jsonpath(%v) succeeded, want an error", tt.path)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("jsonpath(%v) error = %q, want it to contain %q", tt.path, err, tt.want)
+		}
+	}
+}
+
+func TestJSONPathInExpression(t *testing.T) {
+	program, err := Compile(`len(jsonpath(output, "$.items[*].id")) == 2`)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	ok, err := program.EvalBool(map[string]interface{}{
+		"output": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"id": 1},
+				map[string]interface{}{"id": 2},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("EvalBool() error = %v", err)
+	}
+	if !ok {
+		t.Error("EvalBool() = false, want true")
+	}
+}
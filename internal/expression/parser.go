@@ -0,0 +1,322 @@
+package expression
+
+import (
+	"fmt"
+)
+
+// node is an element of the expression syntax tree
+type node interface{}
+
+type (
+	// literalNode is a constant value
+	literalNode struct{ value interface{} }
+
+	// identNode looks up a name in the environment
+	identNode struct{ name string }
+
+	// listNode builds a list from its elements
+	listNode struct{ elems []node }
+
+	// memberNode reads a field or index of an object
+	memberNode struct {
+		object node
+		key    node
+	}
+
+	// callNode invokes a built-in function
+	callNode struct {
+		name string
+		fn   function
+		args []node
+	}
+
+	// unaryNode applies a prefix operator
+	unaryNode struct {
+		op      string
+		operand node
+	}
+
+	// binaryNode applies an infix operator
+	binaryNode struct {
+		op          string
+		left, right node
+	}
+
+	// conditionalNode evaluates cond ? then : otherwise
+	conditionalNode struct {
+		cond, then, otherwise node
+	}
+)
+
+// maxDepth bounds the nesting of expressions
+const maxDepth = 64
+
+// parser is a recursive descent parser over a token stream
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+// parse builds the syntax tree of an expression
+func parse(src string) (node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	return root, nil
+}
+
+// peek returns the current token
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next consumes the current token
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the current token if it is one of the given operators
+func (p *parser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokenOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// expect consumes the given operator or fails
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		tok := p.peek()
+		if tok.kind == tokenEOF {
+			return fmt.Errorf("expected %q at end of expression", op)
+		}
+		return fmt.Errorf("expected %q at position %d, found %q", op, tok.pos, tok.text)
+	}
+	return nil
+}
+
+// parseConditional parses cond ? then : otherwise
+func (p *parser) parseConditional() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, fmt.Errorf("expression nested too deeply")
+	}
+
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+
+	then, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	return &conditionalNode{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+// binaryLevels lists the infix operators from lowest to highest precedence
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">=", "in"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// parseBinary parses left-associative infix operators of a precedence level
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(binaryLevels[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+// parseUnary parses prefix operators
+func (p *parser) parseUnary() (node, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxDepth {
+			return nil, fmt.Errorf("expression nested too deeply")
+		}
+
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix parses member access, indexing and calls
+func (p *parser) parsePostfix() (node, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.peek().kind == tokenOperator && p.peek().text == ".":
+			p.next()
+			tok := p.next()
+			name := tok.text
+			switch {
+			case tok.kind == tokenIdent:
+			case tok.kind == tokenOperator && tok.value != "":
+				// Fields may be named like the word operators, as in state.in
+				name = tok.value
+			default:
+				return nil, fmt.Errorf("expected field name at position %d", tok.pos)
+			}
+			expr = &memberNode{object: expr, key: &literalNode{value: name}}
+
+		case p.peek().kind == tokenOperator && p.peek().text == "[":
+			p.next()
+			key, err := p.parseConditional()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			expr = &memberNode{object: expr, key: key}
+
+		case p.peek().kind == tokenOperator && p.peek().text == "(":
+			ident, ok := expr.(*identNode)
+			if !ok {
+				return nil, fmt.Errorf("only built-in functions can be called (position %d)", p.peek().pos)
+			}
+			p.next()
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			fn, ok := functions[ident.name]
+			if !ok {
+				return nil, fmt.Errorf("unknown function %q", ident.name)
+			}
+			if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+				return nil, fmt.Errorf("wrong number of arguments for %s: %d", ident.name, len(args))
+			}
+			expr = &callNode{name: ident.name, fn: fn, args: args}
+
+		default:
+			return expr, nil
+		}
+	}
+}
+
+// parsePrimary parses literals, names, lists and parenthesized expressions
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		return &literalNode{value: tok.num}, nil
+	case tokenString:
+		return &literalNode{value: tok.value}, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		return &identNode{name: tok.text}, nil
+	case tokenOperator:
+		switch tok.text {
+		case "(":
+			expr, err := p.parseConditional()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return expr, nil
+		case "[":
+			elems, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{elems: elems}, nil
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// parseList parses comma separated expressions up to the closing operator
+func (p *parser) parseList(closing string) ([]node, error) {
+	var elems []node
+	if _, ok := p.accept(closing); ok {
+		return elems, nil
+	}
+	for {
+		elem, err := p.parseConditional()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+
+		if _, ok := p.accept(closing); ok {
+			return elems, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package expression
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Template is a compiled text template whose {{ expression }} placeholders
+// are replaced by the values of their expressions
+type Template struct {
+	source string
+	parts  []templatePart
+}
+
+// templatePart is literal text or a placeholder
+type templatePart struct {
+	text    string
+	program *Program
+}
+
+// CompileTemplate parses a template, compiling each of its placeholders
+func CompileTemplate(source string) (*Template, error) {
+	t := &Template{source: source}
+	rest := source
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			if rest != "" {
+				t.parts = append(t.parts, templatePart{text: rest})
+			}
+			return t, nil
+		}
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{text: rest[:start]})
+		}
+
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid template: unclosed {{ at offset %d", len(source)-len(rest)+start)
+		}
+		program, err := Compile(strings.TrimSpace(rest[start+2 : start+end]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		t.parts = append(t.parts, templatePart{program: program})
+		rest = rest[start+end+2:]
+	}
+}
+
+// String returns the template source
+func (t *Template) String() string {
+	return t.source
+}
+
+// Render evaluates the placeholders against an environment. Strings are
+// inserted as they are, null as nothing and lists and objects as JSON.
+func (t *Template) Render(env map[string]interface{}) (string, error) {
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.program == nil {
+			b.WriteString(part.text)
+			continue
+		}
+		value, err := part.program.Eval(env)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(toString(value))
+	}
+	return b.String(), nil
+}
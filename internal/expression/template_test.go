@@ -0,0 +1,70 @@
+package expression
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"plain text", "plain text"},
+		{"", ""},
+		{"Hello {{ output.name }}!", "Hello Ada!"},
+		{"{{output.name}}{{ output.count }}", "Ada3"},
+		{"{{ upper(output.name) }} has {{ len(output.labels) }} labels", "ADA has 2 labels"},
+		{"labels: {{ output.labels }}", `labels: ["urgent","billing"]`},
+		{"deep: {{ output.nested.deep }}", `deep: {"value":42}`},
+		{"[{{ output.missing }}]", "[]"},
+		{"{{ output.score > 0.8 ? 'high' : 'low' }} priority", "high priority"},
+		{"closing }} alone", "closing }} alone"},
+	}
+	for _, tt := range tests {
+		tmpl, err := CompileTemplate(tt.source)
+		if err != nil {
+			t.Errorf("CompileTemplate(%q) error = %v", tt.source, err)
+			continue
+		}
+		if tmpl.String() != tt.source {
+			t.Errorf("String() = %q, want the source %q", tmpl.String(), tt.source)
+		}
+		got, err := tmpl.Render(testEnv())
+		if err != nil {
+			t.Errorf("Render(%q) error = %v", tt.source, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestCompileTemplateErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		wantErr string
+	}{
+		{"Hello {{ output.name", "unclosed {{ at offset 6"},
+		{"{{ a }} and {{ b", "unclosed {{ at offset 12"},
+		{"{{ 1 + }}", "invalid template"},
+		{"{{ }}", "invalid template"},
+	}
+	for _, tt := range tests {
+		_, err := CompileTemplate(tt.source)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("CompileTemplate(%q) error = %v, want %q", tt.source, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRenderFailsWithItsExpression(t *testing.T) {
+	tmpl, err := CompileTemplate("{{ output.name - 1 }}")
+	if err != nil {
+		t.Fatalf("CompileTemplate() error = %v", err)
+	}
+	if _, err := tmpl.Render(testEnv()); err == nil {
+		t.Error("Render() of a failing expression succeeded")
+	}
+}
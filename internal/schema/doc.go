@@ -0,0 +1,8 @@
+// Package schema validates values against the JSON Schemas declared in
+// graph definitions, for example the schema of an execution's inputs.
+//
+// Schemas are self-contained: references to other documents are refused,
+// so a graph definition cannot make the orchestrator read files or fetch
+// URLs. Drafts 4 to 2020-12 are supported; a schema without $schema is read
+// as draft 2020-12.
+package schema
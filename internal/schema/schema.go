@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// resourceURL names the schema being compiled; it only appears in errors,
+// and is absolute so that it is not resolved against the working directory
+const resourceURL = "mem:///schema.json"
+
+// Schema is a compiled JSON Schema
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// FieldError is a value that failed validation. Path is the JSON Pointer of
+// the value within the document, "" for the document itself.
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Compile compiles a JSON Schema given as a decoded JSON value
+func Compile(raw interface{}) (*Schema, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("external schema references are not allowed: %s", url)
+	}
+	if err := compiler.AddResource(resourceURL, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	compiled, err := compiler.Compile(resourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &Schema{schema: compiled}, nil
+}
+
+// Validate checks a value against the schema and returns the values that
+// failed, ordered by path, or nil if it is valid
+func (s *Schema) Validate(value interface{}) ([]FieldError, error) {
+	// The validator only knows decoded JSON, so values go through their JSON form
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode value: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode value: %w", err)
+	}
+
+	err = s.schema.Validate(decoded)
+	if err == nil {
+		return nil, nil
+	}
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return nil, err
+	}
+
+	var failures []FieldError
+	collectFailures(validationErr, &failures)
+	sort.SliceStable(failures, func(i, j int) bool {
+		return failures[i].Path < failures[j].Path
+	})
+	return failures, nil
+}
+
+// collectFailures gathers the innermost errors, which name the offending
+// values; the errors around them only repeat which subschema failed
+func collectFailures(err *jsonschema.ValidationError, failures *[]FieldError) {
+	if len(err.Causes) == 0 {
+		*failures = append(*failures, FieldError{Path: err.InstanceLocation, Message: err.Message})
+		return
+	}
+	for _, cause := range err.Causes {
+		collectFailures(cause, failures)
+	}
+}
+
+// Describe summarizes validation failures in one line
+func Describe(failures []FieldError) string {
+	parts := make([]string, len(failures))
+	for i, failure := range failures {
+		path := failure.Path
+		if path == "" {
+			path = "/"
+		}
+		parts[i] = fmt.Sprintf("%s: %s", path, failure.Message)
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,81 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ticketSchema requires a title and allows a numeric priority
+func ticketSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"title"},
+		"properties": map[string]interface{}{
+			"title":    map[string]interface{}{"type": "string"},
+			"priority": map[string]interface{}{"type": "integer", "minimum": 1},
+			"tags":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, err := Compile(ticketSchema())
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		value     interface{}
+		wantPaths []string
+	}{
+		{"valid", map[string]interface{}{"title": "Refund", "priority": 2}, nil},
+		{"integral float", map[string]interface{}{"title": "Refund", "priority": 2.0}, nil},
+		{"missing field", map[string]interface{}{"priority": 2}, []string{""}},
+		{"wrong types", map[string]interface{}{"title": 7, "priority": "high"}, []string{"/priority", "/title"}},
+		{"below minimum", map[string]interface{}{"title": "Refund", "priority": 0}, []string{"/priority"}},
+		{"array item", map[string]interface{}{"title": "Refund", "tags": []interface{}{"ok", 3}}, []string{"/tags/1"}},
+		{"not an object", "Refund", []string{""}},
+	}
+	for _, tt := range tests {
+		failures, err := s.Validate(tt.value)
+		if err != nil {
+			t.Errorf("%s: Validate() error = %v", tt.name, err)
+			continue
+		}
+		var paths []string
+		for _, failure := range failures {
+			paths = append(paths, failure.Path)
+			if failure.Message == "" {
+				t.Errorf("%s: failure at %q has no message", tt.name, failure.Path)
+			}
+		}
+		if !reflect.DeepEqual(paths, tt.wantPaths) {
+			t.Errorf("%s: failed paths = %q, want %q", tt.name, paths, tt.wantPaths)
+		}
+	}
+}
+
+func TestCompileRejectsInvalidSchemas(t *testing.T) {
+	for _, raw := range []interface{}{
+		map[string]interface{}{"type": 5},
+		map[string]interface{}{"$ref": "https://example.com/schema.json"},
+		map[string]interface{}{"minimum": "one"},
+	} {
+		if _, err := Compile(raw); err == nil || !strings.HasPrefix(err.Error(), "invalid schema") {
+			t.Errorf("Compile(%v) error = %v, want an invalid schema error", raw, err)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	got := Describe([]FieldError{
+		{Path: "", Message: "missing properties: 'title'"},
+		{Path: "/priority", Message: "expected integer, but got string"},
+	})
+	want := "/: missing properties: 'title'; /priority: expected integer, but got string"
+	if got != want {
+		t.Errorf("Describe() = %q, want %q", got, want)
+	}
+}
@@ -11,6 +11,14 @@ import (
 	"go.uber.org/zap"
 )
 
+// Messages delivered to a consumer must stay unacknowledged for
+// pendingClaimMinIdle before another consumer takes them over; each
+// consumer looks for them every pendingClaimInterval
+const (
+	pendingClaimMinIdle  = time.Minute
+	pendingClaimInterval = 30 * time.Second
+)
+
 // StreamsEventBus implements EventBus using Redis Streams
 type StreamsEventBus struct {
 	client        *redis.Client
@@ -84,11 +92,21 @@ func (e *StreamsEventBus) Subscribe(ctx context.Context, topic string, handler p
 
 // readStream reads events from a stream
 func (e *StreamsEventBus) readStream(ctx context.Context, streamKey string, handler ports.EventHandler) {
+	// Pick up messages left unacknowledged by consumers that went away, on
+	// startup and periodically after, as consumers may stop at any time
+	e.claimPending(ctx, streamKey, handler)
+	lastClaim := time.Now()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
+			if time.Since(lastClaim) >= pendingClaimInterval {
+				e.claimPending(ctx, streamKey, handler)
+				lastClaim = time.Now()
+			}
+
 			// Read from stream
 			streams, err := e.client.XReadGroup(ctx, &redis.XReadGroupArgs{
 				Group:    e.consumerGroup,
@@ -120,6 +138,43 @@ func (e *StreamsEventBus) readStream(ctx context.Context, streamKey string, hand
 	}
 }
 
+// claimPending takes over messages delivered to consumers that stopped before
+// acknowledging them, such as a previous orchestrator process
+func (e *StreamsEventBus) claimPending(ctx context.Context, streamKey string, handler ports.EventHandler) {
+	start := "0-0"
+	for {
+		messages, next, err := e.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   streamKey,
+			Group:    e.consumerGroup,
+			Consumer: e.consumerName,
+			MinIdle:  pendingClaimMinIdle,
+			Start:    start,
+			Count:    10,
+		}).Result()
+		if err != nil {
+			e.logger.Error("failed to claim pending messages",
+				zap.String("stream", streamKey),
+				zap.Error(err))
+			return
+		}
+
+		if len(messages) > 0 {
+			e.logger.Info("claimed pending messages",
+				zap.String("stream", streamKey),
+				zap.Int("count", len(messages)))
+		}
+
+		for _, message := range messages {
+			e.processMessage(ctx, streamKey, message, handler)
+		}
+
+		if next == "" || next == "0-0" {
+			return
+		}
+		start = next
+	}
+}
+
 // processMessage processes a single message from the stream
 func (e *StreamsEventBus) processMessage(ctx context.Context, streamKey string, message redis.XMessage, handler ports.EventHandler) {
 	// Extract event data
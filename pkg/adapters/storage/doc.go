@@ -3,4 +3,10 @@
 // Implementations:
 //   - redis: Redis with JSON serialization and TTL (MVP)
 //   - memory: In-memory for testing
+//
+// Both implementations support versioned compare-and-swap writes of graph
+// state through VersionedStateStorage, durable timers through TimerStorage,
+// expiring leases through LeaseStorage, graph definitions through
+// DefinitionStorage and an index of active executions through
+// ExecutionIndexStorage.
 package storage
@@ -2,25 +2,46 @@ package memory
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
 	"github.com/aescanero/dago-libs/pkg/domain/state"
+	"github.com/aescanero/dago/pkg/adapters/storage"
 )
 
 // InMemoryStateStorage implements StateStorage using in-memory map
 // This is for testing purposes only
 type InMemoryStateStorage struct {
-	states map[string]interface{} // stores both state.State and domain.GraphState
-	mu     sync.RWMutex
+	states   map[string]interface{} // stores both state.State and domain.GraphState
+	versions map[string]int64       // graph state versions for compare-and-swap
+	timers   map[string]time.Time   // durable timers by due time
+	leases   map[string]lease       // leases by key
+	defs     map[string][]byte      // graph definitions by reference
+	active   map[string]bool        // index of active executions
+	mu       sync.RWMutex
+}
+
+// lease is a lease held by an owner until it expires
+type lease struct {
+	owner   string
+	expires time.Time
 }
 
 // NewInMemoryStateStorage creates a new in-memory state storage
 func NewInMemoryStateStorage() *InMemoryStateStorage {
 	return &InMemoryStateStorage{
-		states: make(map[string]interface{}),
+		states:   make(map[string]interface{}),
+		versions: make(map[string]int64),
+		timers:   make(map[string]time.Time),
+		leases:   make(map[string]lease),
+		defs:     make(map[string][]byte),
+		active:   make(map[string]bool),
 	}
 }
 
@@ -62,6 +83,7 @@ func (s *InMemoryStateStorage) Delete(ctx context.Context, executionID string) e
 	defer s.mu.Unlock()
 
 	delete(s.states, executionID)
+	delete(s.versions, executionID)
 	return nil
 }
 
@@ -106,21 +128,262 @@ func (s *InMemoryStateStorage) SaveState(ctx context.Context, state interface{})
 	defer s.mu.Unlock()
 
 	// Deep copy to avoid mutations
-	stateCopy := *graphState
-	s.states[graphState.GraphID] = &stateCopy
+	s.states[graphState.GraphID] = cloneGraphState(graphState)
+	s.versions[graphState.GraphID]++
 
 	return nil
 }
 
 // GetState retrieves graph state from memory (compatibility method)
 func (s *InMemoryStateStorage) GetState(ctx context.Context, graphID string) (interface{}, error) {
+	state, _, err := s.GetStateVersion(ctx, graphID)
+	return state, err
+}
+
+// GetStateVersion retrieves graph state together with its version
+func (s *InMemoryStateStorage) GetStateVersion(ctx context.Context, graphID string) (interface{}, int64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	state, ok := s.states[graphID]
 	if !ok {
-		return nil, fmt.Errorf("state not found: %s", graphID)
+		return nil, 0, fmt.Errorf("state not found: %s", graphID)
+	}
+
+	// Callers get their own copy so updates only land through SaveState
+	if graphState, ok := state.(*domain.GraphState); ok {
+		return cloneGraphState(graphState), s.versions[graphID], nil
+	}
+
+	return state, s.versions[graphID], nil
+}
+
+// SaveStateVersion saves graph state if it is still at expectedVersion
+func (s *InMemoryStateStorage) SaveStateVersion(ctx context.Context, state interface{}, expectedVersion int64) (int64, error) {
+	graphState, ok := state.(*domain.GraphState)
+	if !ok {
+		return 0, fmt.Errorf("invalid state type")
 	}
 
-	return state, nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.versions[graphState.GraphID]
+	if current != expectedVersion {
+		return current, storage.ErrVersionConflict
+	}
+
+	s.states[graphState.GraphID] = cloneGraphState(graphState)
+	s.versions[graphState.GraphID] = current + 1
+
+	return current + 1, nil
+}
+
+// ScheduleTimer stores a timer that becomes due at the given time
+func (s *InMemoryStateStorage) ScheduleTimer(ctx context.Context, timerID string, due time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timers[timerID] = due
+	return nil
+}
+
+// ClaimDueTimers removes and returns up to limit timers due at or before now,
+// earliest first
+func (s *InMemoryStateStorage) ClaimDueTimers(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := make([]string, 0)
+	for timerID, at := range s.timers {
+		if !at.After(now) {
+			due = append(due, timerID)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return s.timers[due[i]].Before(s.timers[due[j]])
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, timerID := range due {
+		delete(s.timers, timerID)
+	}
+	return due, nil
+}
+
+// AcquireLease takes a lease for owner unless another owner holds it
+func (s *InMemoryStateStorage) AcquireLease(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if current, ok := s.leases[key]; ok && current.owner != owner && now.Before(current.expires) {
+		return false, nil
+	}
+	s.leases[key] = lease{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// ReleaseLease removes a lease held by owner
+func (s *InMemoryStateStorage) ReleaseLease(ctx context.Context, key, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.leases[key]; ok && current.owner == owner {
+		delete(s.leases, key)
+	}
+	return nil
+}
+
+// SaveDefinition stores a copy of an encoded graph definition
+func (s *InMemoryStateStorage) SaveDefinition(ctx context.Context, ref string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defs[ref] = append([]byte(nil), data...)
+	return nil
+}
+
+// GetDefinition returns a copy of the graph definition stored under ref
+func (s *InMemoryStateStorage) GetDefinition(ctx context.Context, ref string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.defs[ref]
+	if !ok {
+		return nil, storage.ErrDefinitionNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// AddActiveExecution adds an execution to the index of active executions
+func (s *InMemoryStateStorage) AddActiveExecution(ctx context.Context, graphID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active[graphID] = true
+	return nil
+}
+
+// RemoveActiveExecution removes an execution from the index of active executions
+func (s *InMemoryStateStorage) RemoveActiveExecution(ctx context.Context, graphID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.active, graphID)
+	return nil
+}
+
+// ListActiveExecutions returns the indexed executions in ID order
+func (s *InMemoryStateStorage) ListActiveExecutions(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	graphIDs := make([]string, 0, len(s.active))
+	for graphID := range s.active {
+		graphIDs = append(graphIDs, graphID)
+	}
+	sort.Strings(graphIDs)
+	return graphIDs, nil
+}
+
+// cloneGraphState deep-copies a graph state, including its graph, so that
+// the stored copy shares no mutable data with callers. Graph nodes are
+// shared, as executions never modify them.
+func cloneGraphState(src *domain.GraphState) *domain.GraphState {
+	dst := *src
+	dst.Graph = cloneGraph(src.Graph)
+	dst.Inputs = cloneMap(src.Inputs)
+	if src.NodeStates == nil {
+		return &dst
+	}
+
+	dst.NodeStates = make(map[string]*domain.NodeState, len(src.NodeStates))
+	for nodeID, nodeState := range src.NodeStates {
+		if nodeState == nil {
+			continue
+		}
+		nodeCopy := *nodeState
+		nodeCopy.Output = cloneValue(nodeState.Output)
+		nodeCopy.Metadata = cloneMap(nodeState.Metadata)
+		dst.NodeStates[nodeID] = &nodeCopy
+	}
+
+	return &dst
+}
+
+// cloneGraph copies a graph's metadata and edges
+func cloneGraph(src *domain.Graph) *domain.Graph {
+	if src == nil {
+		return nil
+	}
+
+	dst := *src
+	dst.Metadata = cloneMap(src.Metadata)
+	if src.Nodes != nil {
+		dst.Nodes = make(map[string]graph.Node, len(src.Nodes))
+		for nodeID, node := range src.Nodes {
+			dst.Nodes[nodeID] = node
+		}
+	}
+	if src.Edges != nil {
+		dst.Edges = make([]*graph.Edge, len(src.Edges))
+		for i, edge := range src.Edges {
+			if edge == nil {
+				continue
+			}
+			edgeCopy := *edge
+			edgeCopy.Metadata = cloneMap(edge.Metadata)
+			dst.Edges[i] = &edgeCopy
+		}
+	}
+	return &dst
+}
+
+// cloneMap deep-copies a map of decoded values
+func cloneMap(src map[string]interface{}) map[string]interface{} {
+	if src == nil {
+		return nil
+	}
+	dst := make(map[string]interface{}, len(src))
+	for key, value := range src {
+		dst[key] = cloneValue(value)
+	}
+	return dst
+}
+
+// cloneValue deep-copies a value. Maps and slices of decoded JSON are copied
+// as they are; other maps, slices and pointers are copied through their JSON
+// form, as Redis storage would return them.
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		return cloneMap(v)
+	case []interface{}:
+		dst := make([]interface{}, len(v))
+		for i, elem := range v {
+			dst[i] = cloneValue(elem)
+		}
+		return dst
+	case []string:
+		return append([]string(nil), v...)
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Pointer:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return value
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return value
+		}
+		return decoded
+	}
+	return value
 }
@@ -0,0 +1,203 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago/pkg/adapters/storage"
+)
+
+func TestSaveStateVersionRejectsStaleWrites(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStateStorage()
+	state := &domain.GraphState{GraphID: "exec-1", Status: domain.ExecutionStatusRunning}
+
+	version, err := store.SaveStateVersion(ctx, state, 0)
+	if err != nil || version != 1 {
+		t.Fatalf("first SaveStateVersion() = %d, %v; want 1, nil", version, err)
+	}
+	if _, err := store.SaveStateVersion(ctx, state, 0); !errors.Is(err, storage.ErrVersionConflict) {
+		t.Errorf("SaveStateVersion() at a stale version error = %v, want a version conflict", err)
+	}
+
+	// Unversioned writes bump the version too
+	if err := store.SaveState(ctx, state); err != nil {
+		t.Fatalf("SaveState() error = %v", err)
+	}
+	if _, version, err := store.GetStateVersion(ctx, "exec-1"); err != nil || version != 2 {
+		t.Errorf("GetStateVersion() = %d, %v; want 2, nil", version, err)
+	}
+	if _, err := store.SaveStateVersion(ctx, state, 1); !errors.Is(err, storage.ErrVersionConflict) {
+		t.Errorf("SaveStateVersion() after an unversioned write error = %v, want a version conflict", err)
+	}
+	if version, err := store.SaveStateVersion(ctx, state, 2); err != nil || version != 3 {
+		t.Errorf("SaveStateVersion() at the current version = %d, %v; want 3, nil", version, err)
+	}
+}
+
+func TestGraphStateCopiesAreIndependent(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStateStorage()
+
+	original := &domain.GraphState{
+		GraphID: "exec-1",
+		Graph: &domain.Graph{
+			ID:       "g",
+			Metadata: map[string]interface{}{"paused_for": "1s"},
+			Edges: []*graph.Edge{
+				{From: "a", To: "b", Metadata: map[string]interface{}{"default": true}},
+			},
+		},
+		Inputs: map[string]interface{}{"doc": map[string]interface{}{"title": "draft"}},
+		NodeStates: map[string]*domain.NodeState{
+			"a": {
+				NodeID: "a",
+				Output: map[string]interface{}{"tags": []interface{}{"x"}},
+				Metadata: map[string]interface{}{
+					"loop_counts": map[string]interface{}{"b": 1.0},
+					"next_nodes":  []string{"b"},
+				},
+			},
+		},
+	}
+	if _, err := store.SaveStateVersion(ctx, original, 0); err != nil {
+		t.Fatalf("SaveStateVersion() error = %v", err)
+	}
+
+	// Changes to the saved value do not reach the store
+	original.Graph.Metadata["paused_at"] = "now"
+	original.Graph.Edges[0].Metadata["default"] = false
+	original.Inputs["doc"].(map[string]interface{})["title"] = "changed"
+	original.NodeStates["a"].Metadata["loop_counts"].(map[string]interface{})["b"] = 2.0
+
+	raw, _, err := store.GetStateVersion(ctx, "exec-1")
+	if err != nil {
+		t.Fatalf("GetStateVersion() error = %v", err)
+	}
+	loaded := raw.(*domain.GraphState)
+	assertUnchanged := func(state *domain.GraphState) {
+		t.Helper()
+		if _, ok := state.Graph.Metadata["paused_at"]; ok {
+			t.Error("graph metadata shared with the caller")
+		}
+		if state.Graph.Edges[0].Metadata["default"] != true {
+			t.Error("edge metadata shared with the caller")
+		}
+		if title := state.Inputs["doc"].(map[string]interface{})["title"]; title != "draft" {
+			t.Errorf("inputs shared with the caller: title = %v", title)
+		}
+		if count := state.NodeStates["a"].Metadata["loop_counts"].(map[string]interface{})["b"]; count != 1.0 {
+			t.Errorf("nested node metadata shared with the caller: count = %v", count)
+		}
+	}
+	assertUnchanged(loaded)
+
+	// Changes to a loaded copy do not reach the store either
+	loaded.Graph.Metadata["paused_at"] = "now"
+	loaded.Graph.Edges[0].Metadata["default"] = false
+	loaded.Inputs["doc"].(map[string]interface{})["title"] = "changed"
+	loaded.NodeStates["a"].Metadata["loop_counts"].(map[string]interface{})["b"] = 2.0
+	loaded.NodeStates["a"].Metadata["next_nodes"].([]string)[0] = "c"
+	loaded.NodeStates["a"].Output.(map[string]interface{})["tags"].([]interface{})[0] = "y"
+
+	raw, _, err = store.GetStateVersion(ctx, "exec-1")
+	if err != nil {
+		t.Fatalf("GetStateVersion() error = %v", err)
+	}
+	reloaded := raw.(*domain.GraphState)
+	assertUnchanged(reloaded)
+	if next := reloaded.NodeStates["a"].Metadata["next_nodes"].([]string)[0]; next != "b" {
+		t.Errorf("node metadata slice shared with the caller: next = %s", next)
+	}
+	if tag := reloaded.NodeStates["a"].Output.(map[string]interface{})["tags"].([]interface{})[0]; tag != "x" {
+		t.Errorf("node output shared with the caller: tag = %v", tag)
+	}
+}
+
+func TestClaimDueTimers(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStateStorage()
+	now := time.Now()
+
+	for id, due := range map[string]time.Time{
+		"late":   now.Add(-time.Second),
+		"later":  now,
+		"early":  now.Add(-time.Minute),
+		"future": now.Add(time.Hour),
+	} {
+		if err := store.ScheduleTimer(ctx, id, due); err != nil {
+			t.Fatalf("ScheduleTimer() error = %v", err)
+		}
+	}
+
+	// Due timers are claimed earliest first, up to the limit
+	claimed, err := store.ClaimDueTimers(ctx, now, 2)
+	if err != nil {
+		t.Fatalf("ClaimDueTimers() error = %v", err)
+	}
+	if !reflect.DeepEqual(claimed, []string{"early", "late"}) {
+		t.Errorf("first claim = %v, want [early late]", claimed)
+	}
+
+	// Claimed timers are gone; timers not yet due stay
+	claimed, err = store.ClaimDueTimers(ctx, now, 10)
+	if err != nil {
+		t.Fatalf("ClaimDueTimers() error = %v", err)
+	}
+	if !reflect.DeepEqual(claimed, []string{"later"}) {
+		t.Errorf("second claim = %v, want [later]", claimed)
+	}
+	if claimed, _ := store.ClaimDueTimers(ctx, now.Add(2*time.Hour), 10); !reflect.DeepEqual(claimed, []string{"future"}) {
+		t.Errorf("claim after an hour = %v, want [future]", claimed)
+	}
+}
+
+func TestDefinitions(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStateStorage()
+
+	if _, err := store.GetDefinition(ctx, "child"); !errors.Is(err, storage.ErrDefinitionNotFound) {
+		t.Fatalf("GetDefinition() of an unknown reference error = %v, want %v", err, storage.ErrDefinitionNotFound)
+	}
+
+	// Saving a reference again replaces its definition
+	for _, def := range []string{`{"id":"v1"}`, `{"id":"v2"}`} {
+		if err := store.SaveDefinition(ctx, "child", []byte(def)); err != nil {
+			t.Fatalf("SaveDefinition() error = %v", err)
+		}
+	}
+	data, err := store.GetDefinition(ctx, "child")
+	if err != nil {
+		t.Fatalf("GetDefinition() error = %v", err)
+	}
+	if string(data) != `{"id":"v2"}` {
+		t.Errorf("definition = %s, want the one saved last", data)
+	}
+}
+
+func TestActiveExecutionIndex(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStateStorage()
+
+	for _, graphID := range []string{"g2", "g1", "g2"} {
+		if err := store.AddActiveExecution(ctx, graphID); err != nil {
+			t.Fatalf("AddActiveExecution() error = %v", err)
+		}
+	}
+	if err := store.RemoveActiveExecution(ctx, "g2"); err != nil {
+		t.Fatalf("RemoveActiveExecution() error = %v", err)
+	}
+
+	active, err := store.ListActiveExecutions(ctx)
+	if err != nil {
+		t.Fatalf("ListActiveExecutions() error = %v", err)
+	}
+	if !reflect.DeepEqual(active, []string{"g1"}) {
+		t.Errorf("active executions = %v, want [g1]", active)
+	}
+}
@@ -4,14 +4,74 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/aescanero/dago-libs/pkg/domain"
 	"github.com/aescanero/dago-libs/pkg/domain/state"
+	"github.com/aescanero/dago/pkg/adapters/storage"
+	"github.com/aescanero/dago/pkg/nodes"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
+// saveStateScript writes a graph state only when its version key still holds
+// the expected version and bumps the version in the same step.
+// KEYS: state key, version key. ARGV: state JSON, expected version, TTL in ms.
+var saveStateScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[2]) or '0')
+if current ~= tonumber(ARGV[2]) then
+	return -1
+end
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+	redis.call('SET', KEYS[2], current + 1, 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+	redis.call('SET', KEYS[2], current + 1)
+end
+return current + 1
+`)
+
+// claimTimersScript removes and returns the timers due by a score, so that
+// each timer is claimed by a single caller.
+// KEYS: timers key. ARGV: score in Unix milliseconds, limit.
+var claimTimersScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+if #due > 0 then
+	redis.call('ZREM', KEYS[1], unpack(due))
+end
+return due
+`)
+
+// acquireLeaseScript sets a lease key to its owner unless another owner
+// holds it, so that taking and renewing a lease are one step.
+// KEYS: lease key. ARGV: owner, TTL in ms.
+var acquireLeaseScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner and owner ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', tonumber(ARGV[2]))
+return 1
+`)
+
+// releaseLeaseScript deletes a lease key if it still names the owner.
+// KEYS: lease key. ARGV: owner.
+var releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// timersKey is the sorted set holding timers scored by their due time
+const timersKey = "dago:timers"
+
+// activeExecutionsKey is the set indexing the executions still active
+const activeExecutionsKey = "dago:executions:active"
+
 // StateStorage implements StateStorage using Redis
 type StateStorage struct {
 	client *redis.Client
@@ -72,7 +132,7 @@ func (s *StateStorage) Load(ctx context.Context, executionID string) (state.Stat
 func (s *StateStorage) Delete(ctx context.Context, executionID string) error {
 	key := getStateKey(executionID)
 
-	if err := s.client.Del(ctx, key).Err(); err != nil {
+	if err := s.client.Del(ctx, key, getStateVersionKey(executionID)).Err(); err != nil {
 		return fmt.Errorf("failed to delete state: %w", err)
 	}
 
@@ -99,6 +159,10 @@ func (s *StateStorage) SetTTL(ctx context.Context, executionID string, ttl time.
 		return fmt.Errorf("failed to set TTL: %w", err)
 	}
 
+	if err := s.client.Expire(ctx, getStateVersionKey(executionID), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set TTL: %w", err)
+	}
+
 	return nil
 }
 
@@ -153,8 +217,17 @@ func (s *StateStorage) SaveState(ctx context.Context, state interface{}) error {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 
-	// Save to Redis with TTL
-	if err := s.client.Set(ctx, key, data, s.ttl).Err(); err != nil {
+	// Save to Redis with TTL, bumping the version so that concurrent
+	// versioned writers notice the change
+	versionKey := getStateVersionKey(graphState.GraphID)
+	if _, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, data, s.ttl)
+		pipe.Incr(ctx, versionKey)
+		if s.ttl > 0 {
+			pipe.Expire(ctx, versionKey, s.ttl)
+		}
+		return nil
+	}); err != nil {
 		return fmt.Errorf("failed to save state: %w", err)
 	}
 
@@ -167,31 +240,74 @@ func (s *StateStorage) SaveState(ctx context.Context, state interface{}) error {
 
 // GetState retrieves graph state from Redis (compatibility method)
 func (s *StateStorage) GetState(ctx context.Context, graphID string) (interface{}, error) {
-	key := getStateKey(graphID)
+	state, _, err := s.GetStateVersion(ctx, graphID)
+	return state, err
+}
 
-	// Get from Redis
-	data, err := s.client.Get(ctx, key).Bytes()
+// GetStateVersion retrieves graph state together with its version
+func (s *StateStorage) GetStateVersion(ctx context.Context, graphID string) (interface{}, int64, error) {
+	// Read state and version in one round trip so they match
+	values, err := s.client.MGet(ctx, getStateKey(graphID), getStateVersionKey(graphID)).Result()
 	if err != nil {
-		if err == redis.Nil {
-			return nil, fmt.Errorf("state not found: %s", graphID)
+		return nil, 0, fmt.Errorf("failed to get state: %w", err)
+	}
+
+	data, ok := values[0].(string)
+	if !ok {
+		return nil, 0, fmt.Errorf("state not found: %s", graphID)
+	}
+
+	var version int64
+	if raw, ok := values[1].(string); ok {
+		version, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid state version: %w", err)
 		}
-		return nil, fmt.Errorf("failed to get state: %w", err)
 	}
 
-	// Deserialize state
-	var state domain.GraphState
-	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	// Deserialize state, restoring concrete node types
+	state, err := nodes.DecodeGraphState([]byte(data))
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return &state, nil
+	return state, version, nil
+}
+
+// SaveStateVersion saves graph state to Redis if it is still at expectedVersion
+func (s *StateStorage) SaveStateVersion(ctx context.Context, state interface{}, expectedVersion int64) (int64, error) {
+	graphState, ok := state.(*domain.GraphState)
+	if !ok {
+		return 0, fmt.Errorf("invalid state type")
+	}
+
+	data, err := json.Marshal(graphState)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	keys := []string{getStateKey(graphState.GraphID), getStateVersionKey(graphState.GraphID)}
+	version, err := saveStateScript.Run(ctx, s.client, keys, data, expectedVersion, s.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to save state: %w", err)
+	}
+	if version < 0 {
+		return 0, storage.ErrVersionConflict
+	}
+
+	s.logger.Debug("state saved",
+		zap.String("graph_id", graphState.GraphID),
+		zap.String("status", string(graphState.Status)),
+		zap.Int64("version", version))
+
+	return version, nil
 }
 
 // DeleteState deletes graph state from Redis
 func (s *StateStorage) DeleteState(ctx context.Context, graphID string) error {
 	key := getStateKey(graphID)
 
-	if err := s.client.Del(ctx, key).Err(); err != nil {
+	if err := s.client.Del(ctx, key, getStateVersionKey(graphID)).Err(); err != nil {
 		return fmt.Errorf("failed to delete state: %w", err)
 	}
 
@@ -233,18 +349,128 @@ func (s *StateStorage) ListStates(ctx context.Context) ([]*domain.GraphState, er
 			continue
 		}
 
-		var state domain.GraphState
-		if err := json.Unmarshal(data, &state); err != nil {
+		state, err := nodes.DecodeGraphState(data)
+		if err != nil {
 			continue
 		}
 
-		states = append(states, &state)
+		states = append(states, state)
 	}
 
 	return states, nil
 }
 
+// ScheduleTimer stores a timer in the timers sorted set, scored by its due
+// time in Unix milliseconds
+func (s *StateStorage) ScheduleTimer(ctx context.Context, timerID string, due time.Time) error {
+	err := s.client.ZAdd(ctx, timersKey, redis.Z{
+		Score:  float64(due.UnixMilli()),
+		Member: timerID,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to schedule timer: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueTimers removes and returns up to limit timers due at or before now
+func (s *StateStorage) ClaimDueTimers(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	due, err := claimTimersScript.Run(ctx, s.client, []string{timersKey}, now.UnixMilli(), limit).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim timers: %w", err)
+	}
+
+	if len(due) > 0 {
+		s.logger.Debug("timers claimed",
+			zap.Int("count", len(due)))
+	}
+
+	return due, nil
+}
+
+// AcquireLease takes a lease key for owner until ttl passes. Redis expires
+// the key, so leases of an owner that stopped renewing them lapse.
+func (s *StateStorage) AcquireLease(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	acquired, err := acquireLeaseScript.Run(ctx, s.client, []string{getLeaseKey(key)}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	return acquired == 1, nil
+}
+
+// ReleaseLease deletes a lease key held by owner
+func (s *StateStorage) ReleaseLease(ctx context.Context, key, owner string) error {
+	if err := releaseLeaseScript.Run(ctx, s.client, []string{getLeaseKey(key)}, owner).Err(); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
+
+// SaveDefinition stores an encoded graph definition without TTL, as
+// definitions outlive the executions that use them
+func (s *StateStorage) SaveDefinition(ctx context.Context, ref string, data []byte) error {
+	if err := s.client.Set(ctx, getDefinitionKey(ref), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save definition: %w", err)
+	}
+	return nil
+}
+
+// GetDefinition retrieves the encoded graph definition stored under ref
+func (s *StateStorage) GetDefinition(ctx context.Context, ref string) ([]byte, error) {
+	data, err := s.client.Get(ctx, getDefinitionKey(ref)).Bytes()
+	if err == redis.Nil {
+		return nil, storage.ErrDefinitionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get definition: %w", err)
+	}
+	return data, nil
+}
+
+// AddActiveExecution adds an execution to the index of active executions
+func (s *StateStorage) AddActiveExecution(ctx context.Context, graphID string) error {
+	if err := s.client.SAdd(ctx, activeExecutionsKey, graphID).Err(); err != nil {
+		return fmt.Errorf("failed to index execution: %w", err)
+	}
+	return nil
+}
+
+// RemoveActiveExecution removes an execution from the index of active executions
+func (s *StateStorage) RemoveActiveExecution(ctx context.Context, graphID string) error {
+	if err := s.client.SRem(ctx, activeExecutionsKey, graphID).Err(); err != nil {
+		return fmt.Errorf("failed to unindex execution: %w", err)
+	}
+	return nil
+}
+
+// ListActiveExecutions returns the executions in the index of active executions
+func (s *StateStorage) ListActiveExecutions(ctx context.Context) ([]string, error) {
+	graphIDs, err := s.client.SMembers(ctx, activeExecutionsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active executions: %w", err)
+	}
+	return graphIDs, nil
+}
+
 // getStateKey returns the Redis key for a graph state
 func getStateKey(graphID string) string {
 	return fmt.Sprintf("dago:state:%s", graphID)
 }
+
+// getStateVersionKey returns the Redis key holding a graph state's version.
+// It is outside the dago:state:* pattern so it never shows up in List.
+func getStateVersionKey(graphID string) string {
+	return fmt.Sprintf("dago:state-version:%s", graphID)
+}
+
+// getLeaseKey returns the Redis key holding a lease. It is outside the
+// dago:state:* pattern so it never shows up in List.
+func getLeaseKey(key string) string {
+	return fmt.Sprintf("dago:lease:%s", key)
+}
+
+// getDefinitionKey returns the Redis key holding a graph definition. It is
+// outside the dago:state:* pattern so it never shows up in List.
+func getDefinitionKey(ref string) string {
+	return fmt.Sprintf("dago:definition:%s", ref)
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrVersionConflict is returned when a versioned write finds that the
+// stored state changed since it was read
+var ErrVersionConflict = errors.New("state version conflict")
+
+// ErrDefinitionNotFound is returned when no graph definition is stored under
+// a reference
+var ErrDefinitionNotFound = errors.New("graph definition not found")
+
+// VersionedStateStorage is implemented by state storages that support
+// optimistic concurrency on graph states. Every successful write bumps the
+// state's version; unversioned SaveState calls bump it too.
+type VersionedStateStorage interface {
+	// GetStateVersion retrieves graph state together with its current version
+	GetStateVersion(ctx context.Context, graphID string) (interface{}, int64, error)
+
+	// SaveStateVersion persists graph state only if its stored version still
+	// equals expectedVersion (0 for a state that was never saved) and returns
+	// the new version. Stale writes fail with ErrVersionConflict.
+	SaveStateVersion(ctx context.Context, state interface{}, expectedVersion int64) (int64, error)
+}
+
+// TimerStorage is implemented by state storages that keep durable timers,
+// which outlive the process that scheduled them. Each due timer is claimed
+// by exactly one caller, even across several orchestrator replicas.
+type TimerStorage interface {
+	// ScheduleTimer stores a timer that becomes due at the given time.
+	// Scheduling an existing timer again moves it.
+	ScheduleTimer(ctx context.Context, timerID string, due time.Time) error
+
+	// ClaimDueTimers removes and returns up to limit timers due at or
+	// before now
+	ClaimDueTimers(ctx context.Context, now time.Time, limit int) ([]string, error)
+}
+
+// LeaseStorage is implemented by state storages that grant leases, which
+// expire unless renewed. Of several orchestrator replicas, only the one
+// holding an execution's lease looks after it.
+type LeaseStorage interface {
+	// AcquireLease takes the lease named key for owner until ttl passes and
+	// reports whether it did. A lease held by another owner that has not
+	// expired is refused; acquiring a lease already held renews it.
+	AcquireLease(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+
+	// ReleaseLease gives up a lease, if owner holds it
+	ReleaseLease(ctx context.Context, key, owner string) error
+}
+
+// DefinitionStorage is implemented by state storages that keep the graph
+// definitions registered for subgraph nodes. Definitions do not expire, and
+// every orchestrator replica resolves the same ones.
+type DefinitionStorage interface {
+	// SaveDefinition stores an encoded graph definition under a reference,
+	// replacing any definition stored under it before
+	SaveDefinition(ctx context.Context, ref string, data []byte) error
+
+	// GetDefinition retrieves the encoded graph definition stored under a
+	// reference, or fails with ErrDefinitionNotFound
+	GetDefinition(ctx context.Context, ref string) ([]byte, error)
+}
+
+// ExecutionIndexStorage is implemented by state storages that keep an index
+// of the executions still active, so that replicas sweep those without
+// reading every stored state. Callers maintain the index; it may still name
+// executions that finished or whose state expired, which callers prune.
+type ExecutionIndexStorage interface {
+	// AddActiveExecution adds an execution to the index
+	AddActiveExecution(ctx context.Context, graphID string) error
+
+	// RemoveActiveExecution removes an execution from the index
+	RemoveActiveExecution(ctx context.Context, graphID string) error
+
+	// ListActiveExecutions returns the executions in the index
+	ListActiveExecutions(ctx context.Context) ([]string, error)
+}
@@ -1,18 +1,23 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/aescanero/dago-libs/pkg/domain"
 	"github.com/aescanero/dago-libs/pkg/ports"
+	"github.com/aescanero/dago/internal/application/orchestrator"
+	"github.com/aescanero/dago/pkg/nodes"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
-// GraphSubmitRequest represents a graph submission request
+// GraphSubmitRequest represents a graph submission request. The graph is
+// decoded separately so that each node gets its concrete type.
 type GraphSubmitRequest struct {
-	Graph  *domain.Graph          `json:"graph" binding:"required"`
+	Graph  json.RawMessage        `json:"graph" binding:"required"`
 	Inputs map[string]interface{} `json:"inputs"`
 }
 
@@ -23,6 +28,14 @@ type GraphSubmitResponse struct {
 	SubmittedAt string `json:"submitted_at"`
 }
 
+// GraphResponse represents a graph execution together with the executions
+// it is linked to through subgraph nodes
+type GraphResponse struct {
+	*domain.GraphState
+	Parent   *orchestrator.ExecutionLink  `json:"parent,omitempty"`
+	Children []orchestrator.ExecutionLink `json:"children,omitempty"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error ErrorDetail `json:"error"`
@@ -60,10 +73,37 @@ func (s *Server) handleSubmitGraph(c *gin.Context) {
 		return
 	}
 
+	g, err := nodes.DecodeGraph(req.Graph)
+	if err != nil {
+		s.logger.Error("invalid graph", zap.Error(err))
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: ErrorDetail{
+				Code:    "INVALID_REQUEST",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
 	// Submit graph
-	graphID, err := s.orchestrator.SubmitGraph(c.Request.Context(), req.Graph, req.Inputs)
+	graphID, err := s.orchestrator.SubmitGraph(c.Request.Context(), g, req.Inputs)
 	if err != nil {
 		s.logger.Error("failed to submit graph", zap.Error(err))
+
+		// Structural problems carry the offending path or nodes, and inputs
+		// that do not match the input schema each field that failed
+		var validationErr *orchestrator.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+				Error: ErrorDetail{
+					Code:    validationErr.Code,
+					Message: err.Error(),
+					Details: validationErr.Details,
+				},
+			})
+			return
+		}
+
 		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
 			Error: ErrorDetail{
 				Code:    "SUBMISSION_FAILED",
@@ -80,6 +120,47 @@ func (s *Server) handleSubmitGraph(c *gin.Context) {
 	})
 }
 
+// handleRegisterDefinition handles registering a graph for subgraph nodes
+func (s *Server) handleRegisterDefinition(c *gin.Context) {
+	ref := c.Param("ref")
+
+	data, err := c.GetRawData()
+	if err == nil {
+		var g *domain.Graph
+		if g, err = nodes.DecodeGraph(data); err == nil {
+			err = s.orchestrator.RegisterGraph(c.Request.Context(), ref, g)
+		}
+	}
+	if err != nil {
+		s.logger.Error("failed to register graph", zap.String("graph_ref", ref), zap.Error(err))
+
+		var validationErr *orchestrator.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+				Error: ErrorDetail{
+					Code:    validationErr.Code,
+					Message: err.Error(),
+					Details: validationErr.Details,
+				},
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: ErrorDetail{
+				Code:    "INVALID_REQUEST",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"graph_ref": ref,
+		"status":    "registered",
+	})
+}
+
 // handleListGraphs handles listing graphs
 func (s *Server) handleListGraphs(c *gin.Context) {
 	// For MVP, return empty list
@@ -107,7 +188,11 @@ func (s *Server) handleGetGraph(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, state)
+	c.JSON(http.StatusOK, GraphResponse{
+		GraphState: state,
+		Parent:     orchestrator.ParentExecution(state),
+		Children:   orchestrator.ChildExecutions(state),
+	})
 }
 
 // handleGetStatus handles getting graph status
@@ -163,6 +248,7 @@ func (s *Server) handleGetResult(c *gin.Context) {
 		"graph_id":     state.GraphID,
 		"status":       state.Status,
 		"result":       state.NodeStates,
+		"state":        orchestrator.SharedState(state),
 		"completed_at": state.CompletedAt,
 	})
 }
@@ -188,6 +274,146 @@ func (s *Server) handleCancelGraph(c *gin.Context) {
 	})
 }
 
+// handlePauseGraph handles pausing a graph execution
+func (s *Server) handlePauseGraph(c *gin.Context) {
+	graphID := c.Param("id")
+
+	if err := s.orchestrator.PauseExecution(c.Request.Context(), graphID); err != nil {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: ErrorDetail{
+				Code:    "PAUSE_FAILED",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"graph_id": graphID,
+		"status":   orchestrator.ExecutionStatusPaused,
+	})
+}
+
+// handleResumeGraph handles resuming a paused graph execution
+func (s *Server) handleResumeGraph(c *gin.Context) {
+	graphID := c.Param("id")
+
+	if err := s.orchestrator.ResumeExecution(c.Request.Context(), graphID); err != nil {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: ErrorDetail{
+				Code:    "RESUME_FAILED",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"graph_id": graphID,
+		"status":   domain.ExecutionStatusRunning,
+	})
+}
+
+// handleApproveNode handles approving a human node
+func (s *Server) handleApproveNode(c *gin.Context) {
+	s.respondToNode(c, orchestrator.HumanApprove)
+}
+
+// handleRejectNode handles rejecting a human node
+func (s *Server) handleRejectNode(c *gin.Context) {
+	s.respondToNode(c, orchestrator.HumanReject)
+}
+
+// handleRespondNode handles responding to a human node
+func (s *Server) handleRespondNode(c *gin.Context) {
+	s.respondToNode(c, orchestrator.HumanRespond)
+}
+
+// respondToNode answers a human node with the optional JSON object in the
+// request body
+func (s *Server) respondToNode(c *gin.Context, action orchestrator.HumanAction) {
+	graphID := c.Param("id")
+	nodeID := c.Param("node")
+
+	var payload map[string]interface{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: ErrorDetail{
+					Code:    "INVALID_REQUEST",
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	if err := s.orchestrator.RespondToNode(c.Request.Context(), graphID, nodeID, action, payload); err != nil {
+		if errors.Is(err, orchestrator.ErrNotHumanNode) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: ErrorDetail{
+					Code:    "NOT_HUMAN_NODE",
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: ErrorDetail{
+				Code:    "RESPONSE_FAILED",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"graph_id": graphID,
+		"node_id":  nodeID,
+		"action":   action,
+	})
+}
+
+// handleSignal handles a callback resuming a signal node. The request body,
+// if any, becomes the node's output.
+func (s *Server) handleSignal(c *gin.Context) {
+	var payload interface{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: ErrorDetail{
+					Code:    "INVALID_REQUEST",
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	if err := s.orchestrator.Signal(c.Request.Context(), c.Param("token"), payload); err != nil {
+		if errors.Is(err, orchestrator.ErrSignalNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error: ErrorDetail{
+					Code:    "SIGNAL_NOT_FOUND",
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: ErrorDetail{
+				Code:    "SIGNAL_FAILED",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "accepted",
+	})
+}
+
 // WorkerResponse represents the worker data format expected by the dashboard
 type WorkerResponse struct {
 	ID            string                 `json:"id"`
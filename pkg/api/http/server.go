@@ -75,6 +75,19 @@ func (s *Server) setupRoutes() {
 		v1.GET("/graphs/:id/status", s.handleGetStatus)
 		v1.GET("/graphs/:id/result", s.handleGetResult)
 		v1.POST("/graphs/:id/cancel", s.handleCancelGraph)
+		v1.POST("/graphs/:id/pause", s.handlePauseGraph)
+		v1.POST("/graphs/:id/resume", s.handleResumeGraph)
+
+		// Human node responses
+		v1.POST("/graphs/:id/nodes/:node/approve", s.handleApproveNode)
+		v1.POST("/graphs/:id/nodes/:node/reject", s.handleRejectNode)
+		v1.POST("/graphs/:id/nodes/:node/respond", s.handleRespondNode)
+
+		// Callbacks of signal nodes
+		v1.POST("/signals/:token", s.handleSignal)
+
+		// Graph definitions for subgraph nodes
+		v1.PUT("/definitions/:ref", s.handleRegisterDefinition)
 
 		// Worker endpoints
 		v1.GET("/workers", s.handleListWorkers)
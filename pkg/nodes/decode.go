@@ -0,0 +1,130 @@
+package nodes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+// DecodeGraph decodes a graph definition, restoring the concrete type of each node.
+// Nodes may be given as a map keyed by node ID or as a list.
+func DecodeGraph(data []byte) (*domain.Graph, error) {
+	var raw struct {
+		graph.Graph
+		Nodes json.RawMessage `json:"nodes"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal graph: %w", err)
+	}
+
+	g := raw.Graph
+	g.Nodes = make(map[string]graph.Node)
+
+	nodes, err := splitNodes(raw.Nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, nodeData := range nodes {
+		node, err := DecodeNode(id, nodeData)
+		if err != nil {
+			return nil, err
+		}
+		g.Nodes[node.GetID()] = node
+	}
+
+	return &g, nil
+}
+
+// DecodeGraphState decodes a graph state, restoring the concrete types of its graph's nodes
+func DecodeGraphState(data []byte) (*domain.GraphState, error) {
+	var raw struct {
+		domain.GraphState
+		Graph json.RawMessage `json:"graph"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+
+	state := raw.GraphState
+	if len(raw.Graph) > 0 && !bytes.Equal(raw.Graph, []byte("null")) {
+		g, err := DecodeGraph(raw.Graph)
+		if err != nil {
+			return nil, err
+		}
+		state.Graph = g
+	}
+
+	return &state, nil
+}
+
+// DecodeNode decodes a single node based on its type. The fallback ID is
+// used when the node data does not carry one.
+func DecodeNode(fallbackID string, data []byte) (graph.Node, error) {
+	var header struct {
+		ID   string         `json:"id"`
+		Type graph.NodeType `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal node %s: %w", fallbackID, err)
+	}
+
+	id := header.ID
+	if id == "" {
+		id = fallbackID
+	}
+
+	switch header.Type {
+	case graph.NodeTypeExecutor:
+		node := &graph.ExecutorNode{}
+		if err := json.Unmarshal(data, node); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal executor node %s: %w", id, err)
+		}
+		node.ID = id
+		return node, nil
+	case graph.NodeTypeRouter:
+		node := &graph.RouterNode{}
+		if err := json.Unmarshal(data, node); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal router node %s: %w", id, err)
+		}
+		node.ID = id
+		return node, nil
+	default:
+		node := &Node{}
+		if err := json.Unmarshal(data, node); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal node %s: %w", id, err)
+		}
+		node.ID = id
+		return node, nil
+	}
+}
+
+// splitNodes returns the raw node definitions keyed by node ID
+func splitNodes(data json.RawMessage) (map[string]json.RawMessage, error) {
+	nodes := make(map[string]json.RawMessage)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nodes, nil
+	}
+
+	if err := json.Unmarshal(data, &nodes); err == nil {
+		return nodes, nil
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("nodes must be an object or a list: %w", err)
+	}
+	for i, nodeData := range list {
+		var header struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(nodeData, &header); err != nil || header.ID == "" {
+			return nil, fmt.Errorf("node %d in list has no id", i)
+		}
+		nodes[header.ID] = nodeData
+	}
+	return nodes, nil
+}
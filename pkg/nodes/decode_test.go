@@ -0,0 +1,89 @@
+package nodes
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aescanero/dago-libs/pkg/domain"
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+)
+
+func TestDecodeGraphRestoresNodeTypes(t *testing.T) {
+	for name, data := range map[string]string{
+		"map": `{"id": "g", "entry_node": "start", "nodes": {
+			"start": {"type": "start"},
+			"llm": {"id": "llm", "type": "executor", "executor_type": "llm", "config": {"timeout": "5s"}},
+			"route": {"id": "route", "type": "router", "default_route": "end"},
+			"end": {"id": "end", "type": "end"}
+		}}`,
+		"list": `{"id": "g", "entry_node": "start", "nodes": [
+			{"id": "start", "type": "start"},
+			{"id": "llm", "type": "executor", "executor_type": "llm", "config": {"timeout": "5s"}},
+			{"id": "route", "type": "router", "default_route": "end"},
+			{"id": "end", "type": "end"}
+		]}`,
+	} {
+		g, err := DecodeGraph([]byte(data))
+		if err != nil {
+			t.Fatalf("%s: DecodeGraph() error = %v", name, err)
+		}
+		if len(g.Nodes) != 4 {
+			t.Fatalf("%s: decoded %d nodes, want 4", name, len(g.Nodes))
+		}
+
+		// Nodes without an id take their key
+		if start, ok := g.Nodes["start"].(*Node); !ok || start.ID != "start" || start.Type != graph.NodeTypeStart {
+			t.Errorf("%s: start = %#v, want a start *Node", name, g.Nodes["start"])
+		}
+		executor, ok := g.Nodes["llm"].(*graph.ExecutorNode)
+		if !ok || executor.ExecutorType != "llm" || executor.Config["timeout"] != "5s" {
+			t.Errorf("%s: llm = %#v, want an *graph.ExecutorNode with its config", name, g.Nodes["llm"])
+		}
+		if router, ok := g.Nodes["route"].(*graph.RouterNode); !ok || router.DefaultRoute != "end" {
+			t.Errorf("%s: route = %#v, want a *graph.RouterNode", name, g.Nodes["route"])
+		}
+	}
+}
+
+func TestDecodeGraphStateRoundTrip(t *testing.T) {
+	state := &domain.GraphState{
+		GraphID: "exec-1",
+		Graph: &domain.Graph{
+			ID:        "g",
+			EntryNode: "start",
+			Nodes: map[string]graph.Node{
+				"start": New("start", graph.NodeTypeStart, nil),
+				"wait":  New("wait", "custom", map[string]interface{}{"duration": "1m"}),
+			},
+		},
+		Status: domain.ExecutionStatusRunning,
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	decoded, err := DecodeGraphState(data)
+	if err != nil {
+		t.Fatalf("DecodeGraphState() error = %v", err)
+	}
+	if decoded.GraphID != "exec-1" || decoded.Status != domain.ExecutionStatusRunning {
+		t.Errorf("decoded state = %+v", decoded)
+	}
+	if !reflect.DeepEqual(decoded.Graph.Nodes, state.Graph.Nodes) {
+		t.Errorf("decoded nodes = %v, want %v", decoded.Graph.Nodes, state.Graph.Nodes)
+	}
+}
+
+func TestDecodeGraphRejectsMalformedNodes(t *testing.T) {
+	for _, data := range []string{
+		`{"nodes": "start"}`,
+		`{"nodes": [{"type": "start"}]}`,
+		`{"nodes": {"llm": {"type": "executor", "config": "fast"}}}`,
+	} {
+		if _, err := DecodeGraph([]byte(data)); err == nil {
+			t.Errorf("DecodeGraph(%s) succeeded", data)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Package nodes provides concrete graph node types and JSON decoding for graphs.
+//
+// Executor and router nodes are decoded into the dago-libs node types. Nodes
+// handled by the orchestrator itself (start, end) are decoded into Node.
+//
+// Example usage:
+//
+//	g, err := nodes.DecodeGraph(data)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+package nodes
@@ -0,0 +1,49 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aescanero/dago-libs/pkg/domain/graph"
+	"github.com/aescanero/dago-libs/pkg/domain/state"
+)
+
+// Node is a graph node handled by the orchestrator rather than by a worker pool
+type Node struct {
+	graph.BaseNode
+
+	// Config contains node-specific orchestration settings
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// New creates a node of the given type
+func New(id string, nodeType graph.NodeType, config map[string]interface{}) *Node {
+	return &Node{
+		BaseNode: graph.BaseNode{
+			ID:   id,
+			Type: nodeType,
+		},
+		Config: config,
+	}
+}
+
+// GetConfig returns the node's orchestration settings
+func (n *Node) GetConfig() map[string]interface{} {
+	return n.Config
+}
+
+// Execute is not supported; the orchestrator evaluates these nodes itself
+func (n *Node) Execute(ctx context.Context, s state.State) (state.State, error) {
+	return nil, fmt.Errorf("node %s of type %s is evaluated by the orchestrator", n.ID, n.Type)
+}
+
+// Validate checks if the node configuration is valid
+func (n *Node) Validate() error {
+	if n.ID == "" {
+		return &graph.ValidationError{Field: "id", Message: "node ID cannot be empty"}
+	}
+	if n.Type == "" {
+		return &graph.ValidationError{Field: "type", Message: "node type cannot be empty"}
+	}
+	return nil
+}